@@ -0,0 +1,48 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// MyAppProfile is the Schema for the myappprofiles API.
+// It holds a partial MyAppResource spec shared by the instances referencing it with spec.profileRef.
+type MyAppProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the values the instances referencing the profile start from. Their own values are merged over it.
+	// The instance-level switches paused, suspend and profileRef can't be set in a profile.
+	// +kubebuilder:validation:XValidation:rule="!has(self.paused) && !has(self.suspend) && !has(self.profileRef)",message="paused, suspend and profileRef can't be set in a profile"
+	Spec MyAppResourceSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MyAppProfileList contains a list of MyAppProfile
+type MyAppProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MyAppProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MyAppProfile{}, &MyAppProfileList{})
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var myappprofilelog = logf.Log.WithName("myappprofile-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *MyAppProfile) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-my-api-group-v1alpha1-myappprofile,mutating=false,failurePolicy=fail,sideEffects=None,groups=my.api.group,resources=myappprofiles,verbs=create;update,versions=v1alpha1,name=vmyappprofile.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &MyAppProfile{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MyAppProfile) ValidateCreate() (admission.Warnings, error) {
+	myappprofilelog.Info("validate create", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *MyAppProfile) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	myappprofilelog.Info("validate update", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MyAppProfile) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns the warnings and errors of the profile spec, which are the ones of the instances referencing it.
+func (r *MyAppProfile) validate() (admission.Warnings, error) {
+	warnings, allErrs := validateSpec(&r.Spec)
+	if len(allErrs) > 0 {
+		return warnings, apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "MyAppProfile"}, r.Name, allErrs)
+	}
+	return warnings, nil
+}
@@ -17,15 +17,28 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // MyAppResourceSpec defines the desired state of MyAppResource.
 type MyAppResourceSpec struct {
 
+	// ProfileRef references the MyAppProfile the spec is merged over. The values set by the instance take precedence
+	// over the ones of the profile, see status.effectiveSpec for the result.
+	ProfileRef *ProfileReference `json:"profileRef,omitempty"`
+
 	// ReplicaCount specifies the number of frontend replicas.
 	ReplicaCount *int32 `json:"replicaCount,omitempty"`
 
+	// Paused stops the operator from syncing the instance, leaving the managed objects as they are.
+	// The myapp.example/paused annotation set to "true" pauses the instance as well.
+	Paused bool `json:"paused,omitempty"`
+
+	// Suspend scales the frontend down to zero, keeping its config, until it is unset.
+	Suspend bool `json:"suspend,omitempty"`
+
 	// Resources specifies system resources for the frontend pods.
 	Resources *Resources `json:"resources,omitempty"`
 
@@ -35,16 +48,60 @@ type MyAppResourceSpec struct {
 	// UI specifies the UI configuration for the frontend pods.
 	UI *UI `json:"ui,omitempty"`
 
+	// Rollout specifies how the frontend pods are rolled out on changes.
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// Podinfo specifies additions to the frontend pod template.
+	Podinfo *Podinfo `json:"podinfo,omitempty"`
+
+	// PodTemplatePatch specifies a patch applied on top of the rendered frontend pod template.
+	PodTemplatePatch *PodTemplatePatch `json:"podTemplatePatch,omitempty"`
+
+	// Config specifies the podinfo settings rendered into a ConfigMap mounted in the frontend pods.
+	// The frontend pods are restarted when the rendered configuration changes.
+	Config *Config `json:"config,omitempty"`
+
 	// Redis specifies the Redis configuration for the frontend pods.
 	Redis *Redis `json:"redis,omitempty"`
+
+	// Monitoring specifies the Prometheus monitoring configuration for the frontend pods.
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+
+	// Scheduling specifies the scheduling constraints for the frontend pods.
+	// Replicas are softly spread across zones when no affinity is set.
+	Scheduling *Scheduling `json:"scheduling,omitempty"`
+
+	// SecurityContext overrides the restricted security context of the frontend pods.
+	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
+
+	// ServiceAccount specifies the configuration of the ServiceAccount created for the instance.
+	ServiceAccount *ServiceAccount `json:"serviceAccount,omitempty"`
+
+	// ServiceAccountName specifies an existing ServiceAccount the pods run as.
+	// No ServiceAccount is created for the instance when set.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// ProfileReference references a cluster-scoped MyAppProfile.
+type ProfileReference struct {
+	// Name is the name of the MyAppProfile.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
 }
 
 // Resources defines the resource requirements for the frontend pods.
+// The requests and limits set in the requirements take precedence over the deprecated shorthand fields.
 type Resources struct {
+	corev1.ResourceRequirements `json:",inline"`
+
 	// MemoryLimit specifies the maximum memory limit for the frontend pods.
+	// Deprecated: use limits.memory instead.
+	// +kubebuilder:validation:Pattern=`^(\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE](\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`
 	MemoryLimit string `json:"memoryLimit,omitempty"`
 
 	// CPURequest specifies the CPU request for the frontend pods.
+	// Deprecated: use requests.cpu instead.
+	// +kubebuilder:validation:Pattern=`^(\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE](\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`
 	CPURequest string `json:"cpuRequest,omitempty"`
 }
 
@@ -54,6 +111,18 @@ type Image struct {
 	Repository string `json:"repository,omitempty"`
 	// Tag specifies the tag of the container image.
 	Tag string `json:"tag,omitempty"`
+	// Digest specifies the digest of the container image, e.g. sha256:<hex>. It takes precedence over the tag.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`
+	Digest string `json:"digest,omitempty"`
+	// ResolveDigest indicates whether the tag is resolved to a digest at reconcile time.
+	// The resolved digest is recorded in status and reused until the repository or tag changes.
+	ResolveDigest *bool `json:"resolveDigest,omitempty"`
+	// PullPolicy specifies the pull policy of the container image.
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	PullPolicy corev1.PullPolicy `json:"pullPolicy,omitempty"`
+	// PullSecrets specifies the secrets used to pull the container image.
+	// They are also used to authenticate against the registry when resolving the digest.
+	PullSecrets []corev1.LocalObjectReference `json:"pullSecrets,omitempty"`
 }
 
 // UI specifies the configuration for the user interface.
@@ -64,16 +133,587 @@ type UI struct {
 	Message string `json:"message,omitempty"`
 }
 
+// Rollout specifies the rollout settings of the frontend Deployment.
+// The Deployment defaults apply to the settings left unset.
+// +kubebuilder:validation:XValidation:rule="!has(self.progressDeadlineSeconds) || !has(self.minReadySeconds) || self.progressDeadlineSeconds > self.minReadySeconds",message="progressDeadlineSeconds must be greater than minReadySeconds"
+// +kubebuilder:validation:XValidation:rule="!has(self.canary) || !has(self.blueGreen)",message="canary and blueGreen are mutually exclusive"
+type Rollout struct {
+	// Strategy specifies the strategy used to replace the old pods by new ones.
+	Strategy *appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+
+	// MinReadySeconds specifies the time a new pod must be ready, without crashing, to be considered available.
+	// +kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
+	// ProgressDeadlineSeconds specifies the time the rollout can make no progress before it is reported as degraded.
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// RevisionHistoryLimit specifies the number of old ReplicaSets kept to allow rollbacks.
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// Analysis evaluates Prometheus queries during image and config updates, rolling the frontend back
+	// to the last image and config that passed the analysis when they fail.
+	Analysis *Analysis `json:"analysis,omitempty"`
+
+	// Canary releases image changes to a canary Deployment first, promoting it once every step succeeded. The canary
+	// Deployment keeps serving until the promoted image is available on every stable replica.
+	// The frontend pods are restarted once when the canary release is enabled, to label them as stable.
+	Canary *Canary `json:"canary,omitempty"`
+
+	// BlueGreen releases the frontend changes to a preview color Deployment, switching the frontend Service over
+	// to it once it is ready. The frontend pods are replaced by the blue ones when the blue/green release is enabled.
+	// It is an object rather than a value of Strategy, which holds the strategy of the Deployments.
+	BlueGreen *BlueGreen `json:"blueGreen,omitempty"`
+}
+
+// BlueGreen specifies the blue/green release of the frontend changes.
+type BlueGreen struct {
+	// RequireApproval holds the switch-over to a ready preview until the instance is annotated with
+	// my.api.group/promote set to the preview revision reported in status.
+	RequireApproval *bool `json:"requireApproval,omitempty"`
+
+	// ScaleDownDelay specifies how long the previous color keeps running after the switch-over.
+	// +kubebuilder:default="30s"
+	ScaleDownDelay *metav1.Duration `json:"scaleDownDelay,omitempty"`
+}
+
+// Analysis specifies the metric analysis of the frontend updates.
+type Analysis struct {
+	// PrometheusAddress specifies the address of the Prometheus API, e.g. http://prometheus.monitoring:9090.
+	// Defaults to the address the operator is configured with. Any other address must be allowed by the operator.
+	PrometheusAddress string `json:"prometheusAddress,omitempty"`
+
+	// Interval specifies the interval the queries are evaluated at.
+	// +kubebuilder:default="1m"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Count specifies the number of successful runs after which the update is considered healthy.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	Count *int32 `json:"count,omitempty"`
+
+	// FailureLimit specifies the number of failed runs tolerated before the update is rolled back.
+	// +kubebuilder:validation:Minimum=0
+	FailureLimit int32 `json:"failureLimit,omitempty"`
+
+	// Metrics specifies the queries evaluated by each run. A run fails if any of them fails.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Metrics []AnalysisMetric `json:"metrics"`
+}
+
+// AnalysisOperator compares the result of a query with its threshold.
+// +kubebuilder:validation:Enum=LessThan;LessThanOrEqual;GreaterThan;GreaterThanOrEqual
+type AnalysisOperator string
+
+const (
+	AnalysisOperatorLessThan           AnalysisOperator = "LessThan"
+	AnalysisOperatorLessThanOrEqual    AnalysisOperator = "LessThanOrEqual"
+	AnalysisOperatorGreaterThan        AnalysisOperator = "GreaterThan"
+	AnalysisOperatorGreaterThanOrEqual AnalysisOperator = "GreaterThanOrEqual"
+)
+
+// AnalysisMetric specifies a query evaluated by the analysis.
+type AnalysisMetric struct {
+	// Name specifies the name of the metric, as recorded in status.
+	Name string `json:"name"`
+
+	// Query specifies the PromQL query, which must return a single sample.
+	// {{ .Namespace }} and {{ .Name }} are replaced by the namespace and name of the instance.
+	Query string `json:"query"`
+
+	// Operator specifies how the result of the query is compared with the threshold.
+	// +kubebuilder:default=LessThan
+	Operator AnalysisOperator `json:"operator,omitempty"`
+
+	// Threshold specifies the value the result of the query is compared with.
+	// +kubebuilder:validation:Pattern=`^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`
+	Threshold string `json:"threshold"`
+}
+
+// Canary specifies the canary release of the frontend image changes.
+type Canary struct {
+	// Steps specifies the traffic weights the canary goes through before being promoted.
+	// +kubebuilder:validation:MinItems=1
+	Steps []CanaryStep `json:"steps"`
+
+	// TrafficRouting specifies how the traffic is split between the stable and canary pods.
+	// The traffic is split by replica ratio behind the frontend Service when unset.
+	TrafficRouting *TrafficRouting `json:"trafficRouting,omitempty"`
+}
+
+// CanaryStep specifies a step of the canary release.
+type CanaryStep struct {
+	// Weight specifies the percentage of the traffic sent to the canary. When the traffic is split by replica
+	// ratio, the canary replicas are rounded up to at least one, so the canary may receive more than the weight,
+	// e.g. half of the traffic for any weight up to 50 with 2 replicas. At least 2 replicas are then required.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+
+	// Pause specifies the minimum duration of the step. The step also lasts until the canary pods are available.
+	Pause *metav1.Duration `json:"pause,omitempty"`
+}
+
+// TrafficRouting specifies how the traffic is split between the stable and canary pods.
+type TrafficRouting struct {
+	// Gateway splits the traffic with the backend weights of an HTTPRoute managed by the operator.
+	Gateway *GatewayRouting `json:"gateway,omitempty"`
+}
+
+// GatewayRouting specifies the Gateway API HTTPRoute splitting the traffic between the stable and canary pods.
+type GatewayRouting struct {
+	// ParentRefs specifies the Gateways the HTTPRoute attaches to.
+	// +kubebuilder:validation:MinItems=1
+	ParentRefs []GatewayParentReference `json:"parentRefs"`
+
+	// Hostnames specifies the hostnames the HTTPRoute matches.
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// GatewayParentReference references a Gateway the HTTPRoute attaches to.
+type GatewayParentReference struct {
+	// Name specifies the name of the Gateway.
+	Name string `json:"name"`
+
+	// Namespace specifies the namespace of the Gateway. Defaults to the namespace of the instance.
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName specifies the listener of the Gateway the HTTPRoute attaches to.
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// Podinfo specifies additions merged into the frontend pod template.
+// Entries conflicting with the ones managed by the operator are rejected.
+type Podinfo struct {
+	// ExtraEnv specifies environment variables added to the podinfo container.
+	// The variables managed by the operator, e.g. PODINFO_UI_COLOR or PODINFO_CACHE_SERVER, can't be set.
+	ExtraEnv []corev1.EnvVar `json:"extraEnv,omitempty"`
+
+	// EnvFrom specifies sources of environment variables added to the podinfo container.
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Volumes specifies volumes added to the frontend pods.
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts specifies volume mounts added to the podinfo container.
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// InitContainers specifies init containers added to the frontend pods.
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// Sidecars specifies containers added to the frontend pods next to podinfo.
+	// Sidecars without a security context run with the restricted one of podinfo.
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+}
+
+// PodTemplatePatchType specifies the format of a pod template patch.
+// +kubebuilder:validation:Enum=StrategicMerge;JSON
+type PodTemplatePatchType string
+
+const (
+	// PodTemplatePatchTypeStrategicMerge is a strategic merge patch, i.e. a PodTemplateSpec fragment.
+	PodTemplatePatchTypeStrategicMerge PodTemplatePatchType = "StrategicMerge"
+	// PodTemplatePatchTypeJSON is a RFC 6902 JSON patch, whose paths are relative to the pod template.
+	PodTemplatePatchTypeJSON PodTemplatePatchType = "JSON"
+)
+
+// PodTemplatePatch specifies a patch applied on top of a pod template rendered by the operator.
+// It covers the PodSpec fields the spec doesn't expose.
+type PodTemplatePatch struct {
+	// Type specifies the format of the patch.
+	// +kubebuilder:default=StrategicMerge
+	Type PodTemplatePatchType `json:"type,omitempty"`
+
+	// Patch holds the patch, in JSON or YAML.
+	// +kubebuilder:validation:MinLength=1
+	Patch string `json:"patch"`
+}
+
+// Config specifies the podinfo settings read from its configuration file.
+type Config struct {
+	// LogLevel specifies the log level of podinfo.
+	// +kubebuilder:validation:Enum=debug;info;warn;error
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// RandomDelay injects a random delay in the handling of the requests.
+	RandomDelay *RandomDelay `json:"randomDelay,omitempty"`
+
+	// RandomError makes podinfo randomly fail requests with a server error.
+	RandomError *bool `json:"randomError,omitempty"`
+
+	// BackendURLs specifies the backend services the echo API forwards requests to.
+	BackendURLs []string `json:"backendURLs,omitempty"`
+
+	// UILogo specifies the URL of the logo displayed by the user interface.
+	UILogo string `json:"uiLogo,omitempty"`
+
+	// H2C enables HTTP/2 over cleartext.
+	H2C *bool `json:"h2c,omitempty"`
+
+	// Unhealthy makes the liveness endpoint report podinfo as unhealthy.
+	Unhealthy *bool `json:"unhealthy,omitempty"`
+
+	// Unready makes the readiness endpoint report podinfo as not ready.
+	Unready *bool `json:"unready,omitempty"`
+}
+
+// RandomDelay specifies the random delay injected in the handling of the requests.
+// +kubebuilder:validation:XValidation:rule="!has(self.min) || !has(self.max) || self.min <= self.max",message="min must not be greater than max"
+type RandomDelay struct {
+	// Enabled indicates whether the random delay is injected or not.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Min specifies the minimum delay.
+	// +kubebuilder:validation:Minimum=0
+	Min *int32 `json:"min,omitempty"`
+
+	// Max specifies the maximum delay.
+	// +kubebuilder:validation:Minimum=0
+	Max *int32 `json:"max,omitempty"`
+
+	// Unit specifies the unit of the delay, in seconds or milliseconds.
+	// +kubebuilder:validation:Enum=s;ms
+	Unit string `json:"unit,omitempty"`
+}
+
 // Redis specifies the configuration for Redis.
 type Redis struct {
 	// Enabled indicates whether Redis is enabled or not.
-	Enabled bool `json:"enabled,omitempty"`
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Image specifies the image information for the Redis pods.
+	// Defaults to the Redis image of the operator configuration, docker.io/redis:7.2.4 unless configured otherwise.
+	Image *Image `json:"image,omitempty"`
+
+	// Scheduling specifies the scheduling constraints for the Redis pods.
+	Scheduling *Scheduling `json:"scheduling,omitempty"`
+
+	// SecurityContext overrides the restricted security context of the Redis pods.
+	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
+
+	// PodTemplatePatch specifies a patch applied on top of the rendered Redis pod template.
+	PodTemplatePatch *PodTemplatePatch `json:"podTemplatePatch,omitempty"`
+
+	// Suspend scales Redis down to zero along with the frontend when spec.suspend is set.
+	// The Redis volume claims are kept.
+	Suspend *bool `json:"suspend,omitempty"`
+}
+
+// Scheduling specifies the pod scheduling constraints.
+type Scheduling struct {
+	// NodeSelector specifies the node labels the pods must be scheduled on.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations specifies the taints tolerated by the pods.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity specifies the node affinity and pod (anti-)affinity rules of the pods.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints specifies how the pods are spread across topology domains.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClassName specifies the priority class of the pods.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// Monitoring specifies the Prometheus monitoring configuration.
+type Monitoring struct {
+	// Enabled indicates whether the metrics port is exposed and scraped.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Kind specifies the Prometheus Operator resource used to scrape the frontend pods.
+	// Defaults to ServiceMonitor.
+	// +kubebuilder:validation:Enum=ServiceMonitor;PodMonitor
+	Kind string `json:"kind,omitempty"`
+
+	// Interval specifies the scrape interval, e.g. 30s. Prometheus' default is used when unset.
+	// +kubebuilder:validation:Pattern=`^([0-9]+(ms|s|m|h))+$`
+	Interval string `json:"interval,omitempty"`
+
+	// Labels specifies additional labels set on the ServiceMonitor or PodMonitor,
+	// typically used to match the Prometheus resource selector.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Rules specifies the configuration of the default alerting rules.
+	Rules *MonitoringRules `json:"rules,omitempty"`
+}
+
+// MonitoringRules specifies the configuration of the default PrometheusRule.
+type MonitoringRules struct {
+	// Enabled indicates whether the PrometheusRule is rendered or not.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// ErrorRatePercent specifies the percentage of 5xx responses that fires the error rate alert.
+	// Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	ErrorRatePercent *int32 `json:"errorRatePercent,omitempty"`
+
+	// Labels specifies additional labels set on the PrometheusRule.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// SecurityContext specifies security settings replacing the defaults, which comply with
+// the restricted Pod Security Standard. Each level is replaced as a whole when set.
+type SecurityContext struct {
+	// Pod replaces the default pod-level security context.
+	Pod *corev1.PodSecurityContext `json:"pod,omitempty"`
+
+	// Container replaces the default container-level security context.
+	Container *corev1.SecurityContext `json:"container,omitempty"`
+}
+
+// ServiceAccount specifies the configuration of the ServiceAccount used by the pods.
+type ServiceAccount struct {
+	// AutomountServiceAccountToken indicates whether the API token is mounted in the pods.
+	// Defaults to false.
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+
+	// ImagePullSecrets specifies the secrets used to pull the pod images.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Annotations specifies annotations set on the ServiceAccount, e.g. workload identity bindings.
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // MyAppResourceStatus defines the observed state of MyAppResource
 type MyAppResourceStatus struct {
 	Valid bool   `json:"valid"`
 	Error string `json:"error"`
+
+	// PodinfoImage records the podinfo image reference pinned to the digest its tag resolved to.
+	PodinfoImage string `json:"podinfoImage,omitempty"`
+
+	// RedisImage records the Redis image reference pinned to the digest its tag resolved to.
+	RedisImage string `json:"redisImage,omitempty"`
+
+	// SpecRevision is the revision of the spec history recording the last successfully applied spec.
+	// The instance is rolled back to a previous revision by setting the my.api.group/rollback-to annotation to its number.
+	SpecRevision int64 `json:"specRevision,omitempty"`
+
+	// Rollout reports the progress of the frontend rollout.
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Canary reports the state of the canary release of the frontend.
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// Analysis reports the state of the metric analysis of the frontend updates.
+	Analysis *AnalysisStatus `json:"analysis,omitempty"`
+
+	// BlueGreen reports the state of the blue/green release of the frontend.
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+
+	// EffectiveSpec is the spec the objects were rendered from, the spec of the instance merged over the profile it
+	// references. It is unset when the instance doesn't reference a profile.
+	// Its schema is left out of the CRD, which would double in size otherwise.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	EffectiveSpec *MyAppResourceSpec `json:"effectiveSpec,omitempty"`
+
+	// Shard is the operator replica that reconciled the instance last, when the instances are sharded across replicas.
+	Shard string `json:"shard,omitempty"`
+
+	// Conditions reports the latest observations of the instance state.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConditionTypeDegraded is true when the frontend rollout exceeded its progress deadline.
+const ConditionTypeDegraded = "Degraded"
+
+// ConditionTypePaused is true while the operator doesn't sync the instance.
+const ConditionTypePaused = "Paused"
+
+// ConditionTypeSuspended is true while the frontend is scaled down to zero.
+const ConditionTypeSuspended = "Suspended"
+
+// CanaryPhase is the phase of a canary release.
+type CanaryPhase string
+
+const (
+	// CanaryPhaseStable is the phase of a release whose stable image matches the spec.
+	CanaryPhaseStable CanaryPhase = "Stable"
+	// CanaryPhaseProgressing is the phase of a release going through its steps.
+	CanaryPhaseProgressing CanaryPhase = "Progressing"
+	// CanaryPhasePromoting is the phase of a release whose canary image is rolled out to the stable Deployment.
+	// The canary Deployment keeps serving until the stable pods are available.
+	CanaryPhasePromoting CanaryPhase = "Promoting"
+	// CanaryPhaseAborted is the phase of a release whose canary failed to become available.
+	// The stable image keeps being served until the spec image changes again.
+	CanaryPhaseAborted CanaryPhase = "Aborted"
+)
+
+// CanaryStatus reports the state of the canary release of the frontend.
+type CanaryStatus struct {
+	// Phase is the phase of the release.
+	Phase CanaryPhase `json:"phase,omitempty"`
+
+	// StableImage is the image reference served by the stable Deployment.
+	StableImage string `json:"stableImage,omitempty"`
+
+	// CanaryImage is the image reference released by the canary Deployment.
+	CanaryImage string `json:"canaryImage,omitempty"`
+
+	// Step is the index of the current step of the release.
+	Step *int32 `json:"step,omitempty"`
+
+	// Weight is the percentage of the traffic sent to the canary.
+	Weight int32 `json:"weight,omitempty"`
+
+	// StepStartedAt is the time the current step started.
+	StepStartedAt *metav1.Time `json:"stepStartedAt,omitempty"`
+
+	// Message describes the state of the release.
+	Message string `json:"message,omitempty"`
+}
+
+// BlueGreenPhase is the phase of a blue/green release.
+type BlueGreenPhase string
+
+const (
+	// BlueGreenPhaseStarting is the phase of a release enabled on an instance, whose first color rolls out while the
+	// frontend Deployment keeps serving until the pods of the color are available.
+	BlueGreenPhaseStarting BlueGreenPhase = "Starting"
+	// BlueGreenPhaseActive is the phase of an instance served by its active color only.
+	BlueGreenPhaseActive BlueGreenPhase = "Active"
+	// BlueGreenPhasePreviewing is the phase of a change being rolled out to the preview color.
+	BlueGreenPhasePreviewing BlueGreenPhase = "Previewing"
+	// BlueGreenPhaseStopping is the phase of a release disabled on an instance, whose colors keep serving until the
+	// pods of the frontend Deployment replacing them are available.
+	BlueGreenPhaseStopping BlueGreenPhase = "Stopping"
+)
+
+// BlueGreenStatus reports the state of the blue/green release of the frontend.
+type BlueGreenStatus struct {
+	// Phase is the phase of the release.
+	Phase BlueGreenPhase `json:"phase,omitempty"`
+
+	// ActiveColor is the color the frontend Service selects, blue or green.
+	ActiveColor string `json:"activeColor,omitempty"`
+
+	// ActiveRevision identifies the pod template of the active color.
+	ActiveRevision string `json:"activeRevision,omitempty"`
+
+	// PreviewColor is the color the change is rolled out to.
+	PreviewColor string `json:"previewColor,omitempty"`
+
+	// PreviewRevision identifies the pod template of the preview color, which the promote annotation must be set to
+	// when approval is required.
+	PreviewRevision string `json:"previewRevision,omitempty"`
+
+	// ScaleDownAt is the time the previous color is scaled down at after a switch-over.
+	ScaleDownAt *metav1.Time `json:"scaleDownAt,omitempty"`
+
+	// Message describes the state of the release.
+	Message string `json:"message,omitempty"`
+}
+
+// AnalysisPhase is the phase of the analysis of an update.
+type AnalysisPhase string
+
+const (
+	// AnalysisPhaseHealthy is the phase of an instance whose image and config passed the analysis.
+	AnalysisPhaseHealthy AnalysisPhase = "Healthy"
+	// AnalysisPhaseRunning is the phase of an update being analysed.
+	AnalysisPhaseRunning AnalysisPhase = "Running"
+	// AnalysisPhaseFailed is the phase of an update that failed the analysis and was rolled back.
+	// The stable image and config keep being served until the spec changes again.
+	AnalysisPhaseFailed AnalysisPhase = "Failed"
+)
+
+// AnalysisRunPhase is the result of an analysis run of a metric.
+type AnalysisRunPhase string
+
+const (
+	AnalysisRunPhaseSuccessful AnalysisRunPhase = "Successful"
+	AnalysisRunPhaseFailed     AnalysisRunPhase = "Failed"
+	// AnalysisRunPhaseError is the phase of a run whose query couldn't be evaluated. It counts as a failure.
+	AnalysisRunPhaseError AnalysisRunPhase = "Error"
+)
+
+// AnalysisStatus reports the state of the metric analysis of the frontend updates.
+type AnalysisStatus struct {
+	// Phase is the phase of the analysis.
+	Phase AnalysisPhase `json:"phase,omitempty"`
+
+	// StableImage is the last image reference that passed the analysis.
+	StableImage string `json:"stableImage,omitempty"`
+
+	// StableConfig is the last podinfo config that passed the analysis.
+	StableConfig *Config `json:"stableConfig,omitempty"`
+
+	// Revision identifies the image and config under analysis.
+	Revision string `json:"revision,omitempty"`
+
+	// StartedAt is the time the analysis of the revision started at.
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// SuccessfulRuns is the number of successful runs of the revision under analysis.
+	SuccessfulRuns int32 `json:"successfulRuns,omitempty"`
+
+	// FailedRuns is the number of failed runs of the revision under analysis.
+	FailedRuns int32 `json:"failedRuns,omitempty"`
+
+	// Runs records the latest runs of the metrics, the oldest ones being dropped past ten runs.
+	Runs []AnalysisRun `json:"runs,omitempty"`
+
+	// Message describes the state of the analysis.
+	Message string `json:"message,omitempty"`
+}
+
+// AnalysisRun records the evaluation of the metrics of the analysis.
+type AnalysisRun struct {
+	// Revision identifies the image and config analysed by the run.
+	Revision string `json:"revision"`
+
+	// Time is the time the run was evaluated at.
+	Time metav1.Time `json:"time"`
+
+	// Phase is the result of the run, failed if any metric failed.
+	Phase AnalysisRunPhase `json:"phase"`
+
+	// Metrics records the result of each metric.
+	Metrics []AnalysisMetricResult `json:"metrics,omitempty"`
+}
+
+// AnalysisMetricResult records the evaluation of a metric.
+type AnalysisMetricResult struct {
+	// Name is the name of the metric.
+	Name string `json:"name"`
+
+	// Phase is the result of the metric.
+	Phase AnalysisRunPhase `json:"phase"`
+
+	// Value is the result of the query.
+	Value string `json:"value,omitempty"`
+
+	// Message describes why the query couldn't be evaluated.
+	Message string `json:"message,omitempty"`
+}
+
+// RolloutStatus reports the progress of the frontend Deployment rollout.
+type RolloutStatus struct {
+	// Revision is the revision of the Deployment currently rolled out.
+	Revision string `json:"revision,omitempty"`
+
+	// Replicas is the number of pods targeted by the Deployment.
+	Replicas int32 `json:"replicas"`
+
+	// UpdatedReplicas is the number of pods running the current revision.
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// ReadyReplicas is the number of ready pods.
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// AvailableReplicas is the number of pods ready for at least minReadySeconds.
+	AvailableReplicas int32 `json:"availableReplicas"`
 }
 
 // +kubebuilder:object:root=true
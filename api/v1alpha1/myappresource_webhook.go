@@ -0,0 +1,229 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/aa-ang4335/myappresource-operator/internal/podtemplate"
+)
+
+// log is for logging in this package.
+var myappresourcelog = logf.Log.WithName("myappresource-resource")
+
+// SpecResolver returns the effective spec of an instance referencing a profile.
+// +kubebuilder:object:generate=false
+type SpecResolver func(ctx context.Context, spec *MyAppResourceSpec) (*MyAppResourceSpec, error)
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks. The instances referencing a profile are
+// validated against the effective spec returned by resolve.
+func (r *MyAppResource) SetupWebhookWithManager(mgr ctrl.Manager, resolve SpecResolver) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(NewMyAppResourceValidator(resolve)).
+		Complete()
+}
+
+// NewMyAppResourceValidator returns the validator of the instances, which validates the instances referencing a
+// profile against the effective spec returned by resolve.
+func NewMyAppResourceValidator(resolve SpecResolver) admission.CustomValidator {
+	return &myAppResourceValidator{resolve: resolve}
+}
+
+//+kubebuilder:webhook:path=/validate-my-api-group-v1alpha1-myappresource,mutating=false,failurePolicy=fail,sideEffects=None,groups=my.api.group,resources=myappresources,verbs=create;update,versions=v1alpha1,name=vmyappresource.kb.io,admissionReviewVersions=v1
+
+// myAppResourceValidator validates the instances, along with the profile they reference.
+type myAppResourceValidator struct {
+	resolve SpecResolver
+}
+
+var _ admission.CustomValidator = &myAppResourceValidator{}
+
+// ValidateCreate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *myAppResourceValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	r := obj.(*MyAppResource)
+	myappresourcelog.Info("validate create", "name", r.Name)
+
+	return v.validate(ctx, r)
+}
+
+// ValidateUpdate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *myAppResourceValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	r := newObj.(*MyAppResource)
+	myappresourcelog.Info("validate update", "name", r.Name)
+
+	return v.validate(ctx, r)
+}
+
+// ValidateDelete implements admission.CustomValidator so a webhook will be registered for the type
+func (v *myAppResourceValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns the warnings and errors of the MyAppResource spec, or of its effective spec when it references a
+// profile. An instance whose profile can't be read is validated on its own spec, with a warning.
+func (v *myAppResourceValidator) validate(ctx context.Context, r *MyAppResource) (admission.Warnings, error) {
+	var warnings admission.Warnings
+	spec := &r.Spec
+	if r.Spec.ProfileRef != nil && v.resolve != nil {
+		effective, err := v.resolve(ctx, &r.Spec)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("spec.profileRef: %s, only the spec of the instance is validated", err))
+		} else {
+			spec = effective
+		}
+	}
+
+	specWarnings, allErrs := validateSpec(spec)
+	warnings = append(warnings, specWarnings...)
+	allErrs = append(allErrs, validateCanaryReplicas(spec)...)
+	if len(allErrs) > 0 {
+		return warnings, apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "MyAppResource"}, r.Name, allErrs)
+	}
+	return warnings, nil
+}
+
+// validateSpec returns the warnings and errors of a MyAppResource spec, shared by the instances and the profiles.
+func validateSpec(spec *MyAppResourceSpec) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+
+	warnings = append(warnings, validatePodSecurityStandard("spec.securityContext", spec.SecurityContext)...)
+	allErrs = append(allErrs, validatePodTemplatePatch(field.NewPath("spec", "podTemplatePatch"), spec.PodTemplatePatch)...)
+	if spec.Redis != nil {
+		warnings = append(warnings, validatePodSecurityStandard("spec.redis.securityContext", spec.Redis.SecurityContext)...)
+		allErrs = append(allErrs, validatePodTemplatePatch(field.NewPath("spec", "redis", "podTemplatePatch"), spec.Redis.PodTemplatePatch)...)
+	}
+	return warnings, allErrs
+}
+
+// validateCanaryReplicas returns an error if the traffic of a canary release is split by replica ratio with fewer
+// than 2 replicas, as the canary runs at least one replica and would receive all the traffic from the first step.
+// It only applies to the effective spec of the instances, a profile may leave the replicas to them.
+func validateCanaryReplicas(spec *MyAppResourceSpec) field.ErrorList {
+	if spec.Rollout == nil || spec.Rollout.Canary == nil || len(spec.Rollout.Canary.Steps) == 0 {
+		return nil
+	}
+	if routing := spec.Rollout.Canary.TrafficRouting; routing != nil && routing.Gateway != nil {
+		return nil
+	}
+	replicas := int32(1)
+	if spec.ReplicaCount != nil {
+		replicas = *spec.ReplicaCount
+	}
+	if replicas < 2 {
+		return field.ErrorList{field.Invalid(field.NewPath("spec", "replicaCount"), replicas, "canary releases split by replica ratio require at least 2 replicas")}
+	}
+	return nil
+}
+
+// validatePodTemplatePatch returns an error if the pod template patch can't be decoded.
+func validatePodTemplatePatch(path *field.Path, patch *PodTemplatePatch) field.ErrorList {
+	if patch == nil {
+		return nil
+	}
+	if err := podtemplate.Validate(string(patch.Type), patch.Patch); err != nil {
+		return field.ErrorList{field.Invalid(path.Child("patch"), patch.Patch, err.Error())}
+	}
+	return nil
+}
+
+// validatePodSecurityStandard returns a warning for every security context override breaking the restricted Pod Security Standard.
+// A level that isn't overridden keeps the operator defaults, which are compliant.
+// See https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
+func validatePodSecurityStandard(path string, overrides *SecurityContext) admission.Warnings {
+	if overrides == nil || (overrides.Pod == nil && overrides.Container == nil) {
+		return nil
+	}
+
+	var warnings admission.Warnings
+	warn := func(field string, reason string) {
+		warnings = append(warnings, fmt.Sprintf("%s.%s: %s, which breaks the restricted Pod Security Standard", path, field, reason))
+	}
+
+	pod := overrides.Pod
+	container := overrides.Container
+
+	// runAsNonRoot must be true on the container, or on the pod when the container doesn't set it.
+	runAsNonRoot := pod == nil || (pod.RunAsNonRoot != nil && *pod.RunAsNonRoot)
+	if container == nil {
+		runAsNonRoot = true
+	} else if container.RunAsNonRoot != nil {
+		runAsNonRoot = *container.RunAsNonRoot
+	}
+	if !runAsNonRoot {
+		warn("runAsNonRoot", "must be true")
+	}
+	if pod != nil && pod.RunAsUser != nil && *pod.RunAsUser == 0 {
+		warn("pod.runAsUser", "must not be 0")
+	}
+	if container != nil && container.RunAsUser != nil && *container.RunAsUser == 0 {
+		warn("container.runAsUser", "must not be 0")
+	}
+
+	// the seccomp profile must be RuntimeDefault or Localhost on the container, or on the pod when the container doesn't set it.
+	seccompProfile := &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	if pod != nil {
+		seccompProfile = pod.SeccompProfile
+	}
+	if container != nil && container.SeccompProfile != nil {
+		seccompProfile = container.SeccompProfile
+	}
+	if seccompProfile == nil || seccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		warn("seccompProfile.type", "must be RuntimeDefault or Localhost")
+	}
+
+	if container == nil {
+		return warnings
+	}
+
+	if container.Privileged != nil && *container.Privileged {
+		warn("container.privileged", "must be false")
+	}
+	if container.AllowPrivilegeEscalation == nil || *container.AllowPrivilegeEscalation {
+		warn("container.allowPrivilegeEscalation", "must be false")
+	}
+
+	dropsAll := false
+	if container.Capabilities != nil {
+		for _, capability := range container.Capabilities.Drop {
+			if capability == "ALL" {
+				dropsAll = true
+			}
+		}
+		for _, capability := range container.Capabilities.Add {
+			if capability != "NET_BIND_SERVICE" {
+				warn("container.capabilities.add", fmt.Sprintf("must not add %s", capability))
+			}
+		}
+	}
+	if !dropsAll {
+		warn("container.capabilities.drop", "must contain ALL")
+	}
+
+	return warnings
+}
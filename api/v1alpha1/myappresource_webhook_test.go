@@ -0,0 +1,220 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidatePodSecurityStandard(t *testing.T) {
+	yes, no := true, false
+	root := int64(0)
+
+	for _, tc := range []struct {
+		name      string
+		overrides *SecurityContext
+		expected  admission.Warnings
+	}{
+		{
+			name:      "no overrides",
+			overrides: nil,
+		},
+		{
+			name: "compliant container override",
+			overrides: &SecurityContext{
+				Container: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &no,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}, Add: []corev1.Capability{"NET_BIND_SERVICE"}},
+				},
+			},
+		},
+		{
+			name: "pod override running as root without seccomp",
+			overrides: &SecurityContext{
+				Pod: &corev1.PodSecurityContext{RunAsNonRoot: &no, RunAsUser: &root},
+			},
+			expected: admission.Warnings{
+				"spec.securityContext.pod.runAsUser: must not be 0, which breaks the restricted Pod Security Standard",
+				"spec.securityContext.seccompProfile.type: must be RuntimeDefault or Localhost, which breaks the restricted Pod Security Standard",
+			},
+		},
+		{
+			name: "privileged container override",
+			overrides: &SecurityContext{
+				Pod: &corev1.PodSecurityContext{
+					RunAsNonRoot:   &yes,
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+				},
+				Container: &corev1.SecurityContext{
+					Privileged:   &yes,
+					RunAsNonRoot: &no,
+					Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+				},
+			},
+			expected: admission.Warnings{
+				"spec.securityContext.runAsNonRoot: must be true, which breaks the restricted Pod Security Standard",
+				"spec.securityContext.seccompProfile.type: must be RuntimeDefault or Localhost, which breaks the restricted Pod Security Standard",
+				"spec.securityContext.container.privileged: must be false, which breaks the restricted Pod Security Standard",
+				"spec.securityContext.container.allowPrivilegeEscalation: must be false, which breaks the restricted Pod Security Standard",
+				"spec.securityContext.container.capabilities.add: must not add SYS_ADMIN, which breaks the restricted Pod Security Standard",
+				"spec.securityContext.container.capabilities.drop: must contain ALL, which breaks the restricted Pod Security Standard",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			warnings := validatePodSecurityStandard("spec.securityContext", tc.overrides)
+
+			if diff := cmp.Diff(tc.expected, warnings); diff != "" {
+				t.Errorf("validatePodSecurityStandard: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidatePodTemplatePatch(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		patch       *PodTemplatePatch
+		expectedErr bool
+	}{
+		{
+			name: "strategic merge patch in YAML",
+			patch: &PodTemplatePatch{
+				Type:  PodTemplatePatchTypeStrategicMerge,
+				Patch: "spec:\n  hostAliases:\n  - ip: 10.0.0.1\n    hostnames: [backend]\n",
+			},
+		},
+		{
+			name: "JSON patch",
+			patch: &PodTemplatePatch{
+				Type:  PodTemplatePatchTypeJSON,
+				Patch: `[{"op":"add","path":"/spec/containers/0/args","value":["--level=debug"]}]`,
+			},
+		},
+		{
+			name:        "strategic merge patch with a mistyped field",
+			patch:       &PodTemplatePatch{Patch: `{"spec":{"containers":"podinfo"}}`},
+			expectedErr: true,
+		},
+		{
+			name:        "strategic merge patch that isn't an object",
+			patch:       &PodTemplatePatch{Patch: `[{"op":"remove","path":"/spec/affinity"}]`},
+			expectedErr: true,
+		},
+		{
+			name:        "JSON patch with an unknown operation",
+			patch:       &PodTemplatePatch{Type: PodTemplatePatchTypeJSON, Patch: `[{"op":"merge","path":"/spec"}]`},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &MyAppResource{Spec: MyAppResourceSpec{PodTemplatePatch: tc.patch}}
+			_, err := NewMyAppResourceValidator(nil).ValidateCreate(context.Background(), r)
+
+			if tc.expectedErr && err == nil {
+				t.Errorf("validate: expected an error")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("validate: unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEffectiveSpec(t *testing.T) {
+	invalid := &PodTemplatePatch{Patch: `{"spec":{"containers":"podinfo"}}`}
+	profiles := map[string]*MyAppResourceSpec{"invalid": {PodTemplatePatch: invalid}, "valid": {}}
+	resolve := func(ctx context.Context, spec *MyAppResourceSpec) (*MyAppResourceSpec, error) {
+		profile, found := profiles[spec.ProfileRef.Name]
+		if !found {
+			return nil, errors.New("profile " + spec.ProfileRef.Name + " not found")
+		}
+		return profile, nil
+	}
+
+	for _, tc := range []struct {
+		name             string
+		profile          string
+		expectedWarnings admission.Warnings
+		expectedErr      bool
+	}{
+		{name: "valid profile", profile: "valid"},
+		{name: "profile with an invalid patch", profile: "invalid", expectedErr: true},
+		{
+			name:             "missing profile",
+			profile:          "missing",
+			expectedWarnings: admission.Warnings{"spec.profileRef: profile missing not found, only the spec of the instance is validated"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &MyAppResource{Spec: MyAppResourceSpec{ProfileRef: &ProfileReference{Name: tc.profile}}}
+			warnings, err := NewMyAppResourceValidator(resolve).ValidateCreate(context.Background(), r)
+
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("ValidateCreate: expected error %t, got %v", tc.expectedErr, err)
+			}
+			if diff := cmp.Diff(tc.expectedWarnings, warnings); diff != "" {
+				t.Errorf("ValidateCreate: warnings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateCanaryReplicas(t *testing.T) {
+	canary := func(replicas *int32, gateway bool) *MyAppResource {
+		r := &MyAppResource{Spec: MyAppResourceSpec{
+			ReplicaCount: replicas,
+			Rollout:      &Rollout{Canary: &Canary{Steps: []CanaryStep{{Weight: 10}}}},
+		}}
+		if gateway {
+			r.Spec.Rollout.Canary.TrafficRouting = &TrafficRouting{Gateway: &GatewayRouting{ParentRefs: []GatewayParentReference{{Name: "gateway"}}}}
+		}
+		return r
+	}
+	two := int32(2)
+
+	for _, tc := range []struct {
+		name        string
+		argInstance *MyAppResource
+		expectedErr bool
+	}{
+		{name: "no canary", argInstance: &MyAppResource{}},
+		{name: "replica ratio with the default replica", argInstance: canary(nil, false), expectedErr: true},
+		{name: "replica ratio with 2 replicas", argInstance: canary(&two, false)},
+		{name: "gateway routing with the default replica", argInstance: canary(nil, true)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewMyAppResourceValidator(nil).ValidateCreate(context.Background(), tc.argInstance)
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("ValidateCreate: expected error %t, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	r := &MyAppProfile{Spec: MyAppResourceSpec{Redis: &Redis{PodTemplatePatch: &PodTemplatePatch{Patch: "[]"}}}}
+	if _, err := r.ValidateCreate(); err == nil {
+		t.Errorf("ValidateCreate: expected the invalid redis patch of the profile to be rejected")
+	}
+}
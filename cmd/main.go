@@ -17,9 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"net/http"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -29,6 +32,8 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -36,6 +41,13 @@ import (
 
 	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
 	"github.com/aa-ang4335/myappresource-operator/internal/controller"
+	"github.com/aa-ang4335/myappresource-operator/internal/operatorconfig"
+	"github.com/aa-ang4335/myappresource-operator/internal/profile"
+	"github.com/aa-ang4335/myappresource-operator/internal/prometheus"
+	"github.com/aa-ang4335/myappresource-operator/internal/registry"
+	"github.com/aa-ang4335/myappresource-operator/internal/scope"
+	"github.com/aa-ang4335/myappresource-operator/internal/sharding"
+	"github.com/aa-ang4335/myappresource-operator/internal/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -57,6 +69,20 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var prometheusAddr string
+	var prometheusAllowedAddrs string
+	var registryAllowedHosts string
+	var enableTracing bool
+	var tracingOptions tracing.Options
+	controllerOptions := controller.DefaultOptions()
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
+	var watchNamespaces string
+	var watchNamespaceSelector string
+	var configPath string
+	var configReloadInterval time.Duration
+	var enableSharding bool
+	var shardingOptions sharding.Options
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -66,6 +92,52 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&prometheusAddr, "prometheus-address", "",
+		"The address of the Prometheus API the rollout analyses are evaluated against, unless they set their own.")
+	flag.StringVar(&prometheusAllowedAddrs, "prometheus-allowed-addresses", "",
+		"The comma separated addresses of the Prometheus APIs the rollout analyses may set in their spec, none if empty.")
+	flag.StringVar(&registryAllowedHosts, "registry-allowed-hosts", "",
+		"The comma separated hosts of the registries the image tags may be resolved against, none if empty.")
+	flag.BoolVar(&enableTracing, "enable-tracing", false,
+		"If set, the reconciles are traced and the spans exported to the OTLP endpoint, or written to stdout if it isn't set.")
+	flag.StringVar(&tracingOptions.Endpoint, "otlp-endpoint", "",
+		"The host and port of the OTLP/HTTP endpoint the spans are exported to, e.g. localhost:4318.")
+	flag.BoolVar(&tracingOptions.Insecure, "otlp-insecure", false,
+		"If set, the spans are exported to the OTLP endpoint without TLS.")
+	flag.Float64Var(&tracingOptions.SampleRatio, "tracing-sample-ratio", 1,
+		"The ratio of the reconciles traced, between 0 and 1.")
+	flag.IntVar(&controllerOptions.MaxConcurrentReconciles, "max-concurrent-reconciles", controller.DefaultMaxConcurrentReconciles,
+		"The number of instances reconciled in parallel.")
+	flag.DurationVar(&controllerOptions.RateLimiterBaseDelay, "rate-limiter-base-delay", controller.DefaultRateLimiterBaseDelay,
+		"The delay before retrying the first failed reconcile of an instance, doubled on each consecutive failure.")
+	flag.DurationVar(&controllerOptions.RateLimiterMaxDelay, "rate-limiter-max-delay", controller.DefaultRateLimiterMaxDelay,
+		"The maximum delay before retrying the failed reconciles of an instance.")
+	flag.Float64Var(&controllerOptions.RateLimiterQPS, "rate-limiter-qps", controller.DefaultRateLimiterQPS,
+		"The overall rate of the reconciles queued per second.")
+	flag.IntVar(&controllerOptions.RateLimiterBurst, "rate-limiter-burst", controller.DefaultRateLimiterBurst,
+		"The number of reconciles queued at once beyond the rate limiter QPS.")
+	flag.DurationVar(&controllerOptions.ResyncPeriod, "resync-period", 0,
+		"The period the instances are reconciled at to detect drifted objects, disabled if 0.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 20,
+		"The rate of the requests to the Kubernetes API server per second.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 30,
+		"The number of requests sent at once to the Kubernetes API server beyond its QPS.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"The comma separated namespaces the instances are watched in, every namespace if neither this nor the namespace selector is set.")
+	flag.StringVar(&watchNamespaceSelector, "watch-namespace-selector", "",
+		"The label selector of the namespaces the instances are watched in, resolved on startup: the namespaces labeled afterwards are only watched after a restart.")
+	flag.BoolVar(&enableSharding, "enable-sharding", false,
+		"If set, the instances are sharded across the replicas of the operator, coordinated through Leases.")
+	flag.StringVar(&shardingOptions.ID, "shard-id", os.Getenv("POD_NAME"),
+		"The identity of the replica in the shards, unique across the replicas. Defaults to the POD_NAME environment variable or the hostname.")
+	flag.StringVar(&shardingOptions.Namespace, "shard-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace of the shard Leases. Defaults to the POD_NAMESPACE environment variable.")
+	flag.DurationVar(&shardingOptions.LeaseDuration, "shard-lease-duration", 15*time.Second,
+		"The duration after which a replica that stopped renewing its shard Lease leaves the shards.")
+	flag.StringVar(&configPath, "config", "",
+		"The path of the operator configuration file, e.g. mounted from a ConfigMap, setting the defaults of the rendered objects.")
+	flag.DurationVar(&configReloadInterval, "config-reload-interval", 10*time.Second,
+		"The interval the operator configuration file is checked for changes at.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -74,6 +146,33 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	ctx := ctrl.SetupSignalHandler()
+	if enableTracing {
+		shutdownTracing, err := tracing.Setup(ctx, tracingOptions)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				setupLog.Error(err, "problem flushing the spans")
+			}
+		}()
+		setupLog.Info("tracing enabled", "endpoint", tracingOptions.Endpoint)
+	}
+
+	var configWatcher *operatorconfig.Watcher
+	if configPath != "" {
+		config, err := operatorconfig.Load(configPath)
+		if err != nil {
+			setupLog.Error(err, "unable to load the operator configuration", "path", configPath)
+			os.Exit(1)
+		}
+		operatorconfig.Set(config)
+		configWatcher = operatorconfig.NewWatcher(configPath, configReloadInterval)
+		setupLog.Info("loaded the operator configuration", "path", configPath)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -94,12 +193,60 @@ func main() {
 		TLSOpts: tlsOpts,
 	})
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = float32(kubeAPIQPS)
+	restConfig.Burst = kubeAPIBurst
+
+	// the watched namespaces and the permissions are resolved before the cache of the manager is started.
+	directClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	namespaces, err := scope.ResolveNamespaces(ctx, directClient, watchNamespaces, watchNamespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "unable to resolve the watched namespaces")
+		os.Exit(1)
+	}
+	if err := scope.CheckPermissions(ctx, directClient, namespaces, controller.RequiredPermissions()); err != nil {
+		setupLog.Error(err, "unable to start the operator, grant the permissions of config/rbac/role.yaml, "+
+			"or of config/namespaced/role.yaml in each watched namespace")
+		os.Exit(1)
+	}
+	if len(namespaces) > 0 {
+		setupLog.Info("watching namespaces", "namespaces", namespaces)
+	}
+
+	var shards *sharding.Sharder
+	if enableSharding {
+		if shardingOptions.ID == "" {
+			shardingOptions.ID, _ = os.Hostname()
+		}
+		if shardingOptions.ID == "" || shardingOptions.Namespace == "" {
+			setupLog.Error(nil, "the shard id and namespace must be set to enable sharding")
+			os.Exit(1)
+		}
+		if err := scope.CheckPermissions(ctx, directClient, []string{shardingOptions.Namespace}, sharding.RequiredPermissions()); err != nil {
+			setupLog.Error(err, "unable to enable sharding, grant the permissions of config/rbac/leader_election_role.yaml")
+			os.Exit(1)
+		}
+		shards = sharding.New(directClient, shardingOptions)
+		setupLog.Info("sharding enabled", "shard", shardingOptions.ID)
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme: scheme,
+		Cache: cache.Options{
+			DefaultNamespaces: scope.CacheNamespaces(namespaces),
+			ByObject:          controller.CacheByObject(),
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
 			TLSOpts:       tlsOpts,
+			ExtraHandlers: map[string]http.Handler{
+				"/render": controller.NewRenderHandler(scheme, directClient),
+			},
 		},
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -123,12 +270,50 @@ func main() {
 	}
 
 	if err = (&controller.MyAppResourceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                     mgr.GetClient(),
+		Scheme:                     mgr.GetScheme(),
+		APIReader:                  mgr.GetAPIReader(),
+		ImageResolver:              registry.NewResolver(registry.ParseHosts(registryAllowedHosts)),
+		Prometheus:                 prometheus.NewClient(),
+		PrometheusAddress:          prometheusAddr,
+		PrometheusAllowedAddresses: prometheus.ParseAddresses(prometheusAllowedAddrs),
+		Options:                    controllerOptions,
+		Shards:                     shards,
+		Config:                     configWatcher,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MyAppResource")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		resolveProfile := func(ctx context.Context, spec *myapigroupv1alpha1.MyAppResourceSpec) (*myapigroupv1alpha1.MyAppResourceSpec, error) {
+			return profile.Resolve(ctx, mgr.GetClient(), spec)
+		}
+		if err = (&myapigroupv1alpha1.MyAppResource{}).SetupWebhookWithManager(mgr, resolveProfile); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MyAppResource")
+			os.Exit(1)
+		}
+		if err = (&myapigroupv1alpha1.MyAppProfile{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MyAppProfile")
+			os.Exit(1)
+		}
+	}
+	if configWatcher != nil {
+		if err := mgr.Add(configWatcher); err != nil {
+			setupLog.Error(err, "unable to watch the operator configuration")
+			os.Exit(1)
+		}
+	}
+	if shards != nil {
+		if err := mgr.Add(shards); err != nil {
+			setupLog.Error(err, "unable to join the shards")
+			os.Exit(1)
+		}
+		// the leader collects the Leases of the replicas that left without deleting them.
+		if err := mgr.Add(shards.Collector()); err != nil {
+			setupLog.Error(err, "unable to collect the shard leases")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -141,7 +326,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/prometheus"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// defaultAnalysisInterval and defaultAnalysisCount apply when the spec leaves them unset, matching the CRD defaults.
+const defaultAnalysisInterval = time.Minute
+const defaultAnalysisCount = 3
+
+// maxAnalysisRuns bounds the number of runs recorded in status.
+const maxAnalysisRuns = 10
+
+// analysisPlan describes the podinfo image and config to render for the current state of the analysis.
+type analysisPlan struct {
+	// image is the podinfo image to render, the stable one once an update was rolled back.
+	image *myapigroupv1alpha1.Image
+	// config is the podinfo config to render, the stable one once an update was rolled back.
+	config *myapigroupv1alpha1.Config
+	// requeueAfter is the delay after which the next run is due, zero when no update is analysed.
+	requeueAfter time.Duration
+}
+
+// analyzeUpdate evaluates the analysis of the podinfo updates and records its state in status.
+// The first image and config seen once the analysis is enabled are considered stable; later changes are analysed
+// every interval, starting one interval after the change. An update becomes stable after the configured number of
+// successful runs, and is rolled back to the stable image and config once more runs than the failure limit failed.
+// A rolled back update isn't analysed again until the spec changes.
+func (r *MyAppResourceReconciler) analyzeUpdate(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, spec *myapigroupv1alpha1.MyAppResourceSpec, now time.Time) (analysisPlan, error) {
+	analysis := spec.Rollout.Analysis
+	desiredImage := utils.GenerateImageReference(spec.Image)
+	revision := generateAnalysisRevision(desiredImage, spec.Config)
+	status := o.Status.Analysis
+	if status == nil || status.StableImage == "" {
+		status = &myapigroupv1alpha1.AnalysisStatus{StableImage: desiredImage, StableConfig: spec.Config.DeepCopy()}
+	}
+	o.Status.Analysis = status
+	plan := analysisPlan{image: spec.Image, config: spec.Config}
+	rollback := analysisPlan{image: utils.ParseImageReference(status.StableImage, spec.Image), config: status.StableConfig.DeepCopy()}
+
+	switch {
+	case revision == generateAnalysisRevision(status.StableImage, status.StableConfig):
+		if status.Phase != myapigroupv1alpha1.AnalysisPhaseHealthy {
+			status.Phase = myapigroupv1alpha1.AnalysisPhaseHealthy
+			status.Revision, status.StartedAt = "", nil
+		}
+		status.StableImage, status.StableConfig = desiredImage, spec.Config.DeepCopy()
+		return plan, nil
+	case status.Phase == myapigroupv1alpha1.AnalysisPhaseFailed && status.Revision == revision:
+		return rollback, nil
+	case status.Phase != myapigroupv1alpha1.AnalysisPhaseRunning || status.Revision != revision || status.StartedAt == nil:
+		status.Phase = myapigroupv1alpha1.AnalysisPhaseRunning
+		status.Revision = revision
+		status.StartedAt = &metav1.Time{Time: now}
+		status.SuccessfulRuns, status.FailedRuns = 0, 0
+		recordEvent(ctx, corev1.EventTypeNormal, reasonAnalysisStarted, "analysing %s", revision)
+	}
+
+	interval := defaultAnalysisInterval
+	if analysis.Interval != nil && analysis.Interval.Duration > 0 {
+		interval = analysis.Interval.Duration
+	}
+	count := int32(defaultAnalysisCount)
+	if analysis.Count != nil {
+		count = *analysis.Count
+	}
+
+	lastRun := status.StartedAt.Time
+	if len(status.Runs) > 0 && status.Runs[len(status.Runs)-1].Revision == revision && status.Runs[len(status.Runs)-1].Time.After(lastRun) {
+		lastRun = status.Runs[len(status.Runs)-1].Time.Time
+	}
+	if remaining := lastRun.Add(interval).Sub(now); remaining > 0 {
+		status.Message = fmt.Sprintf("%d of %d runs successful, next run in %s", status.SuccessfulRuns, count, remaining.Round(time.Second))
+		plan.requeueAfter = remaining
+		return plan, nil
+	}
+
+	address := analysis.PrometheusAddress
+	if address == "" {
+		address = r.PrometheusAddress
+	} else if !slices.Contains(r.PrometheusAllowedAddresses, strings.TrimSuffix(address, "/")) {
+		// the operator only sends requests to the addresses it is configured with, not to any address of the spec
+		status.Message = fmt.Sprintf("Prometheus address %s isn't allowed by the operator", address)
+		plan.requeueAfter = interval
+		return plan, fmt.Errorf("failed to analyse %s: Prometheus address %s isn't allowed by the operator", revision, address)
+	}
+	if address == "" {
+		status.Message = "no Prometheus address set"
+		plan.requeueAfter = interval
+		return plan, fmt.Errorf("failed to analyse %s: no Prometheus address set in the spec nor the operator", revision)
+	}
+
+	run := r.runAnalysis(ctx, address, o, analysis.Metrics, revision, now)
+	status.Runs = append(status.Runs, run)
+	if len(status.Runs) > maxAnalysisRuns {
+		status.Runs = status.Runs[len(status.Runs)-maxAnalysisRuns:]
+	}
+
+	if run.Phase != myapigroupv1alpha1.AnalysisRunPhaseSuccessful {
+		status.FailedRuns++
+		recordEvent(ctx, corev1.EventTypeWarning, reasonAnalysisRunFailed, "analysis run %d of %s failed", status.SuccessfulRuns+status.FailedRuns, revision)
+	} else {
+		status.SuccessfulRuns++
+	}
+
+	switch {
+	case status.FailedRuns > analysis.FailureLimit:
+		status.Phase = myapigroupv1alpha1.AnalysisPhaseFailed
+		status.Message = fmt.Sprintf("rolled back to %s: %d runs failed", status.StableImage, status.FailedRuns)
+		recordEvent(ctx, corev1.EventTypeWarning, reasonAnalysisRolledBack, "%s failed the analysis, rolled back to %s", revision, status.StableImage)
+		return rollback, nil
+	case status.SuccessfulRuns >= count:
+		status.Phase = myapigroupv1alpha1.AnalysisPhaseHealthy
+		status.StableImage, status.StableConfig = desiredImage, spec.Config.DeepCopy()
+		status.Revision, status.StartedAt = "", nil
+		status.Message = fmt.Sprintf("%s passed the analysis", revision)
+		recordEvent(ctx, corev1.EventTypeNormal, reasonAnalysisPassed, "%s passed the analysis", revision)
+		return plan, nil
+	}
+
+	status.Message = fmt.Sprintf("%d of %d runs successful, %d failed", status.SuccessfulRuns, count, status.FailedRuns)
+	plan.requeueAfter = interval
+	return plan, nil
+}
+
+// runAnalysis evaluates the metrics of the analysis. A metric whose query fails counts as failed.
+func (r *MyAppResourceReconciler) runAnalysis(ctx context.Context, address string, o *myapigroupv1alpha1.MyAppResource, metrics []myapigroupv1alpha1.AnalysisMetric, revision string, now time.Time) myapigroupv1alpha1.AnalysisRun {
+	c := r.Prometheus
+	if c == nil {
+		c = prometheus.NewClient()
+	}
+
+	run := myapigroupv1alpha1.AnalysisRun{Revision: revision, Time: metav1.Time{Time: now}, Phase: myapigroupv1alpha1.AnalysisRunPhaseSuccessful}
+	for _, metric := range metrics {
+		result := myapigroupv1alpha1.AnalysisMetricResult{Name: metric.Name, Phase: myapigroupv1alpha1.AnalysisRunPhaseError}
+		value, err := evaluateAnalysisMetric(ctx, c, address, o, metric)
+		switch {
+		case err != nil:
+			result.Message = err.Error()
+		case compareAnalysisValue(value, metric):
+			result.Phase = myapigroupv1alpha1.AnalysisRunPhaseSuccessful
+			result.Value = strconv.FormatFloat(value, 'g', -1, 64)
+		default:
+			result.Phase = myapigroupv1alpha1.AnalysisRunPhaseFailed
+			result.Value = strconv.FormatFloat(value, 'g', -1, 64)
+		}
+
+		if result.Phase != myapigroupv1alpha1.AnalysisRunPhaseSuccessful {
+			run.Phase = myapigroupv1alpha1.AnalysisRunPhaseFailed
+		}
+		run.Metrics = append(run.Metrics, result)
+	}
+
+	return run
+}
+
+// evaluateAnalysisMetric renders the query of the metric for the instance and evaluates it.
+func evaluateAnalysisMetric(ctx context.Context, c *prometheus.Client, address string, o *myapigroupv1alpha1.MyAppResource, metric myapigroupv1alpha1.AnalysisMetric) (float64, error) {
+	tmpl, err := template.New(metric.Name).Option("missingkey=error").Parse(metric.Query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the query: %w", err)
+	}
+	query := &strings.Builder{}
+	if err := tmpl.Execute(query, map[string]string{"Namespace": o.Namespace, "Name": o.Name}); err != nil {
+		return 0, fmt.Errorf("failed to render the query: %w", err)
+	}
+
+	return c.Query(ctx, address, query.String())
+}
+
+// compareAnalysisValue reports whether the value of the metric satisfies its threshold. NaN never does.
+func compareAnalysisValue(value float64, metric myapigroupv1alpha1.AnalysisMetric) bool {
+	threshold, err := strconv.ParseFloat(metric.Threshold, 64)
+	if err != nil {
+		return false
+	}
+
+	switch metric.Operator {
+	case myapigroupv1alpha1.AnalysisOperatorLessThanOrEqual:
+		return value <= threshold
+	case myapigroupv1alpha1.AnalysisOperatorGreaterThan:
+		return value > threshold
+	case myapigroupv1alpha1.AnalysisOperatorGreaterThanOrEqual:
+		return value >= threshold
+	default:
+		return value < threshold
+	}
+}
+
+// generateAnalysisRevision identifies an image and config, the config by the hash of its rendered file.
+func generateAnalysisRevision(image string, config *myapigroupv1alpha1.Config) string {
+	hash := podinfo.GetConfigHash(config)
+	if hash == "" {
+		return image
+	}
+	return fmt.Sprintf("%s config:%s", image, hash[:12])
+}
@@ -0,0 +1,331 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/prometheus"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// newPrometheus starts a stand-in Prometheus API returning the given value for every query rendered for the
+// whatever instance, and failing the others.
+func newPrometheus(t *testing.T, value string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") != `sum(rate(http_requests_total{namespace="default",job="whatever-podinfo",status=~"5.."}[1m]))` {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unexpected query"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1714564800,%q]}]}}`, value)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAnalyzeUpdate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-2 * time.Minute))
+	recent := metav1.NewTime(now.Add(-30 * time.Second))
+
+	stableConfig := &myapigroupv1alpha1.Config{LogLevel: "info"}
+	desiredConfig := &myapigroupv1alpha1.Config{LogLevel: "debug"}
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		Image:  &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+		Config: desiredConfig,
+		Rollout: &myapigroupv1alpha1.Rollout{
+			Analysis: &myapigroupv1alpha1.Analysis{
+				Interval:     &metav1.Duration{Duration: time.Minute},
+				Count:        utils.Ptr[int32](2),
+				FailureLimit: 1,
+				Metrics: []myapigroupv1alpha1.AnalysisMetric{
+					{
+						Name:      "error-rate",
+						Query:     `sum(rate(http_requests_total{namespace="{{ .Namespace }}",job="{{ .Name }}-podinfo",status=~"5.."}[1m]))`,
+						Operator:  myapigroupv1alpha1.AnalysisOperatorLessThan,
+						Threshold: "0.05",
+					},
+				},
+			},
+		},
+	}
+	stable := "ghcr.io/stefanprodan/podinfo:6.6.0"
+	desired := "ghcr.io/stefanprodan/podinfo:6.6.1"
+	stableRevision := generateAnalysisRevision(stable, stableConfig)
+	revision := generateAnalysisRevision(desired, desiredConfig)
+
+	successfulRun := func(at metav1.Time) myapigroupv1alpha1.AnalysisRun {
+		return myapigroupv1alpha1.AnalysisRun{
+			Revision: revision, Time: at, Phase: myapigroupv1alpha1.AnalysisRunPhaseSuccessful,
+			Metrics: []myapigroupv1alpha1.AnalysisMetricResult{{Name: "error-rate", Phase: myapigroupv1alpha1.AnalysisRunPhaseSuccessful, Value: "0.01"}},
+		}
+	}
+	failedRun := func(at metav1.Time) myapigroupv1alpha1.AnalysisRun {
+		return myapigroupv1alpha1.AnalysisRun{
+			Revision: revision, Time: at, Phase: myapigroupv1alpha1.AnalysisRunPhaseFailed,
+			Metrics: []myapigroupv1alpha1.AnalysisMetricResult{{Name: "error-rate", Phase: myapigroupv1alpha1.AnalysisRunPhaseFailed, Value: "0.5"}},
+		}
+	}
+	running := func(successful int32, failed int32, runs ...myapigroupv1alpha1.AnalysisRun) *myapigroupv1alpha1.AnalysisStatus {
+		return &myapigroupv1alpha1.AnalysisStatus{
+			Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+			Revision: revision, StartedAt: &earlier, SuccessfulRuns: successful, FailedRuns: failed, Runs: runs,
+		}
+	}
+
+	for _, tc := range []struct {
+		name            string
+		argStatus       *myapigroupv1alpha1.AnalysisStatus
+		argValue        string
+		expectedStatus  *myapigroupv1alpha1.AnalysisStatus
+		expectedImage   string
+		expectedConfig  *myapigroupv1alpha1.Config
+		expectedRequeue time.Duration
+	}{
+		{
+			name: "first image and config are stable",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseHealthy, StableImage: desired, StableConfig: desiredConfig,
+			},
+			expectedImage:  desired,
+			expectedConfig: desiredConfig,
+		},
+		{
+			name: "update starts an analysis after an interval",
+			argStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseHealthy, StableImage: stable, StableConfig: stableConfig,
+			},
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &metav1.Time{Time: now}, Message: "0 of 2 runs successful, next run in 1m0s",
+			},
+			expectedImage:   desired,
+			expectedConfig:  desiredConfig,
+			expectedRequeue: time.Minute,
+		},
+		{
+			name:      "successful run is recorded",
+			argStatus: running(0, 0),
+			argValue:  "0.01",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &earlier, SuccessfulRuns: 1, Runs: []myapigroupv1alpha1.AnalysisRun{successfulRun(metav1.NewTime(now))},
+				Message: "1 of 2 runs successful, 0 failed",
+			},
+			expectedImage:   desired,
+			expectedConfig:  desiredConfig,
+			expectedRequeue: time.Minute,
+		},
+		{
+			name:      "runs wait for the interval",
+			argStatus: running(1, 0, successfulRun(recent)),
+			argValue:  "0.01",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &earlier, SuccessfulRuns: 1, Runs: []myapigroupv1alpha1.AnalysisRun{successfulRun(recent)},
+				Message: "1 of 2 runs successful, next run in 30s",
+			},
+			expectedImage:   desired,
+			expectedConfig:  desiredConfig,
+			expectedRequeue: 30 * time.Second,
+		},
+		{
+			name:      "update passes after enough successful runs",
+			argStatus: running(1, 0, successfulRun(earlier)),
+			argValue:  "0.01",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseHealthy, StableImage: desired, StableConfig: desiredConfig,
+				SuccessfulRuns: 2, Runs: []myapigroupv1alpha1.AnalysisRun{successfulRun(earlier), successfulRun(metav1.NewTime(now))},
+				Message: revision + " passed the analysis",
+			},
+			expectedImage:  desired,
+			expectedConfig: desiredConfig,
+		},
+		{
+			name:      "failed run within the failure limit",
+			argStatus: running(0, 0),
+			argValue:  "0.5",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &earlier, FailedRuns: 1, Runs: []myapigroupv1alpha1.AnalysisRun{failedRun(metav1.NewTime(now))},
+				Message: "0 of 2 runs successful, 1 failed",
+			},
+			expectedImage:   desired,
+			expectedConfig:  desiredConfig,
+			expectedRequeue: time.Minute,
+		},
+		{
+			name:      "update is rolled back past the failure limit",
+			argStatus: running(0, 1, failedRun(earlier)),
+			argValue:  "0.5",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseFailed, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &earlier, FailedRuns: 2, Runs: []myapigroupv1alpha1.AnalysisRun{failedRun(earlier), failedRun(metav1.NewTime(now))},
+				Message: "rolled back to " + stable + ": 2 runs failed",
+			},
+			expectedImage:  stable,
+			expectedConfig: stableConfig,
+		},
+		{
+			name: "rolled back update waits for another change",
+			argStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseFailed, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, Message: "rolled back to " + stable + ": 2 runs failed",
+			},
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseFailed, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, Message: "rolled back to " + stable + ": 2 runs failed",
+			},
+			expectedImage:  stable,
+			expectedConfig: stableConfig,
+		},
+		{
+			name: "failing query counts as a failed run",
+			argStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &earlier, FailedRuns: 1,
+			},
+			argValue: "not a number",
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseFailed, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &earlier, FailedRuns: 2,
+				Runs: []myapigroupv1alpha1.AnalysisRun{{
+					Revision: revision, Time: metav1.NewTime(now), Phase: myapigroupv1alpha1.AnalysisRunPhaseFailed,
+					Metrics: []myapigroupv1alpha1.AnalysisMetricResult{{
+						Name: "error-rate", Phase: myapigroupv1alpha1.AnalysisRunPhaseError,
+						Message: `strconv.ParseFloat: parsing "not a number": invalid syntax`,
+					}},
+				}},
+				Message: "rolled back to " + stable + ": 2 runs failed",
+			},
+			expectedImage:  stable,
+			expectedConfig: stableConfig,
+		},
+		{
+			name: "another change restarts the analysis",
+			argStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseFailed, StableImage: stable, StableConfig: stableConfig,
+				Revision: stableRevision + "-previous", FailedRuns: 2,
+			},
+			expectedStatus: &myapigroupv1alpha1.AnalysisStatus{
+				Phase: myapigroupv1alpha1.AnalysisPhaseRunning, StableImage: stable, StableConfig: stableConfig,
+				Revision: revision, StartedAt: &metav1.Time{Time: now}, Message: "0 of 2 runs successful, next run in 1m0s",
+			},
+			expectedImage:   desired,
+			expectedConfig:  desiredConfig,
+			expectedRequeue: time.Minute,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newPrometheus(t, tc.argValue)
+			r := &MyAppResourceReconciler{Prometheus: &prometheus.Client{Client: server.Client()}, PrometheusAddress: server.URL}
+			o := &myapigroupv1alpha1.MyAppResource{
+				ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "default"},
+				Status:     myapigroupv1alpha1.MyAppResourceStatus{Analysis: tc.argStatus},
+			}
+
+			plan, err := r.analyzeUpdate(context.Background(), o, spec.DeepCopy(), now)
+			if err != nil {
+				t.Fatalf("analyzeUpdate: unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedStatus, o.Status.Analysis); diff != "" {
+				t.Errorf("analyzeUpdate: status mismatch (-want +got):\n%s", diff)
+			}
+			if got := utils.GenerateImageReference(plan.image); got != tc.expectedImage {
+				t.Errorf("analyzeUpdate: expected image %q, got %q", tc.expectedImage, got)
+			}
+			if diff := cmp.Diff(tc.expectedConfig, plan.config); diff != "" {
+				t.Errorf("analyzeUpdate: config mismatch (-want +got):\n%s", diff)
+			}
+			if plan.requeueAfter != tc.expectedRequeue {
+				t.Errorf("analyzeUpdate: expected requeue after %s, got %s", tc.expectedRequeue, plan.requeueAfter)
+			}
+		})
+	}
+}
+
+func TestAnalyzeUpdatePrometheusAddress(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	server := newPrometheus(t, "0.01")
+	status := &myapigroupv1alpha1.AnalysisStatus{
+		Phase: myapigroupv1alpha1.AnalysisPhaseHealthy, StableImage: "ghcr.io/stefanprodan/podinfo:6.6.0",
+	}
+
+	for _, tc := range []struct {
+		name             string
+		address          string
+		allowedAddresses []string
+		expectedError    bool
+	}{
+		{name: "operator address", address: ""},
+		{name: "allowed address", address: server.URL + "/", allowedAddresses: []string{server.URL}},
+		{name: "address not allowed", address: server.URL, expectedError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &MyAppResourceReconciler{
+				Prometheus:                 &prometheus.Client{Client: server.Client()},
+				PrometheusAddress:          server.URL,
+				PrometheusAllowedAddresses: tc.allowedAddresses,
+			}
+			o := &myapigroupv1alpha1.MyAppResource{
+				ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "default"},
+				Status:     myapigroupv1alpha1.MyAppResourceStatus{Analysis: status.DeepCopy()},
+			}
+			spec := &myapigroupv1alpha1.MyAppResourceSpec{
+				Image: &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+				Rollout: &myapigroupv1alpha1.Rollout{Analysis: &myapigroupv1alpha1.Analysis{
+					PrometheusAddress: tc.address,
+					Interval:          &metav1.Duration{Duration: time.Minute},
+					Metrics: []myapigroupv1alpha1.AnalysisMetric{{
+						Name:      "error-rate",
+						Query:     `sum(rate(http_requests_total{namespace="{{ .Namespace }}",job="{{ .Name }}-podinfo",status=~"5.."}[1m]))`,
+						Threshold: "0.05",
+					}},
+				}},
+			}
+
+			// the analysis starts one interval after the change
+			if _, err := r.analyzeUpdate(context.Background(), o, spec, now); err != nil {
+				t.Fatalf("analyzeUpdate: unexpected error: %v", err)
+			}
+			_, err := r.analyzeUpdate(context.Background(), o, spec, now.Add(time.Minute))
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("analyzeUpdate: expected error %t, got %v", tc.expectedError, err)
+			}
+			if expectedRuns := map[bool]int{false: 1, true: 0}[tc.expectedError]; len(o.Status.Analysis.Runs) != expectedRuns {
+				t.Errorf("analyzeUpdate: expected %d runs, got %v", expectedRuns, o.Status.Analysis.Runs)
+			}
+		})
+	}
+}
+
+func TestCompareAnalysisValue(t *testing.T) {
+	for _, tc := range []struct {
+		operator myapigroupv1alpha1.AnalysisOperator
+		value    float64
+		expected bool
+	}{
+		{operator: "", value: 0.04, expected: true},
+		{operator: myapigroupv1alpha1.AnalysisOperatorLessThan, value: 0.05, expected: false},
+		{operator: myapigroupv1alpha1.AnalysisOperatorLessThanOrEqual, value: 0.05, expected: true},
+		{operator: myapigroupv1alpha1.AnalysisOperatorGreaterThan, value: 0.05, expected: false},
+		{operator: myapigroupv1alpha1.AnalysisOperatorGreaterThanOrEqual, value: 0.05, expected: true},
+	} {
+		metric := myapigroupv1alpha1.AnalysisMetric{Operator: tc.operator, Threshold: "0.05"}
+		if got := compareAnalysisValue(tc.value, metric); got != tc.expected {
+			t.Errorf("compareAnalysisValue(%v %s 0.05): expected %t, got %t", tc.value, tc.operator, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/redis"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// templateRevisionAnnotation records on the color Deployments the revision of the pod template they were synced with.
+const templateRevisionAnnotation = "my.api.group/template-revision"
+
+// defaultScaleDownDelay applies when the spec leaves it unset, matching the CRD default.
+const defaultScaleDownDelay = 30 * time.Second
+
+// blueGreenPlan describes the podinfo color Deployments to sync for the current state of a blue/green release.
+type blueGreenPlan struct {
+	// activeColor is the color selected by the podinfo Service.
+	activeColor string
+	// previewColor is the color the spec is rolled out to, empty when no preview runs.
+	previewColor string
+	// scaleDown reports whether the color that isn't active is scaled down.
+	scaleDown bool
+	// requeueAfter is the delay after which the release should be checked again, zero when it doesn't progress.
+	requeueAfter time.Duration
+}
+
+// progressBlueGreen moves the blue/green release of the podinfo pod template forward and records its state in status.
+// The first pod template seen once the blue/green release is enabled is rolled out to the blue Deployment, which
+// replaces the podinfo Deployment once its pods are available; later changes are rolled out to the other color
+// while the active one keeps serving. The podinfo Service is switched
+// over once the preview pods are available and, if required, the promote annotation approves the preview revision.
+// The previous color is scaled down after the configured delay.
+func (r *MyAppResourceReconciler) progressBlueGreen(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, spec *myapigroupv1alpha1.MyAppResourceSpec, revision string, now time.Time) (blueGreenPlan, error) {
+	blueGreen := spec.Rollout.BlueGreen
+	status := o.Status.BlueGreen
+	if status == nil || status.ActiveColor == "" {
+		status = &myapigroupv1alpha1.BlueGreenStatus{Phase: myapigroupv1alpha1.BlueGreenPhaseStarting, ActiveColor: podinfo.ColorBlue}
+	}
+	o.Status.BlueGreen = status
+	plan := blueGreenPlan{activeColor: status.ActiveColor, scaleDown: true}
+
+	// the podinfo service keeps selecting the podinfo deployment until the first color is available
+	if status.Phase == myapigroupv1alpha1.BlueGreenPhaseStarting {
+		status.ActiveRevision = revision
+		status.Message = fmt.Sprintf("waiting for the %s pods to be available", status.ActiveColor)
+		plan.requeueAfter = canaryPollInterval
+		condition, synced, err := r.getColorRollout(ctx, o, spec, status.ActiveColor, revision)
+		if err != nil || !synced || condition.Reason != reasonRolloutComplete {
+			if condition.Status == metav1.ConditionTrue {
+				status.Message = fmt.Sprintf("%s pods are degraded: %s", status.ActiveColor, condition.Message)
+			}
+			return plan, err
+		}
+		status.Phase = myapigroupv1alpha1.BlueGreenPhaseActive
+		status.Message = fmt.Sprintf("switched over to %s", status.ActiveColor)
+		recordEvent(ctx, corev1.EventTypeNormal, reasonSwitchedOver, "switched over to %s at revision %s", status.ActiveColor, revision)
+		return blueGreenPlan{activeColor: status.ActiveColor, scaleDown: true}, nil
+	}
+
+	if revision == status.ActiveRevision {
+		status.Phase = myapigroupv1alpha1.BlueGreenPhaseActive
+		status.PreviewColor, status.PreviewRevision = "", ""
+		status.Message = fmt.Sprintf("%s is active", status.ActiveColor)
+		if status.ScaleDownAt != nil {
+			if remaining := status.ScaleDownAt.Sub(now); remaining > 0 {
+				status.Message = fmt.Sprintf("%s is active, scaling %s down in %s", status.ActiveColor, podinfo.OtherColor(status.ActiveColor), remaining.Round(time.Second))
+				plan.scaleDown = false
+				plan.requeueAfter = remaining
+				return plan, nil
+			}
+			status.ScaleDownAt = nil
+			recordEvent(ctx, corev1.EventTypeNormal, reasonScaledDown, "scaled %s down", podinfo.OtherColor(status.ActiveColor))
+		}
+		return plan, nil
+	}
+
+	if status.Phase != myapigroupv1alpha1.BlueGreenPhasePreviewing || status.PreviewRevision != revision {
+		recordEvent(ctx, corev1.EventTypeNormal, reasonPreviewStarted, "rolling out revision %s to %s", revision, podinfo.OtherColor(status.ActiveColor))
+	}
+
+	status.Phase = myapigroupv1alpha1.BlueGreenPhasePreviewing
+	status.PreviewColor = podinfo.OtherColor(status.ActiveColor)
+	status.PreviewRevision = revision
+	status.ScaleDownAt = nil
+	status.Message = fmt.Sprintf("waiting for the %s pods to be available", status.PreviewColor)
+	plan.previewColor = status.PreviewColor
+	plan.scaleDown = false
+	plan.requeueAfter = canaryPollInterval
+
+	condition, synced, err := r.getColorRollout(ctx, o, spec, status.PreviewColor, revision)
+	if err != nil || !synced || condition.Reason != reasonRolloutComplete {
+		if condition.Status == metav1.ConditionTrue {
+			status.Message = fmt.Sprintf("%s pods are degraded: %s", status.PreviewColor, condition.Message)
+		}
+		return plan, err
+	}
+
+	if utils.Deref(blueGreen.RequireApproval) && o.Annotations[podinfo.PromoteAnnotation] != revision {
+		status.Message = fmt.Sprintf("%s pods are available, waiting for %s=%s", status.PreviewColor, podinfo.PromoteAnnotation, revision)
+		plan.requeueAfter = 0
+		return plan, nil
+	}
+
+	delay := defaultScaleDownDelay
+	if blueGreen.ScaleDownDelay != nil {
+		delay = blueGreen.ScaleDownDelay.Duration
+	}
+	*status = myapigroupv1alpha1.BlueGreenStatus{
+		Phase:          myapigroupv1alpha1.BlueGreenPhaseActive,
+		ActiveColor:    status.PreviewColor,
+		ActiveRevision: revision,
+		ScaleDownAt:    &metav1.Time{Time: now.Add(delay)},
+		Message:        fmt.Sprintf("switched over to %s", status.PreviewColor),
+	}
+	recordEvent(ctx, corev1.EventTypeNormal, reasonSwitchedOver, "switched over to %s at revision %s", status.ActiveColor, revision)
+	return blueGreenPlan{activeColor: status.ActiveColor, requeueAfter: delay}, nil
+}
+
+// getColorRollout returns the rollout condition of the color deployment, and whether it is synced with the revision
+// and scaled up, as a color scaled down by a previous release reports a complete rollout until it is scaled back up.
+func (r *MyAppResourceReconciler) getColorRollout(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, spec *myapigroupv1alpha1.MyAppResourceSpec, color string, revision string) (metav1.Condition, bool, error) {
+	local := podinfo.GetColorDeployment(o.Name, o.Namespace, redis.GetServiceAddr(o.Name, o.Namespace), spec, color)
+	deployment := &appsv1.Deployment{}
+	if err := lookupDeployment(r.Client, ctx, local, deployment); err != nil {
+		return metav1.Condition{}, false, fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
+	}
+	if deployment.Name == "" || deployment.Annotations[templateRevisionAnnotation] != revision {
+		return metav1.Condition{}, false, nil
+	}
+	_, condition := generateRolloutStatus(deployment)
+	return condition, deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0, nil
+}
+
+// stopBlueGreen keeps the color deployments of a disabled blue/green release serving until the podinfo deployment
+// replacing them, synced with the given desired state, is available. It returns true once the colors can be removed.
+func (r *MyAppResourceReconciler) stopBlueGreen(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, local *appsv1.Deployment) (bool, error) {
+	status := o.Status.BlueGreen
+	status.Phase = myapigroupv1alpha1.BlueGreenPhaseStopping
+	status.PreviewColor, status.PreviewRevision, status.ScaleDownAt = "", "", nil
+	status.Message = "waiting for the podinfo pods to be available"
+
+	deployment := &appsv1.Deployment{}
+	if err := lookupDeployment(r.Client, ctx, local, deployment); err != nil {
+		return false, fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
+	}
+	if deployment.Name == "" || deployment.Annotations[desiredHashAnnotation] != local.GetAnnotations()[desiredHashAnnotation] {
+		return false, nil
+	}
+	if _, condition := generateRolloutStatus(deployment); condition.Reason != reasonRolloutComplete {
+		if condition.Status == metav1.ConditionTrue {
+			status.Message = fmt.Sprintf("podinfo pods are degraded: %s", condition.Message)
+		}
+		return false, nil
+	}
+	o.Status.BlueGreen = nil
+	return true, nil
+}
+
+// renderPodinfoColorDeployments renders the color deployments to sync for the blue/green plan, the preview or active
+// one first. The color that isn't active is scaled down once the plan allows it, and left untouched otherwise.
+func renderPodinfoColorDeployments(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec, plan blueGreenPlan, revision string) ([]*appsv1.Deployment, error) {
+	var deployments []*appsv1.Deployment
+	var errs error
+	add := func(color string, replicas *int32) {
+		colorSpec := spec.DeepCopy()
+		if replicas != nil {
+			colorSpec.ReplicaCount = replicas
+		}
+		deployment, err := renderPodinfoColorDeployment(name, namespace, colorSpec, color)
+		errs = errors.Join(errs, err)
+		deployment.Annotations = map[string]string{templateRevisionAnnotation: revision}
+		deployments = append(deployments, deployment)
+	}
+
+	if plan.previewColor != "" {
+		add(plan.previewColor, nil)
+		return deployments, errs
+	}
+	add(plan.activeColor, nil)
+	if plan.scaleDown {
+		add(podinfo.OtherColor(plan.activeColor), utils.Ptr[int32](0))
+	}
+	return deployments, errs
+}
+
+// generateTemplateRevision identifies a pod template by the hash of its content.
+func generateTemplateRevision(template *corev1.PodTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+}
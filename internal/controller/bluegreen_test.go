@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func newColorDeployment(revision string, replicas int32, available int32, conditions ...appsv1.DeploymentCondition) *appsv1.Deployment {
+	deployment := newCanaryDeployment("ghcr.io/stefanprodan/podinfo:6.6.1", replicas, available, conditions...)
+	deployment.Name = "whatever-podinfo-green"
+	deployment.Annotations = map[string]string{templateRevisionAnnotation: revision}
+	return deployment
+}
+
+func TestProgressBlueGreen(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := metav1.NewTime(now.Add(time.Minute))
+	earlier := metav1.NewTime(now.Add(-time.Minute))
+
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		ReplicaCount: utils.Ptr[int32](2),
+		Image:        &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+		Rollout: &myapigroupv1alpha1.Rollout{
+			BlueGreen: &myapigroupv1alpha1.BlueGreen{ScaleDownDelay: &metav1.Duration{Duration: 2 * time.Minute}},
+		},
+	}
+	approvalSpec := spec.DeepCopy()
+	approvalSpec.Rollout.BlueGreen.RequireApproval = utils.Ptr(true)
+	active := &myapigroupv1alpha1.BlueGreenStatus{Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa"}
+
+	for _, tc := range []struct {
+		name           string
+		argSpec        *myapigroupv1alpha1.MyAppResourceSpec
+		argStatus      *myapigroupv1alpha1.BlueGreenStatus
+		argAnnotations map[string]string
+		argDeployment  *appsv1.Deployment
+		expectedStatus *myapigroupv1alpha1.BlueGreenStatus
+		expectedPlan   blueGreenPlan
+	}{
+		{
+			name: "first revision is rolled out to blue",
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseStarting, ActiveColor: podinfo.ColorBlue, ActiveRevision: "bbb",
+				Message: "waiting for the blue pods to be available",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, scaleDown: true, requeueAfter: canaryPollInterval},
+		},
+		{
+			name:          "first revision being scaled up isn't ready",
+			argStatus:     &myapigroupv1alpha1.BlueGreenStatus{Phase: myapigroupv1alpha1.BlueGreenPhaseStarting, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa"},
+			argDeployment: newColorDeployment("bbb", 2, 1),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseStarting, ActiveColor: podinfo.ColorBlue, ActiveRevision: "bbb",
+				Message: "waiting for the blue pods to be available",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, scaleDown: true, requeueAfter: canaryPollInterval},
+		},
+		{
+			name:          "available first revision is switched over to",
+			argStatus:     &myapigroupv1alpha1.BlueGreenStatus{Phase: myapigroupv1alpha1.BlueGreenPhaseStarting, ActiveColor: podinfo.ColorBlue, ActiveRevision: "bbb"},
+			argDeployment: newColorDeployment("bbb", 2, 2),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorBlue, ActiveRevision: "bbb", Message: "switched over to blue",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, scaleDown: true},
+		},
+		{
+			name:      "change is previewed on the other color",
+			argStatus: active,
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhasePreviewing, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa",
+				PreviewColor: podinfo.ColorGreen, PreviewRevision: "bbb", Message: "waiting for the green pods to be available",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen, requeueAfter: canaryPollInterval},
+		},
+		{
+			name:          "preview of a previous revision isn't ready",
+			argStatus:     active,
+			argDeployment: newColorDeployment("aaa", 2, 2),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhasePreviewing, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa",
+				PreviewColor: podinfo.ColorGreen, PreviewRevision: "bbb", Message: "waiting for the green pods to be available",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen, requeueAfter: canaryPollInterval},
+		},
+		{
+			name:          "preview being scaled up isn't ready",
+			argStatus:     active,
+			argDeployment: newColorDeployment("bbb", 2, 1),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhasePreviewing, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa",
+				PreviewColor: podinfo.ColorGreen, PreviewRevision: "bbb", Message: "waiting for the green pods to be available",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen, requeueAfter: canaryPollInterval},
+		},
+		{
+			name:      "degraded preview keeps the active color",
+			argStatus: active,
+			argDeployment: newColorDeployment("bbb", 2, 0, appsv1.DeploymentCondition{
+				Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: progressDeadlineExceededReason,
+				Message: "timed out progressing",
+			}),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhasePreviewing, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa",
+				PreviewColor: podinfo.ColorGreen, PreviewRevision: "bbb", Message: "green pods are degraded: timed out progressing",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen, requeueAfter: canaryPollInterval},
+		},
+		{
+			name:          "available preview is switched over to",
+			argStatus:     active,
+			argDeployment: newColorDeployment("bbb", 2, 2),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb",
+				ScaleDownAt: &metav1.Time{Time: now.Add(2 * time.Minute)}, Message: "switched over to green",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorGreen, requeueAfter: 2 * time.Minute},
+		},
+		{
+			name:          "available preview waits for approval",
+			argSpec:       approvalSpec,
+			argStatus:     active,
+			argDeployment: newColorDeployment("bbb", 2, 2),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhasePreviewing, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa",
+				PreviewColor: podinfo.ColorGreen, PreviewRevision: "bbb",
+				Message: "green pods are available, waiting for my.api.group/promote=bbb",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen},
+		},
+		{
+			name:           "stale approval is ignored",
+			argSpec:        approvalSpec,
+			argStatus:      active,
+			argAnnotations: map[string]string{podinfo.PromoteAnnotation: "aaa"},
+			argDeployment:  newColorDeployment("bbb", 2, 2),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhasePreviewing, ActiveColor: podinfo.ColorBlue, ActiveRevision: "aaa",
+				PreviewColor: podinfo.ColorGreen, PreviewRevision: "bbb",
+				Message: "green pods are available, waiting for my.api.group/promote=bbb",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen},
+		},
+		{
+			name:           "approved preview is switched over to",
+			argSpec:        approvalSpec,
+			argStatus:      active,
+			argAnnotations: map[string]string{podinfo.PromoteAnnotation: "bbb"},
+			argDeployment:  newColorDeployment("bbb", 2, 2),
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb",
+				ScaleDownAt: &metav1.Time{Time: now.Add(2 * time.Minute)}, Message: "switched over to green",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorGreen, requeueAfter: 2 * time.Minute},
+		},
+		{
+			name: "previous color keeps running until the scale down delay",
+			argStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb", ScaleDownAt: &later,
+			},
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb", ScaleDownAt: &later,
+				Message: "green is active, scaling blue down in 1m0s",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorGreen, requeueAfter: time.Minute},
+		},
+		{
+			name: "previous color is scaled down after the delay",
+			argStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb", ScaleDownAt: &earlier,
+			},
+			expectedStatus: &myapigroupv1alpha1.BlueGreenStatus{
+				Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb", Message: "green is active",
+			},
+			expectedPlan: blueGreenPlan{activeColor: podinfo.ColorGreen, scaleDown: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			argSpec := spec
+			if tc.argSpec != nil {
+				argSpec = tc.argSpec
+			}
+			r := &MyAppResourceReconciler{Client: &deploymentClient{deployment: tc.argDeployment}}
+			o := &myapigroupv1alpha1.MyAppResource{
+				ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "default", Annotations: tc.argAnnotations},
+				Status:     myapigroupv1alpha1.MyAppResourceStatus{BlueGreen: tc.argStatus.DeepCopy()},
+			}
+
+			plan, err := r.progressBlueGreen(context.Background(), o, argSpec.DeepCopy(), "bbb", now)
+			if err != nil {
+				t.Fatalf("progressBlueGreen: unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedStatus, o.Status.BlueGreen); diff != "" {
+				t.Errorf("progressBlueGreen: status mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedPlan, plan, cmp.AllowUnexported(blueGreenPlan{})); diff != "" {
+				t.Errorf("progressBlueGreen: plan mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStopBlueGreen(t *testing.T) {
+	local := newCanaryDeployment("ghcr.io/stefanprodan/podinfo:6.6.1", 2, 2)
+	local.Name = "whatever-podinfo"
+	local.Annotations = map[string]string{desiredHashAnnotation: "desired"}
+	withHash := func(deployment *appsv1.Deployment, hash string) *appsv1.Deployment {
+		deployment.Name = "whatever-podinfo"
+		deployment.Annotations = map[string]string{desiredHashAnnotation: hash}
+		return deployment
+	}
+	active := &myapigroupv1alpha1.BlueGreenStatus{Phase: myapigroupv1alpha1.BlueGreenPhaseActive, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb"}
+	stopping := &myapigroupv1alpha1.BlueGreenStatus{
+		Phase: myapigroupv1alpha1.BlueGreenPhaseStopping, ActiveColor: podinfo.ColorGreen, ActiveRevision: "bbb",
+		Message: "waiting for the podinfo pods to be available",
+	}
+
+	for _, tc := range []struct {
+		name            string
+		argDeployment   *appsv1.Deployment
+		expectedStopped bool
+		expectedStatus  *myapigroupv1alpha1.BlueGreenStatus
+	}{
+		{name: "missing podinfo deployment", expectedStatus: stopping},
+		{name: "podinfo deployment of a previous state", argDeployment: withHash(newCanaryDeployment("whatever", 2, 2), "previous"), expectedStatus: stopping},
+		{name: "podinfo deployment being scaled up", argDeployment: withHash(newCanaryDeployment("whatever", 2, 1), "desired"), expectedStatus: stopping},
+		{name: "available podinfo deployment", argDeployment: withHash(newCanaryDeployment("whatever", 2, 2), "desired"), expectedStopped: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &MyAppResourceReconciler{Client: &deploymentClient{deployment: tc.argDeployment}}
+			o := &myapigroupv1alpha1.MyAppResource{
+				ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "default"},
+				Status:     myapigroupv1alpha1.MyAppResourceStatus{BlueGreen: active.DeepCopy()},
+			}
+
+			stopped, err := r.stopBlueGreen(context.Background(), o, local)
+			if err != nil {
+				t.Fatalf("stopBlueGreen: unexpected error: %v", err)
+			}
+			if stopped != tc.expectedStopped {
+				t.Errorf("stopBlueGreen: expected stopped %t, got %t", tc.expectedStopped, stopped)
+			}
+			if diff := cmp.Diff(tc.expectedStatus, o.Status.BlueGreen); diff != "" {
+				t.Errorf("stopBlueGreen: status mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderPodinfoColorDeployments(t *testing.T) {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		ReplicaCount: utils.Ptr[int32](2),
+		Image:        &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+		Rollout:      &myapigroupv1alpha1.Rollout{BlueGreen: &myapigroupv1alpha1.BlueGreen{}},
+	}
+
+	for _, tc := range []struct {
+		name             string
+		plan             blueGreenPlan
+		expectedNames    []string
+		expectedReplicas []int32
+	}{
+		{
+			name:             "preview only",
+			plan:             blueGreenPlan{activeColor: podinfo.ColorBlue, previewColor: podinfo.ColorGreen},
+			expectedNames:    []string{"whatever-podinfo-green"},
+			expectedReplicas: []int32{2},
+		},
+		{
+			name:             "active while the previous color keeps running",
+			plan:             blueGreenPlan{activeColor: podinfo.ColorGreen},
+			expectedNames:    []string{"whatever-podinfo-green"},
+			expectedReplicas: []int32{2},
+		},
+		{
+			name:             "active and the scaled down color",
+			plan:             blueGreenPlan{activeColor: podinfo.ColorGreen, scaleDown: true},
+			expectedNames:    []string{"whatever-podinfo-green", "whatever-podinfo-blue"},
+			expectedReplicas: []int32{2, 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			deployments, err := renderPodinfoColorDeployments("whatever", "default", spec, tc.plan, "bbb")
+			if err != nil {
+				t.Fatalf("renderPodinfoColorDeployments: unexpected error: %v", err)
+			}
+
+			var names []string
+			var replicas []int32
+			for _, deployment := range deployments {
+				names = append(names, deployment.Name)
+				replicas = append(replicas, *deployment.Spec.Replicas)
+				if deployment.Annotations[templateRevisionAnnotation] != "bbb" {
+					t.Errorf("renderPodinfoColorDeployments: expected %s to be annotated with revision bbb, got %v", deployment.Name, deployment.Annotations)
+				}
+			}
+			if diff := cmp.Diff(tc.expectedNames, names); diff != "" {
+				t.Errorf("renderPodinfoColorDeployments: names mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedReplicas, replicas); diff != "" {
+				t.Errorf("renderPodinfoColorDeployments: replicas mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/operatorconfig"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/redis"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// canaryPollInterval is the interval the canary Deployment is checked at while a release progresses, as the pauses
+// of the steps elapse without any event and only the readiness transitions of the Deployments are reconciled.
+const canaryPollInterval = 10 * time.Second
+
+// canaryPlan describes the podinfo Deployments to sync for the current state of a canary release.
+type canaryPlan struct {
+	// stableImage is the image of the stable Deployment.
+	stableImage *myapigroupv1alpha1.Image
+	// canaryImage is the image of the canary Deployment, nil when no canary runs.
+	canaryImage *myapigroupv1alpha1.Image
+	// weight is the percentage of the traffic sent to the canary.
+	weight int32
+	// promoted is true while the stable Deployment rolls out the promoted image with all its replicas, the canary
+	// Deployment serving along until the stable pods are available.
+	promoted bool
+	// requeueAfter is the delay after which the release should be checked again, zero when it doesn't progress.
+	requeueAfter time.Duration
+}
+
+// progressCanary moves the canary release of the podinfo image forward and records its state in status.
+// The first image seen once the canary release is enabled is considered stable; later image changes go through
+// the steps of the spec on the canary Deployment. A step is over once the canary pods are available and its pause
+// elapsed, the canary image is promoted to the stable Deployment after the last one, and the canary Deployment is
+// kept until the stable pods are available. The release is aborted when the canary Deployment exceeds its progress
+// deadline.
+func (r *MyAppResourceReconciler) progressCanary(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, spec *myapigroupv1alpha1.MyAppResourceSpec, now time.Time) (canaryPlan, error) {
+	desiredImage := utils.GenerateImageReference(spec.Image)
+	status := o.Status.Canary
+	if status == nil || status.StableImage == "" {
+		status = &myapigroupv1alpha1.CanaryStatus{StableImage: desiredImage}
+	}
+	o.Status.Canary = status
+	plan := canaryPlan{stableImage: utils.ParseImageReference(status.StableImage, spec.Image)}
+
+	switch {
+	case status.Phase == myapigroupv1alpha1.CanaryPhasePromoting && desiredImage == status.StableImage:
+		return r.progressPromotion(ctx, o, spec, status)
+	case desiredImage == status.StableImage:
+		*status = myapigroupv1alpha1.CanaryStatus{Phase: myapigroupv1alpha1.CanaryPhaseStable, StableImage: desiredImage}
+		return plan, nil
+	case status.Phase == myapigroupv1alpha1.CanaryPhaseAborted && status.CanaryImage == desiredImage:
+		return plan, nil
+	case status.Phase != myapigroupv1alpha1.CanaryPhaseProgressing || status.CanaryImage != desiredImage || status.Step == nil:
+		status.Phase = myapigroupv1alpha1.CanaryPhaseProgressing
+		status.CanaryImage = desiredImage
+		status.Step = utils.Ptr[int32](0)
+		status.StepStartedAt = &metav1.Time{Time: now}
+		recordEvent(ctx, corev1.EventTypeNormal, reasonCanaryStarted, "releasing %s through the canary deployment", desiredImage)
+	}
+
+	steps := spec.Rollout.Canary.Steps
+	if int(*status.Step) >= len(steps) {
+		// the steps were shortened during the release.
+		status.Step = utils.Ptr(int32(len(steps) - 1))
+	}
+	step := steps[*status.Step]
+	status.Weight = step.Weight
+	status.Message = fmt.Sprintf("step %d of %d: waiting for the canary pods to be available", *status.Step+1, len(steps))
+	plan.canaryImage = spec.Image
+	plan.weight = step.Weight
+	plan.requeueAfter = canaryPollInterval
+
+	local := podinfo.GetCanaryDeployment(o.Name, o.Namespace, redis.GetServiceAddr(o.Name, o.Namespace), spec)
+	deployment := &appsv1.Deployment{}
+	if err := lookupDeployment(r.Client, ctx, local, deployment); err != nil {
+		return plan, fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
+	}
+	if deployment.Name == "" || len(deployment.Spec.Template.Spec.Containers) == 0 || deployment.Spec.Template.Spec.Containers[0].Image != operatorconfig.MirrorImage(desiredImage) {
+		return plan, nil
+	}
+
+	_, condition := generateRolloutStatus(deployment)
+	if condition.Status == metav1.ConditionTrue {
+		status.Phase = myapigroupv1alpha1.CanaryPhaseAborted
+		status.Step, status.Weight, status.StepStartedAt = nil, 0, nil
+		status.Message = fmt.Sprintf("aborted: %s", condition.Message)
+		recordEvent(ctx, corev1.EventTypeWarning, reasonCanaryAborted, "aborted the canary release of %s: %s", desiredImage, condition.Message)
+		return canaryPlan{stableImage: plan.stableImage}, nil
+	}
+
+	_, canaryReplicas := podinfo.GetCanaryReplicas(spec, step.Weight)
+	if condition.Reason != reasonRolloutComplete || deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != canaryReplicas {
+		return plan, nil
+	}
+
+	if step.Pause != nil {
+		if remaining := status.StepStartedAt.Add(step.Pause.Duration).Sub(now); remaining > 0 {
+			status.Message = fmt.Sprintf("step %d of %d: paused for %s", *status.Step+1, len(steps), remaining.Round(time.Second))
+			if remaining < plan.requeueAfter {
+				plan.requeueAfter = remaining
+			}
+			return plan, nil
+		}
+	}
+
+	if int(*status.Step) == len(steps)-1 {
+		*status = myapigroupv1alpha1.CanaryStatus{
+			Phase:       myapigroupv1alpha1.CanaryPhasePromoting,
+			StableImage: desiredImage,
+			CanaryImage: desiredImage,
+			Weight:      step.Weight,
+		}
+		return r.progressPromotion(ctx, o, spec, status)
+	}
+
+	recordEvent(ctx, corev1.EventTypeNormal, reasonCanaryStepCompleted, "step %d of %d of the canary release of %s completed", *status.Step+1, len(steps), desiredImage)
+	status.Step = utils.Ptr(*status.Step + 1)
+	status.StepStartedAt = &metav1.Time{Time: now}
+	status.Weight = steps[*status.Step].Weight
+	status.Message = fmt.Sprintf("step %d of %d: waiting for the canary pods to be available", *status.Step+1, len(steps))
+	plan.weight = status.Weight
+	return plan, nil
+}
+
+// progressPromotion completes the promotion of the canary image once the stable Deployment rolled it out to all its
+// replicas. Until then the canary Deployment keeps its replicas and its share of the traffic, so that the capacity
+// doesn't drop while the stable pods are replaced.
+func (r *MyAppResourceReconciler) progressPromotion(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, spec *myapigroupv1alpha1.MyAppResourceSpec, status *myapigroupv1alpha1.CanaryStatus) (canaryPlan, error) {
+	plan := canaryPlan{
+		stableImage:  spec.Image,
+		canaryImage:  spec.Image,
+		weight:       status.Weight,
+		promoted:     true,
+		requeueAfter: canaryPollInterval,
+	}
+	status.Message = fmt.Sprintf("promoting %s: waiting for the stable pods to be available", status.StableImage)
+
+	local := podinfo.GetDeployment(o.Name, o.Namespace, redis.GetServiceAddr(o.Name, o.Namespace), spec)
+	deployment := &appsv1.Deployment{}
+	if err := lookupDeployment(r.Client, ctx, local, deployment); err != nil {
+		return plan, fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
+	}
+	if deployment.Name == "" || len(deployment.Spec.Template.Spec.Containers) == 0 || deployment.Spec.Template.Spec.Containers[0].Image != operatorconfig.MirrorImage(status.StableImage) {
+		return plan, nil
+	}
+	replicas := int32(1)
+	if spec.ReplicaCount != nil {
+		replicas = *spec.ReplicaCount
+	}
+	if _, condition := generateRolloutStatus(deployment); condition.Reason != reasonRolloutComplete || deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != replicas {
+		return plan, nil
+	}
+
+	*status = myapigroupv1alpha1.CanaryStatus{
+		Phase:       myapigroupv1alpha1.CanaryPhaseStable,
+		StableImage: status.StableImage,
+		Message:     fmt.Sprintf("promoted %s", status.StableImage),
+	}
+	recordEvent(ctx, corev1.EventTypeNormal, reasonCanaryPromoted, "promoted %s", status.StableImage)
+	return canaryPlan{stableImage: spec.Image}, nil
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// deploymentClient serves a single deployment, or none, to the reconciler, whatever its name.
+type deploymentClient struct {
+	client.Client
+	deployment *appsv1.Deployment
+}
+
+func (c *deploymentClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if c.deployment == nil {
+		return apierrors.NewNotFound(appsv1.Resource("deployments"), key.Name)
+	}
+	c.deployment.DeepCopyInto(obj.(*appsv1.Deployment))
+	return nil
+}
+
+func newCanaryDeployment(image string, replicas int32, available int32, conditions ...appsv1.DeploymentCondition) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "whatever-podinfo-canary", Generation: 1},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: utils.Ptr(replicas),
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: image}}}},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			ReadyReplicas:      available,
+			AvailableReplicas:  available,
+			Conditions:         conditions,
+		},
+	}
+}
+
+func TestProgressCanary(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	started := metav1.NewTime(now.Add(-time.Minute))
+
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		ReplicaCount: utils.Ptr[int32](4),
+		Image:        &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+		Rollout: &myapigroupv1alpha1.Rollout{
+			Canary: &myapigroupv1alpha1.Canary{
+				Steps: []myapigroupv1alpha1.CanaryStep{
+					{Weight: 25, Pause: &metav1.Duration{Duration: 5 * time.Minute}},
+					{Weight: 50},
+				},
+			},
+		},
+	}
+	stable := "ghcr.io/stefanprodan/podinfo:6.6.0"
+	desired := "ghcr.io/stefanprodan/podinfo:6.6.1"
+
+	for _, tc := range []struct {
+		name               string
+		argStatus          *myapigroupv1alpha1.CanaryStatus
+		argDeployment      *appsv1.Deployment
+		expectedStatus     *myapigroupv1alpha1.CanaryStatus
+		expectedStable     string
+		expectedCanary     string
+		expectedWeight     int32
+		expectedRequeueMax time.Duration
+	}{
+		{
+			name:           "first image is stable",
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{Phase: myapigroupv1alpha1.CanaryPhaseStable, StableImage: desired},
+			expectedStable: desired,
+		},
+		{
+			name:      "image change starts a release",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{Phase: myapigroupv1alpha1.CanaryPhaseStable, StableImage: stable},
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](0), Weight: 25, StepStartedAt: &metav1.Time{Time: now},
+				Message: "step 1 of 2: waiting for the canary pods to be available",
+			},
+			expectedStable:     stable,
+			expectedCanary:     desired,
+			expectedWeight:     25,
+			expectedRequeueMax: canaryPollInterval,
+		},
+		{
+			name: "available canary pauses",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](0), StepStartedAt: &started,
+			},
+			argDeployment: newCanaryDeployment(desired, 1, 1),
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](0), Weight: 25, StepStartedAt: &started,
+				Message: "step 1 of 2: paused for 4m0s",
+			},
+			expectedStable:     stable,
+			expectedCanary:     desired,
+			expectedWeight:     25,
+			expectedRequeueMax: canaryPollInterval,
+		},
+		{
+			name: "available canary moves to the next step once paused",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](0), StepStartedAt: &metav1.Time{Time: now.Add(-10 * time.Minute)},
+			},
+			argDeployment: newCanaryDeployment(desired, 1, 1),
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](1), Weight: 50, StepStartedAt: &metav1.Time{Time: now},
+				Message: "step 2 of 2: waiting for the canary pods to be available",
+			},
+			expectedStable:     stable,
+			expectedCanary:     desired,
+			expectedWeight:     50,
+			expectedRequeueMax: canaryPollInterval,
+		},
+		{
+			name: "available canary is promoted after the last step",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](1), StepStartedAt: &started,
+			},
+			argDeployment: newCanaryDeployment(desired, 2, 2),
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhasePromoting, StableImage: desired, CanaryImage: desired, Weight: 50,
+				Message: "promoting " + desired + ": waiting for the stable pods to be available",
+			},
+			expectedStable:     desired,
+			expectedCanary:     desired,
+			expectedWeight:     50,
+			expectedRequeueMax: canaryPollInterval,
+		},
+		{
+			name: "promoted canary serves until the stable pods are available",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhasePromoting, StableImage: desired, CanaryImage: desired, Weight: 50,
+			},
+			argDeployment: newCanaryDeployment(desired, 4, 2),
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhasePromoting, StableImage: desired, CanaryImage: desired, Weight: 50,
+				Message: "promoting " + desired + ": waiting for the stable pods to be available",
+			},
+			expectedStable:     desired,
+			expectedCanary:     desired,
+			expectedWeight:     50,
+			expectedRequeueMax: canaryPollInterval,
+		},
+		{
+			name: "promoted canary is removed once the stable pods are available",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhasePromoting, StableImage: desired, CanaryImage: desired, Weight: 50,
+			},
+			argDeployment: newCanaryDeployment(desired, 4, 4),
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseStable, StableImage: desired, Message: "promoted " + desired,
+			},
+			expectedStable: desired,
+		},
+		{
+			name: "canary exceeding its progress deadline is aborted",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseProgressing, StableImage: stable, CanaryImage: desired,
+				Step: utils.Ptr[int32](0), StepStartedAt: &started,
+			},
+			argDeployment: newCanaryDeployment(desired, 1, 0, appsv1.DeploymentCondition{
+				Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: progressDeadlineExceededReason,
+				Message: "timed out progressing",
+			}),
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseAborted, StableImage: stable, CanaryImage: desired,
+				Message: "aborted: timed out progressing",
+			},
+			expectedStable: stable,
+		},
+		{
+			name: "aborted release waits for another image",
+			argStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseAborted, StableImage: stable, CanaryImage: desired,
+				Message: "aborted: timed out progressing",
+			},
+			expectedStatus: &myapigroupv1alpha1.CanaryStatus{
+				Phase: myapigroupv1alpha1.CanaryPhaseAborted, StableImage: stable, CanaryImage: desired,
+				Message: "aborted: timed out progressing",
+			},
+			expectedStable: stable,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &MyAppResourceReconciler{Client: &deploymentClient{deployment: tc.argDeployment}}
+			o := &myapigroupv1alpha1.MyAppResource{
+				ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "default"},
+				Status:     myapigroupv1alpha1.MyAppResourceStatus{Canary: tc.argStatus},
+			}
+
+			plan, err := r.progressCanary(context.Background(), o, spec.DeepCopy(), now)
+			if err != nil {
+				t.Fatalf("progressCanary: unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expectedStatus, o.Status.Canary); diff != "" {
+				t.Errorf("progressCanary: status mismatch (-want +got):\n%s", diff)
+			}
+			if got := utils.GenerateImageReference(plan.stableImage); got != tc.expectedStable {
+				t.Errorf("progressCanary: expected stable image %q, got %q", tc.expectedStable, got)
+			}
+			canaryImage := ""
+			if plan.canaryImage != nil {
+				canaryImage = utils.GenerateImageReference(plan.canaryImage)
+			}
+			if canaryImage != tc.expectedCanary {
+				t.Errorf("progressCanary: expected canary image %q, got %q", tc.expectedCanary, canaryImage)
+			}
+			if plan.weight != tc.expectedWeight {
+				t.Errorf("progressCanary: expected weight %d, got %d", tc.expectedWeight, plan.weight)
+			}
+			if promoted := tc.expectedStatus.Phase == myapigroupv1alpha1.CanaryPhasePromoting; plan.promoted != promoted {
+				t.Errorf("progressCanary: expected promoted %t, got %t", promoted, plan.promoted)
+			}
+			if plan.requeueAfter > tc.expectedRequeueMax || (tc.expectedRequeueMax > 0 && plan.requeueAfter == 0) {
+				t.Errorf("progressCanary: expected a requeue within %s, got %s", tc.expectedRequeueMax, plan.requeueAfter)
+			}
+		})
+	}
+}
@@ -3,26 +3,93 @@ package controller
 import (
 	"context"
 	"errors"
+	"reflect"
 
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-func cleanK8sObjects(k8sClient client.Client, ctx context.Context, objectsToClean []client.Object) error {
+// absentVersion is recorded in observedObjects for the uncached objects a cleanup found absent.
+const absentVersion = "absent"
+
+// cleanK8sObjects deletes the objects of an instance. The objects are read first, and only the ones managed by the
+// instance are deleted, so that an existing object of the same name created outside of the operator is left untouched.
+// The deletion is conditioned on the UID read, in case the object is replaced in between.
+//
+// The unstructured objects, such as the Prometheus Operator and Gateway API ones, aren't cached and are read from the
+// API server. The ones found absent are recorded, so that the next cleanups skip them without any API call until
+// they are synced again or forgotten.
+func cleanK8sObjects(k8sClient client.Client, ctx context.Context, instance string, objectsToClean []client.Object) error {
 	var errs error
 	logger := log.FromContext(ctx)
+	ctx, span := tracer.Start(ctx, "cleanK8sObjects")
 
 	for _, resource := range objectsToClean {
+		_, uncached := resource.(*unstructured.Unstructured)
+		key := getObservedKey(resource)
+		if version, found := observedObjects.Load(key); uncached && found && version == absentVersion {
+			continue
+		}
+		observedObjects.Delete(key)
+
 		logger.Info("deleting resource", "name", resource.GetName(), "namespace", resource.GetNamespace())
-		if err := k8sClient.Delete(ctx, resource); err != nil {
-			if apierrors.IsNotFound(err) {
-				logger.Info("resource not found for deletion")
+		outcome := "deleted"
+		remote := newEmptyObject(resource)
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(resource), remote)
+		if err == nil {
+			if isManagedBy(remote, instance) {
+				uid := remote.GetUID()
+				err = k8sClient.Delete(ctx, remote, client.Preconditions{UID: &uid})
 			} else {
-				errs = errors.Join(errs, err)
+				outcome = "unmanaged"
 			}
 		}
+		switch {
+		case outcome == "unmanaged":
+			logger.Info("resource not managed by the instance, skipping deletion")
+		case apierrors.IsNotFound(err):
+			logger.Info("resource not found for deletion")
+			outcome = "notfound"
+			if uncached {
+				observedObjects.Store(key, absentVersion)
+			}
+		case meta.IsNoMatchError(err):
+			// the kind isn't served by the cluster (e.g. Prometheus Operator CRDs aren't installed)
+			logger.Info("resource kind not found for deletion")
+			outcome = "nomatch"
+			if uncached {
+				observedObjects.Store(key, absentVersion)
+			}
+		case err != nil:
+			recordEvent(ctx, corev1.EventTypeWarning, reasonCleanupFailed, "failed to delete %s %s: %s", getObjectKind(resource), resource.GetName(), err)
+			errs = errors.Join(errs, err)
+			outcome = "failed"
+		default:
+			recordEvent(ctx, corev1.EventTypeNormal, reasonDeleted, "deleted %s %s", getObjectKind(resource), resource.GetName())
+		}
+		span.AddEvent("delete", trace.WithAttributes(
+			attributeNamespace.String(resource.GetNamespace()),
+			attributeName.String(resource.GetName()),
+			attributeKind.String(getObjectKind(resource)),
+			attributeOutcome.String(outcome),
+		))
 	}
 
+	endSpan(span, outcomeOf(errs), errs)
 	return errs
 }
+
+// newEmptyObject returns an empty object of the kind of the given one to read the remote object into.
+func newEmptyObject(object client.Object) client.Object {
+	if u, ok := object.(*unstructured.Unstructured); ok {
+		out := &unstructured.Unstructured{}
+		out.SetGroupVersionKind(u.GroupVersionKind())
+		return out
+	}
+	return reflect.New(reflect.TypeOf(object).Elem()).Interface().(client.Object)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
+)
+
+// absentClient serves no object and counts the reads.
+type absentClient struct {
+	client.Client
+	gets int
+}
+
+func (c *absentClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.gets++
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func TestCleanK8sObjectsAbsent(t *testing.T) {
+	c := &absentClient{}
+	objects := []client.Object{
+		podinfo.GetAllMonitors("whatever", "cleaner")[0],
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "whatever-podinfo", Namespace: "cleaner"}},
+	}
+	defer forgetObjects(objects)
+
+	for _, tc := range []struct {
+		name         string
+		before       func()
+		expectedGets int
+	}{
+		{name: "first cleanup", expectedGets: 2},
+		// the uncached monitor found absent is skipped, the cached service is still read
+		{name: "next cleanup", expectedGets: 1},
+		{name: "after a rebalance", before: forgetAbsentObjects, expectedGets: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.before != nil {
+				tc.before()
+			}
+			c.gets = 0
+			if err := cleanK8sObjects(c, context.Background(), "whatever", objects); err != nil {
+				t.Fatalf("cleanK8sObjects: unexpected error: %v", err)
+			}
+			if c.gets != tc.expectedGets {
+				t.Errorf("cleanK8sObjects: expected %d reads, got %d", tc.expectedGets, c.gets)
+			}
+		})
+	}
+}
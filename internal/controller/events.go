@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The reasons of the events recorded on the instances.
+const (
+	reasonCreated             = "Created"
+	reasonUpdated             = "Updated"
+	reasonDeleted             = "Deleted"
+	reasonCleanupFailed       = "CleanupFailed"
+	reasonNotManaged          = "NotManaged"
+	reasonInvalidSpec         = "InvalidSpec"
+	reasonSpecRolledBack      = "SpecRolledBack"
+	reasonCanaryStarted       = "CanaryStarted"
+	reasonCanaryStepCompleted = "CanaryStepCompleted"
+	reasonCanaryPromoted      = "CanaryPromoted"
+	reasonCanaryAborted       = "CanaryAborted"
+	reasonAnalysisStarted     = "AnalysisStarted"
+	reasonAnalysisRunFailed   = "AnalysisRunFailed"
+	reasonAnalysisPassed      = "AnalysisPassed"
+	reasonAnalysisRolledBack  = "AnalysisRolledBack"
+	reasonPreviewStarted      = "PreviewStarted"
+	reasonSwitchedOver        = "SwitchedOver"
+	reasonScaledDown          = "ScaledDown"
+	reasonRolloutDegraded     = "RolloutDegraded"
+)
+
+// maxDiffSummaryFields bounds the number of changed fields listed in the summary of an update.
+const maxDiffSummaryFields = 5
+
+type eventRecorderKey struct{}
+
+// eventRecorder records the events of the instance being reconciled.
+type eventRecorder struct {
+	recorder record.EventRecorder
+	object   runtime.Object
+}
+
+// withEventRecorder returns a context recording the events of the syncers, the cleaner and the release steps on the
+// object. The recorder is the one of the manager, whose broadcaster aggregates the repeated identical events into a
+// single event with a count, so the messages mustn't carry values changing on every reconcile.
+func withEventRecorder(ctx context.Context, recorder record.EventRecorder, object runtime.Object) context.Context {
+	if recorder == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, eventRecorderKey{}, eventRecorder{recorder: recorder, object: object})
+}
+
+// recordEvent records an event on the object of the context, if any.
+func recordEvent(ctx context.Context, eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r, ok := ctx.Value(eventRecorderKey{}).(eventRecorder); ok {
+		r.recorder.Eventf(r.object, eventType, reason, messageFmt, args...)
+	}
+}
+
+// getObjectKind returns the kind of the object, falling back to its type name as typed objects usually have no
+// type meta set.
+func getObjectKind(object client.Object) string {
+	if kind := object.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.Indirect(reflect.ValueOf(object)).Type().Name()
+}
+
+// summarizeDiff lists the fields changed by a diff generated by kmp.SafeDiff, or counts its changed lines when
+// no field name could be found.
+func summarizeDiff(diff string) string {
+	var fields []string
+	seen := map[string]bool{}
+	changed := 0
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "+") {
+			continue
+		}
+		changed++
+		// the diff randomly pads the lines with spaces or non-breaking spaces
+		field, _, found := strings.Cut(strings.TrimLeftFunc(line[1:], unicode.IsSpace), ":")
+		field = strings.Trim(field, `"`)
+		if !found || field == "" || strings.IndexFunc(field, unicode.IsSpace) >= 0 || strings.ContainsAny(field, "{}(),") || seen[field] {
+			continue
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+
+	switch {
+	case len(fields) == 0:
+		return fmt.Sprintf("%d lines changed", changed)
+	case len(fields) > maxDiffSummaryFields:
+		return fmt.Sprintf("changed %s and %d more", strings.Join(fields[:maxDiffSummaryFields], ", "), len(fields)-maxDiffSummaryFields)
+	}
+	return fmt.Sprintf("changed %s", strings.Join(fields, ", "))
+}
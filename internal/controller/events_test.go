@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/kmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// deleteClient fails the deletion of the objects with an error set. The objects are read as managed by the operator,
+// except the unmanaged ones.
+type deleteClient struct {
+	client.Client
+	errs      map[string]error
+	unmanaged map[string]bool
+	deleted   []string
+}
+
+func (c *deleteClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.errs[key.Name]; apierrors.IsNotFound(err) {
+		return err
+	}
+	obj.SetName(key.Name)
+	if !c.unmanaged[key.Name] {
+		obj.SetLabels(map[string]string{managedByLabel: managedByLabelValue})
+	}
+	return nil
+}
+
+func (c *deleteClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.deleted = append(c.deleted, obj.GetName())
+	return c.errs[obj.GetName()]
+}
+
+// drainEvents returns the events recorded so far.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRecordEvent(t *testing.T) {
+	// a context without recorder, as in the unit tests and when the instance is deleted, records nothing
+	recordEvent(withEventRecorder(context.Background(), nil, &myapigroupv1alpha1.MyAppResource{}), corev1.EventTypeNormal, reasonCreated, "created")
+
+	recorder := record.NewFakeRecorder(10)
+	ctx := withEventRecorder(context.Background(), recorder, &myapigroupv1alpha1.MyAppResource{})
+	recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "invalid %s", "patch")
+	if diff := cmp.Diff([]string{"Warning InvalidSpec invalid patch"}, drainEvents(recorder)); diff != "" {
+		t.Errorf("recordEvent: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCleanK8sObjectsEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ctx := withEventRecorder(context.Background(), recorder, &myapigroupv1alpha1.MyAppResource{})
+	c := &deleteClient{
+		errs: map[string]error{
+			"missing":   apierrors.NewNotFound(schema.GroupResource{Resource: "services"}, "missing"),
+			"forbidden": apierrors.NewForbidden(schema.GroupResource{Resource: "services"}, "forbidden", errors.New("denied")),
+		},
+		unmanaged: map[string]bool{"default": true},
+	}
+
+	err := cleanK8sObjects(c, ctx, "whatever", []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "deleted"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "missing"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "forbidden"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	})
+	if err == nil {
+		t.Errorf("cleanK8sObjects: expected the forbidden deletion to fail")
+	}
+	// the objects not managed by the operator are left untouched
+	if diff := cmp.Diff([]string{"deleted", "forbidden"}, c.deleted); diff != "" {
+		t.Errorf("cleanK8sObjects: deleted objects mismatch (-want +got):\n%s", diff)
+	}
+	expected := []string{
+		"Normal Deleted deleted Deployment deleted",
+		`Warning CleanupFailed failed to delete Service forbidden: services "forbidden" is forbidden: denied`,
+	}
+	if diff := cmp.Diff(expected, drainEvents(recorder)); diff != "" {
+		t.Errorf("cleanK8sObjects: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSummarizeDiff(t *testing.T) {
+	newSpec := func(replicas int32, image string, paused bool) appsv1.DeploymentSpec {
+		return appsv1.DeploymentSpec{
+			Replicas: utils.Ptr(replicas),
+			Paused:   paused,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "podinfo", Image: image}}}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		remote   interface{}
+		local    interface{}
+		expected string
+	}{
+		{
+			name:     "replicas",
+			remote:   newSpec(1, "podinfo:1", false),
+			local:    newSpec(2, "podinfo:1", false),
+			expected: "changed Replicas",
+		},
+		{
+			name:     "replicas, image and paused",
+			remote:   newSpec(1, "podinfo:1", false),
+			local:    newSpec(2, "podinfo:2", true),
+			expected: "changed Replicas, Image, Paused",
+		},
+		{
+			name:     "no field name",
+			remote:   []string{"a"},
+			local:    []string{"b"},
+			expected: "2 lines changed",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := kmp.SafeDiff(tc.remote, tc.local)
+			if err != nil {
+				t.Fatalf("SafeDiff: unexpected error: %v", err)
+			}
+			if got := summarizeDiff(diff); got != tc.expected {
+				t.Errorf("summarizeDiff: expected %q, got %q for the diff:\n%s", tc.expected, got, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/kmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+)
+
+// specHistoryLimit bounds the number of spec revisions kept for an instance.
+const specHistoryLimit = 10
+
+// maxSpecDiffSize bounds the size of the diff recorded on a revision, as annotations are limited in size.
+const maxSpecDiffSize = 32 << 10
+
+// instanceLabel selects the spec revisions of an instance.
+const instanceLabel = "my.api.group/instance"
+
+// The annotations of the spec revisions.
+const (
+	specAppliedAtAnnotation = "my.api.group/applied-at"
+	specDiffAnnotation      = "my.api.group/spec-diff"
+)
+
+// rollbackToAnnotation restores the spec recorded by the revision it is set to.
+const rollbackToAnnotation = "my.api.group/rollback-to"
+
+// maxSpecRevisionNameLength is the maximum length of the name of a spec revision, a DNS subdomain.
+const maxSpecRevisionNameLength = 253
+
+// getSpecRevisionName returns the name of the revision of the encoded spec of an instance, the instance name followed
+// by the first 10 hex digits of the hash of the spec. The instance name is truncated so that the name stays a valid
+// DNS subdomain.
+func getSpecRevisionName(instance string, data []byte) string {
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))[:10]
+	if len(instance) > maxSpecRevisionNameLength-len(hash)-1 {
+		instance = strings.TrimRight(instance[:maxSpecRevisionNameLength-len(hash)-1], "-.")
+	}
+	return instance + "-" + hash
+}
+
+// recordSpecRevision records the spec of the instance in its history of ControllerRevisions, owned by the instance,
+// along with the time it was applied at and its diff with the previous revision. A spec matching an older revision
+// renumbers that revision instead of recording it twice, and the oldest revisions are pruned past the history limit.
+func (r *MyAppResourceReconciler) recordSpecRevision(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, now time.Time) error {
+	revisions, err := r.listSpecRevisions(ctx, o)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(o.Spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode the spec: %w", err)
+	}
+	name := getSpecRevisionName(o.Name, data)
+
+	next := int64(1)
+	var previous *appsv1.ControllerRevision
+	if len(revisions) > 0 {
+		previous = &revisions[len(revisions)-1]
+		next = previous.Revision + 1
+	}
+	if previous != nil && previous.Name == name {
+		o.Status.SpecRevision = previous.Revision
+		return nil
+	}
+
+	diff, err := generateSpecDiff(previous, &o.Spec)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{specAppliedAtAnnotation: now.UTC().Format(time.RFC3339), specDiffAnnotation: diff}
+
+	recorded := false
+	for i := range revisions {
+		if revisions[i].Name != name {
+			continue
+		}
+		revision := &revisions[i]
+		revision.Revision = next
+		revision.Annotations = annotations
+		if err := r.Client.Update(ctx, revision); err != nil {
+			return fmt.Errorf("failed to update controllerrevision %s: %w", name, err)
+		}
+		recorded = true
+	}
+	if !recorded {
+		revision := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       o.Namespace,
+				Labels:          map[string]string{instanceLabel: o.Name},
+				Annotations:     annotations,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(o, myapigroupv1alpha1.GroupVersion.WithKind("MyAppResource"))},
+			},
+			Data:     runtime.RawExtension{Raw: data},
+			Revision: next,
+		}
+		if err := r.Client.Create(ctx, revision); err != nil {
+			return fmt.Errorf("failed to create controllerrevision %s: %w", name, err)
+		}
+		revisions = append(revisions, *revision)
+	}
+	o.Status.SpecRevision = next
+
+	// revisions are sorted by number, except for the renumbered one which is now the latest
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	for i := 0; i < len(revisions)-specHistoryLimit; i++ {
+		if err := r.Client.Delete(ctx, &revisions[i]); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete controllerrevision %s: %w", revisions[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// rollbackSpec restores the spec recorded by the revision the rollback-to annotation is set to, and removes the
+// annotation. It returns true once the instance was updated, the update triggering another reconcile.
+// The annotation is left in place when the revision can't be restored, so the error is reported until it is fixed.
+func (r *MyAppResourceReconciler) rollbackSpec(ctx context.Context, o *myapigroupv1alpha1.MyAppResource) (bool, error) {
+	value, found := o.Annotations[rollbackToAnnotation]
+	if !found {
+		return false, nil
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid revision %q", rollbackToAnnotation, value)
+	}
+
+	revisions, err := r.listSpecRevisions(ctx, o)
+	if err != nil {
+		return false, err
+	}
+	for _, revision := range revisions {
+		if revision.Revision != number {
+			continue
+		}
+		spec := myapigroupv1alpha1.MyAppResourceSpec{}
+		if err := json.Unmarshal(revision.Data.Raw, &spec); err != nil {
+			return false, fmt.Errorf("%s: failed to decode revision %d: %w", rollbackToAnnotation, number, err)
+		}
+		o.Spec = spec
+		delete(o.Annotations, rollbackToAnnotation)
+		if err := r.Client.Update(ctx, o); err != nil {
+			return false, fmt.Errorf("%s: failed to restore revision %d: %w", rollbackToAnnotation, number, err)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("%s: revision %d not found in the spec history", rollbackToAnnotation, number)
+}
+
+// listSpecRevisions lists the spec revisions of the instance, sorted by revision number.
+func (r *MyAppResourceReconciler) listSpecRevisions(ctx context.Context, o *myapigroupv1alpha1.MyAppResource) ([]appsv1.ControllerRevision, error) {
+	revisions := appsv1.ControllerRevisionList{}
+	if err := r.Client.List(ctx, &revisions, client.InNamespace(o.Namespace), client.MatchingLabels{instanceLabel: o.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list controllerrevisions: %w", err)
+	}
+
+	sort.Slice(revisions.Items, func(i, j int) bool { return revisions.Items[i].Revision < revisions.Items[j].Revision })
+	return revisions.Items, nil
+}
+
+// generateSpecDiff generates the diff between the spec recorded by the previous revision and the spec,
+// truncated to the maximum diff size.
+func generateSpecDiff(previous *appsv1.ControllerRevision, spec *myapigroupv1alpha1.MyAppResourceSpec) (string, error) {
+	previousSpec := myapigroupv1alpha1.MyAppResourceSpec{}
+	if previous != nil {
+		if err := json.Unmarshal(previous.Data.Raw, &previousSpec); err != nil {
+			return "", fmt.Errorf("failed to decode controllerrevision %s: %w", previous.Name, err)
+		}
+	}
+
+	diff, err := kmp.SafeDiff(previousSpec, *spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff specs: %w", err)
+	}
+	if len(diff) > maxSpecDiffSize {
+		diff = diff[:maxSpecDiffSize] + "\n(truncated)"
+	}
+	return diff, nil
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// revisionClient keeps the controllerrevisions in memory, and records the last updated instance.
+type revisionClient struct {
+	client.Client
+	revisions map[string]*appsv1.ControllerRevision
+	updated   *myapigroupv1alpha1.MyAppResource
+}
+
+func (c *revisionClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	revisions := list.(*appsv1.ControllerRevisionList)
+	for _, revision := range c.revisions {
+		revisions.Items = append(revisions.Items, *revision.DeepCopy())
+	}
+	return nil
+}
+
+func (c *revisionClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.revisions[obj.GetName()] = obj.(*appsv1.ControllerRevision).DeepCopy()
+	return nil
+}
+
+func (c *revisionClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	switch o := obj.(type) {
+	case *appsv1.ControllerRevision:
+		c.revisions[o.Name] = o.DeepCopy()
+	case *myapigroupv1alpha1.MyAppResource:
+		c.updated = o.DeepCopy()
+	}
+	return nil
+}
+
+func (c *revisionClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	delete(c.revisions, obj.GetName())
+	return nil
+}
+
+// numbers returns the sorted numbers of the recorded revisions.
+func (c *revisionClient) numbers() []int64 {
+	var numbers []int64
+	for _, revision := range c.revisions {
+		numbers = append(numbers, revision.Revision)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
+func newHistoryInstance(replicas int32) *myapigroupv1alpha1.MyAppResource {
+	return &myapigroupv1alpha1.MyAppResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "default", UID: "uid"},
+		Spec:       myapigroupv1alpha1.MyAppResourceSpec{ReplicaCount: utils.Ptr(replicas)},
+	}
+}
+
+func TestRecordSpecRevision(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &revisionClient{revisions: map[string]*appsv1.ControllerRevision{}}
+	r := &MyAppResourceReconciler{Client: c}
+
+	record := func(replicas int32) *myapigroupv1alpha1.MyAppResource {
+		o := newHistoryInstance(replicas)
+		if err := r.recordSpecRevision(context.Background(), o, now); err != nil {
+			t.Fatalf("recordSpecRevision: unexpected error: %v", err)
+		}
+		return o
+	}
+
+	o := record(1)
+	if o.Status.SpecRevision != 1 || len(c.revisions) != 1 {
+		t.Fatalf("recordSpecRevision: expected a first revision, got revision %d and %d revisions", o.Status.SpecRevision, len(c.revisions))
+	}
+	for _, revision := range c.revisions {
+		if revision.Labels[instanceLabel] != "whatever" || len(revision.OwnerReferences) != 1 || revision.OwnerReferences[0].UID != "uid" {
+			t.Errorf("recordSpecRevision: expected the revision to be labelled and owned by the instance, got %v", revision.ObjectMeta)
+		}
+		if revision.Annotations[specAppliedAtAnnotation] != "2024-05-01T12:00:00Z" {
+			t.Errorf("recordSpecRevision: expected the applied time to be recorded, got %v", revision.Annotations)
+		}
+	}
+
+	if o := record(1); o.Status.SpecRevision != 1 || len(c.revisions) != 1 {
+		t.Errorf("recordSpecRevision: expected an unchanged spec not to be recorded, got revision %d and %d revisions", o.Status.SpecRevision, len(c.revisions))
+	}
+
+	o = record(2)
+	if o.Status.SpecRevision != 2 {
+		t.Errorf("recordSpecRevision: expected revision 2, got %d", o.Status.SpecRevision)
+	}
+	for _, revision := range c.revisions {
+		if revision.Revision == 2 && !strings.Contains(revision.Annotations[specDiffAnnotation], "ReplicaCount") {
+			t.Errorf("recordSpecRevision: expected the diff to mention the replica count, got %q", revision.Annotations[specDiffAnnotation])
+		}
+	}
+
+	if o := record(1); o.Status.SpecRevision != 3 || len(c.revisions) != 2 {
+		t.Errorf("recordSpecRevision: expected a previous spec to be renumbered, got revision %d and %d revisions", o.Status.SpecRevision, len(c.revisions))
+	}
+
+	for replicas := int32(3); replicas < 3+specHistoryLimit; replicas++ {
+		record(replicas)
+	}
+	expected := []int64{4, 5, 6, 7, 8, 9, 10, 11, 12, 13}
+	if diff := cmp.Diff(expected, c.numbers()); diff != "" {
+		t.Errorf("recordSpecRevision: expected the oldest revisions to be pruned (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetSpecRevisionName(t *testing.T) {
+	long := strings.Repeat("a", 240)
+	for _, tc := range []struct {
+		name     string
+		instance string
+		expected string
+	}{
+		{name: "short name", instance: "whatever", expected: "whatever-"},
+		{name: "longest name", instance: long + "aa", expected: long + "aa-"},
+		{name: "truncated name", instance: long + strings.Repeat("b", 13), expected: long + "bb-"},
+		{name: "truncated at a separator", instance: long + "a.b-" + strings.Repeat("c", 10), expected: long + "a-"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			name := getSpecRevisionName(tc.instance, []byte(`{"replicaCount":1}`))
+			if !strings.HasPrefix(name, tc.expected) || len(name) != len(tc.expected)+10 {
+				t.Errorf("getSpecRevisionName: expected %s followed by the hash, got %s", tc.expected, name)
+			}
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+				t.Errorf("getSpecRevisionName: invalid name %s: %v", name, errs)
+			}
+		})
+	}
+}
+
+func TestRollbackSpec(t *testing.T) {
+	c := &revisionClient{revisions: map[string]*appsv1.ControllerRevision{}}
+	r := &MyAppResourceReconciler{Client: c}
+	for _, replicas := range []int32{1, 2} {
+		if err := r.recordSpecRevision(context.Background(), newHistoryInstance(replicas), time.Now()); err != nil {
+			t.Fatalf("recordSpecRevision: unexpected error: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name             string
+		annotations      map[string]string
+		expectedUpdated  bool
+		expectedErr      bool
+		expectedReplicas int32
+	}{
+		{name: "no annotation", expectedReplicas: 3},
+		{name: "known revision", annotations: map[string]string{rollbackToAnnotation: "1", "keep": "me"}, expectedUpdated: true, expectedReplicas: 1},
+		{name: "unknown revision", annotations: map[string]string{rollbackToAnnotation: "7"}, expectedErr: true, expectedReplicas: 3},
+		{name: "invalid revision", annotations: map[string]string{rollbackToAnnotation: "latest"}, expectedErr: true, expectedReplicas: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c.updated = nil
+			o := newHistoryInstance(3)
+			o.Annotations = tc.annotations
+
+			updated, err := r.rollbackSpec(context.Background(), o)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("rollbackSpec: expected error %t, got %v", tc.expectedErr, err)
+			}
+			if updated != tc.expectedUpdated || (c.updated != nil) != tc.expectedUpdated {
+				t.Fatalf("rollbackSpec: expected updated %t, got %t", tc.expectedUpdated, updated)
+			}
+			if *o.Spec.ReplicaCount != tc.expectedReplicas {
+				t.Errorf("rollbackSpec: expected %d replicas, got %d", tc.expectedReplicas, *o.Spec.ReplicaCount)
+			}
+			if updated {
+				if diff := cmp.Diff(map[string]string{"keep": "me"}, c.updated.Annotations); diff != "" {
+					t.Errorf("rollbackSpec: expected the rollback-to annotation to be removed (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
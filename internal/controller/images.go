@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/registry"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// resolveImageDigest pins the image to a digest when digest resolution is requested.
+// The digest recorded in status is reused as long as the repository and tag are unchanged, so that
+// rollouts are reproducible even if the tag is moved; otherwise the tag is resolved against the registry.
+// It returns the pinned image reference to record in status.
+func (r *MyAppResourceReconciler) resolveImageDigest(ctx context.Context, namespace string, image *myapigroupv1alpha1.Image, recorded string) (string, error) {
+	if image == nil || !utils.Deref(image.ResolveDigest) || image.Digest != "" || image.Tag == "" {
+		return "", nil
+	}
+
+	prefix := fmt.Sprintf("%s:%s@", image.Repository, image.Tag)
+	if strings.HasPrefix(recorded, prefix) {
+		image.Digest = strings.TrimPrefix(recorded, prefix)
+		return recorded, nil
+	}
+
+	// the secrets are read from the API server, caching them would watch every secret of the cluster
+	reader := r.getAPIReader()
+	var secrets []corev1.Secret
+	for _, ref := range image.PullSecrets {
+		secret := corev1.Secret{}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+			return recorded, fmt.Errorf("failed to get image pull secret %s: %w", ref.Name, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	credentials, err := registry.CredentialsFromSecrets(secrets)
+	if err != nil {
+		return recorded, err
+	}
+
+	resolver := r.ImageResolver
+	if resolver == nil {
+		resolver = registry.NewResolver(nil)
+	}
+	digest, err := resolver.Resolve(ctx, image.Repository, image.Tag, credentials)
+	if err != nil {
+		return recorded, fmt.Errorf("failed to resolve the digest of %s:%s: %w", image.Repository, image.Tag, err)
+	}
+
+	image.Digest = digest
+	return utils.GenerateImageReference(image), nil
+}
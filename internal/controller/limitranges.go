@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aa-ang4335/myappresource-operator/internal/limitrange"
+)
+
+// validateLimitRanges checks the pod templates of the workloads, their init containers and their pod totals included,
+// against the LimitRanges of the namespace, so that requirements the API server would reject surface in status instead
+// of as a stuck rollout. Every rendered workload is checked, the canary and color deployments included, as their
+// templates may differ from the podinfo deployment one.
+func (r *MyAppResourceReconciler) validateLimitRanges(ctx context.Context, namespace string, workloads ...client.Object) error {
+	limitRanges := corev1.LimitRangeList{}
+	listed := false
+
+	var errs error
+	for _, workload := range workloads {
+		var podSpec corev1.PodSpec
+		switch o := workload.(type) {
+		case *appsv1.Deployment:
+			podSpec = o.Spec.Template.Spec
+		case *appsv1.StatefulSet:
+			podSpec = o.Spec.Template.Spec
+		}
+		if len(podSpec.Containers) == 0 {
+			continue
+		}
+
+		if !listed {
+			if err := r.Client.List(ctx, &limitRanges, client.InNamespace(namespace)); err != nil {
+				return fmt.Errorf("failed to list limitranges: %w", err)
+			}
+			listed = true
+		}
+		if err := limitrange.ValidatePod(podSpec, limitRanges.Items); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("pods of %s %s are not admitted: %w", strings.ToLower(getObjectKind(workload)), workload.GetName(), err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// limitRangeClient lists the LimitRanges it holds.
+type limitRangeClient struct {
+	client.Client
+	limitRanges []corev1.LimitRange
+}
+
+func (c *limitRangeClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	list.(*corev1.LimitRangeList).Items = c.limitRanges
+	return nil
+}
+
+func TestValidateLimitRanges(t *testing.T) {
+	r := &MyAppResourceReconciler{Client: &limitRangeClient{limitRanges: []corev1.LimitRange{{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+			Type: corev1.LimitTypeContainer,
+			Max:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+		}}},
+	}}}}
+	podSpec := func(memory string) corev1.PodSpec {
+		return corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "main",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)}},
+		}}}
+	}
+	deployment := func(name string, memory string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: podSpec(memory)}},
+		}
+	}
+	statefulSet := func(name string, memory string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: podSpec(memory)}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		workloads []client.Object
+		expected  string
+	}{
+		{
+			name:      "admitted workloads",
+			workloads: []client.Object{deployment("whatever-podinfo", "256Mi"), statefulSet("whatever-redis", "256Mi")},
+		},
+		{
+			name:      "canary deployment",
+			workloads: []client.Object{deployment("whatever-podinfo", "256Mi"), deployment("whatever-podinfo-canary", "1Gi")},
+			expected:  "pods of deployment whatever-podinfo-canary are not admitted",
+		},
+		{
+			name:      "redis statefulset",
+			workloads: []client.Object{statefulSet("whatever-redis", "1Gi")},
+			expected:  "pods of statefulset whatever-redis are not admitted",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.validateLimitRanges(context.Background(), "default", tc.workloads...)
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("validateLimitRanges: unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+				t.Errorf("validateLimitRanges: expected an error starting with %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/metrics"
+)
+
+// observedObjects keeps the version of each managed object observed by its last sync, so that an object read from
+// the cache is known to be unchanged since. It is kept in memory only, the objects are checked with a dry-run update
+// on their first sync after a restart. The versions of the objects are forgotten once they are deleted.
+var observedObjects sync.Map
+
+// syncOutcome records the outcome of the sync of a managed object in the metrics and in the span of the sync.
+// The outcome is failed until the sync reports another one.
+type syncOutcome struct {
+	kind    string
+	key     string
+	hash    string
+	outcome string
+	span    trace.Span
+}
+
+// newSyncOutcome starts recording the sync of the rendered object, hashing its desired state, and returns the context
+// of its span. It must be called before the object is sent to the API server, which fills in the defaults.
+func newSyncOutcome(ctx context.Context, operation string, local client.Object) (context.Context, *syncOutcome) {
+	kind := getObjectKind(local)
+	ctx, span := startSpan(ctx, operation, local)
+	return ctx, &syncOutcome{
+		kind:    kind,
+		key:     getObservedKey(local),
+		hash:    generateObjectHash(local),
+		outcome: metrics.SyncOutcomeFailed,
+		span:    span,
+	}
+}
+
+func (s *syncOutcome) created(object client.Object) {
+	s.outcome = metrics.SyncOutcomeCreated
+	observedObjects.Store(s.key, getObjectVersion(object))
+}
+
+// updated records an update, which corrected a drift if the desired state of the object didn't change.
+func (s *syncOutcome) updated(object client.Object, drift bool) {
+	s.outcome = metrics.SyncOutcomeUpdated
+	observedObjects.Store(s.key, getObjectVersion(object))
+	if drift {
+		metrics.DriftCorrectionsTotal.WithLabelValues(s.kind).Inc()
+	}
+}
+
+func (s *syncOutcome) unchanged(object client.Object) {
+	s.outcome = metrics.SyncOutcomeUnchanged
+	observedObjects.Store(s.key, getObjectVersion(object))
+}
+
+// observed returns true if the version of the object was observed by the last sync.
+func (s *syncOutcome) observed(object client.Object) bool {
+	version, found := observedObjects.Load(s.key)
+	return found && version == getObjectVersion(object)
+}
+
+// done counts the sync and ends its span, it is deferred by the syncers.
+func (s *syncOutcome) done(err error) {
+	metrics.SyncTotal.WithLabelValues(s.kind, s.outcome).Inc()
+	endSpan(s.span, s.outcome, err)
+}
+
+// forgetObjects forgets the versions observed by the syncs of the objects, once they are deleted or left to another
+// replica.
+func forgetObjects(objects []client.Object) {
+	for _, object := range objects {
+		observedObjects.Delete(getObservedKey(object))
+	}
+}
+
+// forgetAbsentObjects forgets the objects found absent by the cleanups, which another replica may have created while
+// their instance was on its shard.
+func forgetAbsentObjects() {
+	observedObjects.Range(func(key, version any) bool {
+		if version == absentVersion {
+			observedObjects.Delete(key)
+		}
+		return true
+	})
+}
+
+// getObservedKey returns the key of the version of the object in observedObjects.
+func getObservedKey(object client.Object) string {
+	return getObjectKind(object) + "/" + client.ObjectKeyFromObject(object).String()
+}
+
+// getObjectVersion returns the generation of the object, which only changes with its desired state, or its resource
+// version for the kinds without generation.
+func getObjectVersion(object client.Object) string {
+	if generation := object.GetGeneration(); generation > 0 {
+		return fmt.Sprintf("generation/%d", generation)
+	}
+	return "resourceVersion/" + object.GetResourceVersion()
+}
+
+// isInstanceReady returns whether the instance is ready, as reported by the Instances gauge: it was synced
+// successfully, its podinfo rollout isn't degraded and all the desired podinfo replicas are available, and all the
+// replicas of the Redis statefulset, when it is enabled, are ready. A nil statefulset means Redis is disabled.
+func isInstanceReady(o *myapigroupv1alpha1.MyAppResource, desiredReplicas int32, redisStatefulSet *appsv1.StatefulSet) bool {
+	if !o.Status.Valid || meta.IsStatusConditionTrue(o.Status.Conditions, myapigroupv1alpha1.ConditionTypeDegraded) {
+		return false
+	}
+	if o.Status.Rollout == nil || o.Status.Rollout.AvailableReplicas < desiredReplicas {
+		return false
+	}
+	return redisStatefulSet == nil || (redisStatefulSet.Name != "" && getReadiness(redisStatefulSet) == "Ready")
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/metrics"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func TestSyncOutcome(t *testing.T) {
+	read := func(kind string, outcome string) (float64, float64) {
+		sync, drift := &dto.Metric{}, &dto.Metric{}
+		if err := metrics.SyncTotal.WithLabelValues(kind, outcome).Write(sync); err != nil {
+			t.Fatalf("Write: unexpected error: %v", err)
+		}
+		if err := metrics.DriftCorrectionsTotal.WithLabelValues(kind).Write(drift); err != nil {
+			t.Fatalf("Write: unexpected error: %v", err)
+		}
+		return sync.GetCounter().GetValue(), drift.GetCounter().GetValue()
+	}
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "whatever-podinfo", Namespace: "sync-outcome", ResourceVersion: value},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	for _, tc := range []struct {
+		name             string
+		value            string
+		record           func(*syncOutcome, client.Object)
+		err              error
+		expectedOutcome  string
+		expectedDrift    float64
+		expectedObserved bool
+	}{
+		{name: "failed", value: "a", record: func(*syncOutcome, client.Object) {}, err: errors.New("failed"), expectedOutcome: metrics.SyncOutcomeFailed},
+		{name: "created", value: "a", record: (*syncOutcome).created, expectedOutcome: metrics.SyncOutcomeCreated, expectedObserved: true},
+		{name: "unchanged", value: "a", record: (*syncOutcome).unchanged, expectedOutcome: metrics.SyncOutcomeUnchanged, expectedObserved: true},
+		{
+			name:             "updated after the desired state changed",
+			value:            "b",
+			record:           func(s *syncOutcome, object client.Object) { s.updated(object, false) },
+			expectedOutcome:  metrics.SyncOutcomeUpdated,
+			expectedObserved: true,
+		},
+		{
+			name:             "updated after the object drifted",
+			value:            "b",
+			record:           func(s *syncOutcome, object client.Object) { s.updated(object, true) },
+			expectedOutcome:  metrics.SyncOutcomeUpdated,
+			expectedDrift:    1,
+			expectedObserved: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configMap := newConfigMap(tc.value)
+			forgetObjects([]client.Object{configMap})
+			syncs, drifts := read("ConfigMap", tc.expectedOutcome)
+			_, outcome := newSyncOutcome(context.Background(), "syncK8sConfigMap", configMap)
+			tc.record(outcome, configMap)
+			outcome.done(tc.err)
+
+			gotSyncs, gotDrifts := read("ConfigMap", tc.expectedOutcome)
+			if gotSyncs-syncs != 1 {
+				t.Errorf("syncOutcome: expected a %s sync to be counted, got %v", tc.expectedOutcome, gotSyncs-syncs)
+			}
+			if gotDrifts-drifts != tc.expectedDrift {
+				t.Errorf("syncOutcome: expected %v drift corrections, got %v", tc.expectedDrift, gotDrifts-drifts)
+			}
+			if got := outcome.observed(configMap); got != tc.expectedObserved {
+				t.Errorf("syncOutcome: expected the version to be observed %t, got %t", tc.expectedObserved, got)
+			}
+
+			// the version is forgotten once the object is deleted
+			forgetObjects([]client.Object{configMap})
+			if outcome.observed(configMap) {
+				t.Errorf("forgetObjects: expected the version to be forgotten")
+			}
+		})
+	}
+}
+
+func TestIsInstanceReady(t *testing.T) {
+	newInstance := func(valid bool, available int32, degraded metav1.ConditionStatus) *myapigroupv1alpha1.MyAppResource {
+		return &myapigroupv1alpha1.MyAppResource{Status: myapigroupv1alpha1.MyAppResourceStatus{
+			Valid:      valid,
+			Rollout:    &myapigroupv1alpha1.RolloutStatus{AvailableReplicas: available},
+			Conditions: []metav1.Condition{{Type: myapigroupv1alpha1.ConditionTypeDegraded, Status: degraded}},
+		}}
+	}
+	newStatefulSet := func(ready int32) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "whatever-redis"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: utils.Ptr[int32](1)},
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: ready},
+		}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		instance    *myapigroupv1alpha1.MyAppResource
+		statefulSet *appsv1.StatefulSet
+		expected    bool
+	}{
+		{name: "available", instance: newInstance(true, 2, metav1.ConditionFalse), expected: true},
+		{name: "sync failed", instance: newInstance(false, 2, metav1.ConditionFalse)},
+		{name: "replicas unavailable", instance: newInstance(true, 1, metav1.ConditionFalse)},
+		{name: "rollout degraded", instance: newInstance(true, 2, metav1.ConditionTrue)},
+		{name: "rollout unknown", instance: &myapigroupv1alpha1.MyAppResource{Status: myapigroupv1alpha1.MyAppResourceStatus{Valid: true}}},
+		{name: "redis ready", instance: newInstance(true, 2, metav1.ConditionFalse), statefulSet: newStatefulSet(1), expected: true},
+		{name: "redis not ready", instance: newInstance(true, 2, metav1.ConditionFalse), statefulSet: newStatefulSet(0)},
+		{name: "redis missing", instance: newInstance(true, 2, metav1.ConditionFalse), statefulSet: &appsv1.StatefulSet{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ready := isInstanceReady(tc.instance, 2, tc.statefulSet); ready != tc.expected {
+				t.Errorf("isInstanceReady: expected %t, got %t", tc.expected, ready)
+			}
+		})
+	}
+}
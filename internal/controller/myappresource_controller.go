@@ -3,21 +3,40 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"errors"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/metrics"
+	"github.com/aa-ang4335/myappresource-operator/internal/operatorconfig"
+	"github.com/aa-ang4335/myappresource-operator/internal/profile"
+	"github.com/aa-ang4335/myappresource-operator/internal/prometheus"
+	"github.com/aa-ang4335/myappresource-operator/internal/registry"
+	"github.com/aa-ang4335/myappresource-operator/internal/scope"
 	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
 	"github.com/aa-ang4335/myappresource-operator/internal/service/redis"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/serviceaccount"
+	"github.com/aa-ang4335/myappresource-operator/internal/sharding"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
 )
 
 const controllerName = "controller.MyAppResource"
@@ -26,15 +45,77 @@ const controllerName = "controller.MyAppResource"
 type MyAppResourceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIReader reads the objects left out of the cache of the manager, such as the image pull Secrets, from the API
+	// server. It defaults to the client.
+	APIReader client.Reader
+
+	// ImageResolver resolves image tags to digests for the images requesting it.
+	ImageResolver *registry.Resolver
+
+	// Prometheus evaluates the queries of the rollout analyses.
+	Prometheus *prometheus.Client
+
+	// PrometheusAddress is the address of the Prometheus API used by the analyses not setting their own.
+	PrometheusAddress string
+
+	// PrometheusAllowedAddresses are the addresses of the Prometheus APIs the analyses may set, without trailing
+	// slash. The analyses setting any other address fail.
+	PrometheusAllowedAddresses []string
+
+	// Recorder records the events of the instances. It defaults to the recorder of the manager.
+	Recorder record.EventRecorder
+
+	// Options configures the concurrency, the rate limiting and the periodic resync of the reconciles. The zero
+	// value falls back to the defaults of controller-runtime.
+	Options Options
+
+	// Shards assigns the instances to the replicas when they are sharded, every instance is reconciled when nil.
+	Shards *sharding.Sharder
+
+	// Config reloads the operator configuration, the instances it affects are reconciled once it changes.
+	Config *operatorconfig.Watcher
 }
 
 //+kubebuilder:rbac:groups=my.api.group,resources=myappresources,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=my.api.group,resources=myappresources/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=my.api.group,resources=myappresources/finalizers,verbs=update
+//+kubebuilder:rbac:groups=my.api.group,resources=myappprofiles,verbs=get;list;watch
 
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=limitranges,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;podmonitors;prometheusrules,verbs=get;list;watch;create;update;patch;delete
+
+// RequiredPermissions returns the permissions granted by the RBAC markers above that the operator can't run without,
+// which are checked in each watched namespace on startup. The permissions on the Gateway API and Prometheus Operator
+// resources are left out, as they are only needed by the instances using them.
+func RequiredPermissions() []scope.Permission {
+	var permissions []scope.Permission
+	add := func(group string, resources []string, verbs ...string) {
+		for _, resource := range resources {
+			for _, verb := range verbs {
+				permissions = append(permissions, scope.Permission{Group: group, Resource: resource, Verb: verb})
+			}
+		}
+	}
+	add(myapigroupv1alpha1.GroupVersion.Group, []string{"myappresources"}, "get", "list", "watch", "update")
+	add(myapigroupv1alpha1.GroupVersion.Group, []string{"myappresources/status"}, "update")
+	add(myapigroupv1alpha1.GroupVersion.Group, []string{"myappprofiles"}, "get", "list", "watch")
+	add("apps", []string{"deployments", "statefulsets", "controllerrevisions"}, "list", "watch", "create", "update", "delete")
+	add("", []string{"services", "configmaps", "serviceaccounts"}, "list", "watch", "create", "update", "delete")
+	add("", []string{"secrets"}, "get")
+	add("", []string{"limitranges"}, "list", "watch")
+	add("", []string{"events"}, "create")
+	return permissions
+}
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -46,41 +127,232 @@ type MyAppResourceReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.17.0/pkg/reconcile
 func (r *MyAppResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// the reconcile is the root span of the syncs, lookups and cleanups it does
+	ctx, span := tracer.Start(ctx, "Reconcile", trace.WithNewRoot(), trace.WithAttributes(
+		attributeNamespace.String(req.Namespace),
+		attributeName.String(req.Name),
+		attributeKind.String("MyAppResource"),
+	))
+	result, err := r.reconcile(ctx, req)
+	endSpan(span, outcomeOf(err), err)
+	return result, err
+}
+
+// reconcile syncs the managed objects of the instance with its spec and reports their state in its status.
+func (r *MyAppResourceReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	o := &myapigroupv1alpha1.MyAppResource{}
 
 	if err := r.Client.Get(ctx, req.NamespacedName, o); err != nil {
 		if apierrors.IsNotFound(err) {
+			// the shard label of a deleted instance is gone, so the replica owning its name cleans it up. The
+			// objects of the other replicas are still deleted by the garbage collector through their owner references.
+			if r.Shards != nil && !r.Shards.Owns(&metav1.ObjectMeta{Namespace: req.Namespace, Name: req.Name}) {
+				forgetObjects(getAllManagedObjects(req.Name, req.Namespace, &o.Spec))
+				metrics.DeleteInstance(req.Namespace, req.Name)
+				return ctrl.Result{}, nil
+			}
 
 			// MyAppResource object not found. attempt to clean up any existing managed objects
-			if err := cleanK8sObjects(r.Client, ctx, getAllManagedObjects(req.Name, req.Namespace, &o.Spec)); err != nil {
+			managedObjects := getAllManagedObjects(req.Name, req.Namespace, &o.Spec)
+			if err := cleanK8sObjects(r.Client, ctx, req.Name, managedObjects); err != nil {
 				return ctrl.Result{}, fmt.Errorf("failed to cleanup all managed objects: %w", err)
 			}
+			forgetObjects(managedObjects)
+			metrics.DeleteInstance(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "failed to get myappresource object", "name", o.Name)
 		return ctrl.Result{}, err
 	}
 	logger = logger.WithValues("namespace", o.Namespace, "name", o.Name, "controller", controllerName)
+
+	// leaves the instances of the other replicas to them
+	if r.Shards != nil {
+		if !r.Shards.Owns(o) {
+			forgetObjects(getAllManagedObjects(o.Name, o.Namespace, &o.Spec))
+			metrics.DeleteInstance(o.Namespace, o.Name)
+			return ctrl.Result{}, nil
+		}
+		o.Status.Shard = r.Shards.ID()
+	}
+	ctx = withEventRecorder(ctx, r.Recorder, o)
+	ctx = withAPIReader(ctx, r.getAPIReader())
+
+	// leaves the managed objects as they are while the instance is paused
+	if updatePausedCondition(o) {
+		logger.Info("instance is paused, skipping the sync")
+		if err := r.Status().Update(ctx, o); err != nil {
+			logger.Error(err, "failed to update the resource's status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// assume that status is always invalid
 	o.Status.Valid = false
 
 	var errs error
+	// restores a recorded spec when the rollback-to annotation is set. the update triggers another reconcile applying it.
+	rollbackTo := o.Annotations[rollbackToAnnotation]
+	if rolledBack, err := r.rollbackSpec(ctx, o); err != nil {
+		logger.Error(err, "failed to rollback the spec")
+		errs = errors.Join(errs, err)
+	} else if rolledBack {
+		logger.Info("rolled back the spec", "revision", rollbackTo)
+		recordEvent(ctx, corev1.EventTypeNormal, reasonSpecRolledBack, "rolled back the spec to revision %s", rollbackTo)
+		return ctrl.Result{}, nil
+	}
+
+	// merges the spec over the profile it references. nothing is synced until the profile is found, as the objects
+	// rendered from the partial spec of the instance would drop the values of the profile.
+	spec, err := profile.Resolve(ctx, r.Client, &o.Spec)
+	if err != nil {
+		logger.Error(err, "failed to resolve the profile")
+		recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "invalid profile reference: %s", err)
+		o.Status.Error = err.Error()
+		if statusErr := r.Status().Update(ctx, o); statusErr != nil {
+			logger.Error(statusErr, "failed to update the resource's status")
+		}
+		return ctrl.Result{}, err
+	}
+	o.Status.EffectiveSpec = nil
+	if o.Spec.ProfileRef != nil {
+		o.Status.EffectiveSpec = spec.DeepCopy()
+	}
+
+	// pin the images requesting digest resolution before rendering the objects to manage.
+	// workloads whose image couldn't be resolved aren't synced, so they never roll out an unpinned tag.
+	podinfoImage, podinfoImageErr := r.resolveImageDigest(ctx, req.Namespace, spec.Image, o.Status.PodinfoImage)
+	if podinfoImageErr != nil {
+		logger.Error(podinfoImageErr, "failed to resolve podinfo image digest")
+		errs = errors.Join(errs, podinfoImageErr)
+	}
+	o.Status.PodinfoImage = podinfoImage
+
+	var redisImageErr error
+	if spec.Redis != nil && utils.Deref(spec.Redis.Enabled) {
+		spec.Redis.Image = redis.GetImage(spec)
+		var redisImage string
+		redisImage, redisImageErr = r.resolveImageDigest(ctx, req.Namespace, spec.Redis.Image, o.Status.RedisImage)
+		if redisImageErr != nil {
+			logger.Error(redisImageErr, "failed to resolve redis image digest")
+			errs = errors.Join(errs, redisImageErr)
+		}
+		o.Status.RedisImage = redisImage
+	} else {
+		o.Status.RedisImage = ""
+	}
+
+	// analyses podinfo image and config changes when an analysis is enabled.
+	// an update failing the analysis is rolled back to the last image and config that passed it.
+	var analysis analysisPlan
+	if podinfo.IsAnalysisEnabled(spec) && podinfo.IsImageSet(spec) && podinfoImageErr == nil {
+		var err error
+		if analysis, err = r.analyzeUpdate(ctx, o, spec, time.Now()); err != nil {
+			logger.Error(err, "failed to analyse the podinfo update")
+			errs = errors.Join(errs, err)
+		}
+		spec.Image, spec.Config = analysis.image, analysis.config
+	} else if !podinfo.IsAnalysisEnabled(spec) {
+		o.Status.Analysis = nil
+	}
+
+	// releases podinfo image changes through the canary deployment when canary releases are enabled.
+	// the stable deployment keeps the stable image and gives up replicas to the canary when traffic is split by replica ratio.
+	var canary canaryPlan
+	podinfoSpec, podinfoCanarySpec := spec, spec
+	if podinfo.IsCanaryEnabled(spec) && podinfo.IsImageSet(spec) && podinfoImageErr == nil {
+		var err error
+		if canary, err = r.progressCanary(ctx, o, spec, time.Now()); err != nil {
+			logger.Error(err, "failed to progress the canary release")
+			errs = errors.Join(errs, err)
+		}
+		podinfoSpec, podinfoCanarySpec = spec.DeepCopy(), spec.DeepCopy()
+		podinfoSpec.Image = canary.stableImage
+		podinfoCanarySpec.Image = canary.canaryImage
+		if canary.canaryImage != nil {
+			stableReplicas, canaryReplicas := podinfo.GetCanaryReplicas(spec, canary.weight)
+			podinfoCanarySpec.ReplicaCount = &canaryReplicas
+			// the promoted image rolls out to all the stable replicas while the canary keeps serving
+			if !canary.promoted {
+				podinfoSpec.ReplicaCount = &stableReplicas
+			}
+		}
+	} else if !podinfo.IsCanaryEnabled(spec) {
+		o.Status.Canary = nil
+	}
+
 	// fetch objects to manage from the request
-	redisStatefulSet := redis.GetStatefulset(req.Name, req.Namespace)
+	podServiceAccount := serviceaccount.GetServiceAccount(req.Name, req.Namespace, spec)
+	redisStatefulSet, redisPatchErr := renderRedisStatefulset(req.Name, req.Namespace, spec)
 	redisService := redis.GetService(req.Name, req.Namespace)
-	podinfoDeployment := podinfo.GetDeployment(req.Name, req.Namespace, redis.GetServiceAddr(req.Name, req.Namespace), &o.Spec)
-	podinfoService := podinfo.GetService(req.Name, req.Namespace)
+	podinfoConfigMap := podinfo.GetConfigMap(req.Name, req.Namespace, spec)
+	podinfoDeployment, podinfoPatchErr := renderPodinfoDeployment(req.Name, req.Namespace, podinfoSpec)
+	podinfoCanaryDeployment, podinfoCanaryPatchErr := renderPodinfoCanaryDeployment(req.Name, req.Namespace, podinfoCanarySpec)
+	podinfoHTTPRoute := podinfo.GetHTTPRoute(req.Name, req.Namespace, spec, canary.weight)
+	podinfoService := podinfo.GetService(req.Name, req.Namespace, spec)
+	podinfoMonitor := podinfo.GetMonitor(req.Name, req.Namespace, spec)
+	podinfoPrometheusRule := podinfo.GetPrometheusRule(req.Name, req.Namespace, spec)
+
+	// releases podinfo pod template changes through the blue and green deployments when blue/green releases are enabled.
+	// the podinfo service keeps selecting the active color until the preview one is available and approved.
+	var blueGreen blueGreenPlan
+	var podinfoColorDeployments []*appsv1.Deployment
+	var podinfoColorPatchErr error
+	if podinfo.IsBlueGreenEnabled(spec) && podinfo.IsImageSet(spec) && podinfoImageErr == nil && podinfoPatchErr == nil {
+		revision := generateTemplateRevision(&podinfoDeployment.Spec.Template)
+		var err error
+		if blueGreen, err = r.progressBlueGreen(ctx, o, spec, revision, time.Now()); err != nil {
+			logger.Error(err, "failed to progress the blue/green release")
+			errs = errors.Join(errs, err)
+		}
+		podinfoColorDeployments, podinfoColorPatchErr = renderPodinfoColorDeployments(req.Name, req.Namespace, spec, blueGreen, revision)
+	}
+	blueGreenStarting := o.Status.BlueGreen != nil && o.Status.BlueGreen.Phase == myapigroupv1alpha1.BlueGreenPhaseStarting
+	if podinfo.IsBlueGreenEnabled(spec) && o.Status.BlueGreen != nil && !blueGreenStarting {
+		podinfo.SelectColor(podinfoService, o.Status.BlueGreen.ActiveColor)
+	}
+
+	// scales the workloads down to zero while the instance is suspended, keeping the config and the redis volumes
+	suspendWorkloads(o, spec, append([]*appsv1.Deployment{podinfoDeployment, podinfoCanaryDeployment}, podinfoColorDeployments...), redisStatefulSet)
+
+	// syncs the instance service account unless an existing one is referenced
+	if serviceaccount.IsManaged(spec) {
+		if err := syncK8sServiceAccount(r.Client, ctx, o, podServiceAccount); err != nil {
+			logger.Error(err, "failed to sync k8 serviceaccount", "name", podServiceAccount.GetName())
+			errs = errors.Join(errs, err)
+		}
+	} else if spec.ServiceAccountName != podServiceAccount.GetName() {
+		if err := cleanK8sObjects(r.Client, ctx, o.Name, []client.Object{podServiceAccount}); err != nil {
+			logger.Error(err, "failed to cleanup serviceaccount")
+			errs = errors.Join(errs, err)
+		}
+	}
 
 	// syncs redis objects if redis is enabled
-	if o.Spec.Redis != nil && o.Spec.Redis.Enabled {
+	redisSyncStart := time.Now()
+	if spec.Redis != nil && utils.Deref(spec.Redis.Enabled) {
 		logger.Info("initiating a sync for redis backend")
-		if err := syncK8sStatefulset(r.Client, ctx, redisStatefulSet); err != nil {
-			logger.Error(err, "failed to sync k8 statefulset", "name", redisStatefulSet.GetName())
-			errs = errors.Join(errs, err)
+		if redisPatchErr != nil {
+			logger.Error(redisPatchErr, "failed to patch the redis pod template")
+			recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "invalid redis pod template patch: %s", redisPatchErr)
+			errs = errors.Join(errs, redisPatchErr)
+		}
+		redisLimitRangeErr := r.validateLimitRanges(ctx, req.Namespace, redisStatefulSet)
+		if redisLimitRangeErr != nil {
+			logger.Error(redisLimitRangeErr, "redis resource requirements violate the namespace limitranges")
+			recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "redis resource requirements violate the namespace limitranges: %s", redisLimitRangeErr)
+			errs = errors.Join(errs, redisLimitRangeErr)
+		}
+		if redisImageErr == nil && redisPatchErr == nil && redisLimitRangeErr == nil {
+			if err := syncK8sStatefulset(r.Client, ctx, o, redisStatefulSet); err != nil {
+				logger.Error(err, "failed to sync k8 statefulset", "name", redisStatefulSet.GetName())
+				errs = errors.Join(errs, err)
+			}
 		}
 
-		if err := syncK8sService(r.Client, ctx, redisService); err != nil {
+		if err := syncK8sService(r.Client, ctx, o, redisService); err != nil {
 			logger.Error(err, "failed to sync k8 service", "name", redisService.GetName())
 			errs = errors.Join(errs, err)
 
@@ -88,7 +360,7 @@ func (r *MyAppResourceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	} else {
 		// attempt to cleanup redis objects if the flag is unset
-		if err := cleanK8sObjects(r.Client, ctx, []client.Object{
+		if err := cleanK8sObjects(r.Client, ctx, o.Name, []client.Object{
 			redisStatefulSet,
 			redisService,
 		}); err != nil {
@@ -96,20 +368,206 @@ func (r *MyAppResourceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			errs = errors.Join(errs, err)
 		}
 	}
+	metrics.ReconcilePhaseDuration.WithLabelValues(metrics.PhaseRedisSync).Observe(time.Since(redisSyncStart).Seconds())
+
+	// syncs podinfo configmap ahead of the deployment mounting it
+	podinfoSyncStart := time.Now()
+	if podinfo.IsConfigEnabled(spec) {
+		if err := syncK8sConfigMap(r.Client, ctx, o, podinfoConfigMap); err != nil {
+			logger.Error(err, "failed to sync k8 configmap", "name", podinfoConfigMap.GetName())
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	// syncs podinfo deployment object once its pod template and resource requirements are known to be valid
+	if podinfoPatchErr != nil {
+		logger.Error(podinfoPatchErr, "failed to patch the podinfo pod template")
+		recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "invalid podinfo pod template patch: %s", podinfoPatchErr)
+		errs = errors.Join(errs, podinfoPatchErr)
+	}
+	podinfoExtensionsErr := podinfo.ValidateExtensions(req.Name, spec)
+	if podinfoExtensionsErr != nil {
+		logger.Error(podinfoExtensionsErr, "podinfo pod template additions conflict with the managed ones")
+		recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "podinfo pod template additions conflict with the managed ones: %s", podinfoExtensionsErr)
+		errs = errors.Join(errs, podinfoExtensionsErr)
+	}
+	podinfoWorkloads := []client.Object{podinfoDeployment}
+	if canary.canaryImage != nil && podinfoCanaryPatchErr == nil {
+		podinfoWorkloads = append(podinfoWorkloads, podinfoCanaryDeployment)
+	}
+	for _, deployment := range podinfoColorDeployments {
+		podinfoWorkloads = append(podinfoWorkloads, deployment)
+	}
+	podinfoLimitRangeErr := r.validateLimitRanges(ctx, req.Namespace, podinfoWorkloads...)
+	if podinfoLimitRangeErr != nil {
+		logger.Error(podinfoLimitRangeErr, "podinfo resource requirements violate the namespace limitranges")
+		recordEvent(ctx, corev1.EventTypeWarning, reasonInvalidSpec, "podinfo resource requirements violate the namespace limitranges: %s", podinfoLimitRangeErr)
+		errs = errors.Join(errs, podinfoLimitRangeErr)
+	}
+	if podinfoImageErr == nil && podinfoPatchErr == nil && podinfoExtensionsErr == nil && podinfoLimitRangeErr == nil {
+		if !podinfo.IsBlueGreenEnabled(spec) {
+			if err := syncK8sDeployment(r.Client, ctx, o, podinfoDeployment); err != nil {
+				logger.Error(err, "failed to sync k8 deployment", "name", podinfoDeployment.GetName())
+				errs = errors.Join(errs, err)
+			}
+		} else if podinfoColorPatchErr != nil {
+			logger.Error(podinfoColorPatchErr, "failed to patch the podinfo color pod template")
+			errs = errors.Join(errs, podinfoColorPatchErr)
+		} else {
+			for _, deployment := range podinfoColorDeployments {
+				if err := syncK8sDeployment(r.Client, ctx, o, deployment); err != nil {
+					logger.Error(err, "failed to sync k8 deployment", "name", deployment.GetName())
+					errs = errors.Join(errs, err)
+				}
+			}
+		}
+		if canary.canaryImage != nil && podinfoCanaryPatchErr != nil {
+			logger.Error(podinfoCanaryPatchErr, "failed to patch the podinfo canary pod template")
+			errs = errors.Join(errs, podinfoCanaryPatchErr)
+		} else if canary.canaryImage != nil {
+			if err := syncK8sDeployment(r.Client, ctx, o, podinfoCanaryDeployment); err != nil {
+				logger.Error(err, "failed to sync k8 deployment", "name", podinfoCanaryDeployment.GetName())
+				errs = errors.Join(errs, err)
+			}
+		}
+	}
+
+	// syncs the podinfo canary traffic routing if it is split by a gateway
+	if podinfo.IsGatewayRoutingEnabled(spec) {
+		for _, service := range []*corev1.Service{
+			podinfo.GetTrackService(req.Name, req.Namespace, podinfo.TrackStable),
+			podinfo.GetTrackService(req.Name, req.Namespace, podinfo.TrackCanary),
+		} {
+			if err := syncK8sService(r.Client, ctx, o, service); err != nil {
+				logger.Error(err, "failed to sync k8 service", "name", service.GetName())
+				errs = errors.Join(errs, err)
+			}
+		}
+		if err := syncK8sUnstructured(r.Client, ctx, o, podinfoHTTPRoute); err != nil {
+			logger.Error(err, "failed to sync k8 httproute", "name", podinfoHTTPRoute.GetName())
+			errs = errors.Join(errs, err)
+		}
+	}
 
-	// syncs podinfo deployment object
-	if err := syncK8sDeployment(r.Client, ctx, podinfoDeployment); err != nil {
-		logger.Error(err, "failed to sync k8 deployment", "name", podinfoDeployment.GetName())
+	// attempt to cleanup the podinfo canary objects no longer rendered
+	var staleCanaryObjects []client.Object
+	for _, object := range podinfo.GetAllCanaryObjects(req.Name, req.Namespace) {
+		isCanaryDeployment := object.GetName() == podinfoCanaryDeployment.GetName() && object.GetKind() == "Deployment"
+		if (isCanaryDeployment && canary.canaryImage == nil) || (!isCanaryDeployment && !podinfo.IsGatewayRoutingEnabled(spec)) {
+			staleCanaryObjects = append(staleCanaryObjects, object)
+		}
+	}
+	if err := cleanK8sObjects(r.Client, ctx, o.Name, staleCanaryObjects); err != nil {
+		logger.Error(err, "failed to cleanup podinfo canary objects")
+		errs = errors.Join(errs, err)
+	}
+
+	// syncs the podinfo preview service of the blue/green release, or cleans up the blue/green objects once it is disabled.
+	// the podinfo deployment is replaced by the color deployments while the blue/green release is enabled.
+	if podinfo.IsBlueGreenEnabled(spec) {
+		if o.Status.BlueGreen != nil {
+			podinfoPreviewService := podinfo.GetPreviewService(req.Name, req.Namespace, podinfo.OtherColor(o.Status.BlueGreen.ActiveColor))
+			if err := syncK8sService(r.Client, ctx, o, podinfoPreviewService); err != nil {
+				logger.Error(err, "failed to sync k8 service", "name", podinfoPreviewService.GetName())
+				errs = errors.Join(errs, err)
+			}
+		}
+		if len(podinfoColorDeployments) > 0 && !blueGreenStarting {
+			if err := cleanK8sObjects(r.Client, ctx, o.Name, []client.Object{podinfoDeployment}); err != nil {
+				logger.Error(err, "failed to cleanup podinfo deployment")
+				errs = errors.Join(errs, err)
+			}
+		}
+	} else {
+		// the color deployments keep serving until the podinfo deployment replacing them is available
+		stopped := true
+		if o.Status.BlueGreen != nil {
+			var err error
+			if stopped, err = r.stopBlueGreen(ctx, o, podinfoDeployment); err != nil {
+				logger.Error(err, "failed to stop the blue/green release")
+				errs = errors.Join(errs, err)
+			}
+			if !stopped {
+				blueGreen.requeueAfter = canaryPollInterval
+			}
+		}
+		if stopped {
+			var staleBlueGreenObjects []client.Object
+			for _, object := range podinfo.GetAllBlueGreenObjects(req.Name, req.Namespace) {
+				staleBlueGreenObjects = append(staleBlueGreenObjects, object)
+			}
+			if err := cleanK8sObjects(r.Client, ctx, o.Name, staleBlueGreenObjects); err != nil {
+				logger.Error(err, "failed to cleanup podinfo blue/green objects")
+				errs = errors.Join(errs, err)
+			}
+		}
+	}
+
+	// attempt to cleanup the podinfo configmap once the deployment no longer mounts it
+	if !podinfo.IsConfigEnabled(spec) {
+		if err := cleanK8sObjects(r.Client, ctx, o.Name, []client.Object{podinfoConfigMap}); err != nil {
+			logger.Error(err, "failed to cleanup podinfo configmap")
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	// reports the podinfo rollout progress, of the preview or active color when the blue/green release is enabled
+	podinfoRolloutDeployment := podinfoDeployment
+	if len(podinfoColorDeployments) > 0 {
+		podinfoRolloutDeployment = podinfoColorDeployments[0]
+	}
+	rolloutRequeueAfter, err := r.updateRolloutStatus(ctx, o, podinfoRolloutDeployment)
+	if err != nil {
+		logger.Error(err, "failed to update the rollout status")
 		errs = errors.Join(errs, err)
 	}
 
 	// syncs podinfor service object
-	if err := syncK8sService(r.Client, ctx, podinfoService); err != nil {
+	if err := syncK8sService(r.Client, ctx, o, podinfoService); err != nil {
 		logger.Error(err, "failed to sync k8 service", "name", podinfoService.GetName())
 		errs = errors.Join(errs, err)
 
 	}
 
+	// syncs podinfo monitoring objects if monitoring is enabled
+	var staleMonitoringObjects []client.Object
+	if podinfo.IsMonitoringEnabled(spec) {
+		logger.Info("initiating a sync for podinfo monitoring")
+		if err := syncK8sUnstructured(r.Client, ctx, o, podinfoMonitor); err != nil {
+			logger.Error(err, "failed to sync k8 monitor", "name", podinfoMonitor.GetName(), "kind", podinfoMonitor.GetKind())
+			errs = errors.Join(errs, err)
+		}
+	}
+	for _, monitor := range podinfo.GetAllMonitors(req.Name, req.Namespace) {
+		if !podinfo.IsMonitoringEnabled(spec) || monitor.GetKind() != podinfoMonitor.GetKind() {
+			staleMonitoringObjects = append(staleMonitoringObjects, monitor)
+		}
+	}
+
+	if podinfo.IsPrometheusRuleEnabled(spec) {
+		if err := syncK8sUnstructured(r.Client, ctx, o, podinfoPrometheusRule); err != nil {
+			logger.Error(err, "failed to sync k8 prometheus rule", "name", podinfoPrometheusRule.GetName())
+			errs = errors.Join(errs, err)
+		}
+	} else {
+		staleMonitoringObjects = append(staleMonitoringObjects, podinfoPrometheusRule)
+	}
+
+	// attempt to cleanup monitoring objects that are no longer rendered
+	if err := cleanK8sObjects(r.Client, ctx, o.Name, staleMonitoringObjects); err != nil {
+		logger.Error(err, "failed to cleanup monitoring objects")
+		errs = errors.Join(errs, err)
+	}
+	metrics.ReconcilePhaseDuration.WithLabelValues(metrics.PhasePodinfoSync).Observe(time.Since(podinfoSyncStart).Seconds())
+
+	// records the applied spec in the instance history
+	if errs == nil {
+		if err := r.recordSpecRevision(ctx, o, time.Now()); err != nil {
+			logger.Error(err, "failed to record the spec revision")
+			errs = errors.Join(errs, err)
+		}
+	}
+
 	if errs == nil {
 		o.Status.Error = ""
 		o.Status.Valid = true
@@ -118,30 +576,162 @@ func (r *MyAppResourceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		o.Status.Valid = false
 	}
 
-	if err := r.Status().Update(ctx, o); err != nil {
+	// reports the instance readiness and the replicas of the deployment whose rollout is reported
+	desiredReplicas, readyReplicas := int32(1), int32(0)
+	if podinfoRolloutDeployment.Spec.Replicas != nil {
+		desiredReplicas = *podinfoRolloutDeployment.Spec.Replicas
+	}
+	if o.Status.Rollout != nil {
+		readyReplicas = o.Status.Rollout.ReadyReplicas
+	}
+	var redisRemote *appsv1.StatefulSet
+	if spec.Redis != nil && utils.Deref(spec.Redis.Enabled) {
+		redisRemote = &appsv1.StatefulSet{}
+		if err := lookupObject(r.Client, ctx, redisStatefulSet, redisRemote); err != nil {
+			// the instance is reported as not ready until the statefulset can be read
+			logger.Error(err, "failed to lookup the redis statefulset", "name", redisStatefulSet.GetName())
+		}
+	}
+	metrics.SetInstance(o.Namespace, o.Name, isInstanceReady(o, desiredReplicas, redisRemote), desiredReplicas, readyReplicas)
+
+	statusUpdateStart := time.Now()
+	err = r.Status().Update(ctx, o)
+	metrics.ReconcilePhaseDuration.WithLabelValues(metrics.PhaseStatusUpdate).Observe(time.Since(statusUpdateStart).Seconds())
+	if err != nil {
 		logger.Error(err, "failed to update the resource's status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: minRequeueAfter(canary.requeueAfter, analysis.requeueAfter, blueGreen.requeueAfter, rolloutRequeueAfter, r.Options.ResyncPeriod)}, nil
+}
+
+// getAPIReader returns the reader of the objects left out of the cache, defaulting to the client.
+func (r *MyAppResourceReconciler) getAPIReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *MyAppResourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(controllerName)
+	}
+	if r.Options == (Options{}) {
+		r.Options = DefaultOptions()
+	}
+	controllerOptions := getControllerOptions(r.Options)
+	instances := make(chan event.GenericEvent, instanceEventsBufferSize)
+	if r.Shards != nil {
+		// every replica reconciles its own shard, the leader only runs the shared duties.
+		controllerOptions.NeedLeaderElection = utils.Ptr(false)
+		r.Shards.OnRebalance(func() {
+			forgetAbsentObjects()
+			r.enqueueInstances(mgr.GetClient(), instances, func(o *myapigroupv1alpha1.MyAppResource) bool { return r.Shards.Owns(o) })
+		})
+	}
+	if r.Config != nil {
+		r.Config.OnChange(func(previous *operatorconfig.Config, config *operatorconfig.Config) {
+			r.enqueueInstances(mgr.GetClient(), instances, func(o *myapigroupv1alpha1.MyAppResource) bool {
+				spec := &o.Spec
+				if o.Status.EffectiveSpec != nil {
+					spec = o.Status.EffectiveSpec
+				}
+				return operatorconfig.Affects(previous, config, spec)
+			})
+		})
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&myapigroupv1alpha1.MyAppResource{}).
-		Owns(&appsv1.Deployment{}).
-		Owns(&corev1.Service{}).
-		Owns(&appsv1.StatefulSet{}).
+		WithOptions(controllerOptions).
+		WatchesRawSource(&source.Channel{Source: instances}, &handler.EnqueueRequestForObject{}).
+		For(&myapigroupv1alpha1.MyAppResource{}, builder.WithPredicates(instancePredicate())).
+		Watches(&myapigroupv1alpha1.MyAppProfile{}, handler.EnqueueRequestsFromMapFunc(r.mapProfileToInstances),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(ownedObjectPredicate())).
+		Owns(&corev1.Service{}, builder.WithPredicates(ownedObjectPredicate())).
+		Owns(&appsv1.StatefulSet{}, builder.WithPredicates(ownedObjectPredicate())).
+		Owns(&corev1.ServiceAccount{}, builder.WithPredicates(ownedObjectPredicate())).
+		Owns(&corev1.ConfigMap{}, builder.WithPredicates(ownedObjectPredicate())).
 		Complete(r)
 }
 
+// instanceEventsBufferSize bounds the events of the instances to reconcile waiting for the controller to read them.
+const instanceEventsBufferSize = 1024
+
+// enqueueInstances reconciles the instances matching the filter, which don't receive any event when the shards are
+// rebalanced or the operator configuration changes. The events are dropped rather than blocking once the buffer is
+// full, e.g. on a standby replica whose controller isn't started and reconciles every instance once it starts.
+func (r *MyAppResourceReconciler) enqueueInstances(reader client.Reader, events chan<- event.GenericEvent, filter func(o *myapigroupv1alpha1.MyAppResource) bool) {
+	ctx := context.Background()
+	logger := log.FromContext(ctx)
+	var instances myapigroupv1alpha1.MyAppResourceList
+	if err := reader.List(ctx, &instances); err != nil {
+		logger.Error(err, "failed to list the instances to reconcile")
+		return
+	}
+	dropped := 0
+	for i := range instances.Items {
+		if !filter(&instances.Items[i]) {
+			continue
+		}
+		select {
+		case events <- event.GenericEvent{Object: &instances.Items[i]}:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		logger.Info("dropped the events of instances to reconcile, the controller isn't reading them", "dropped", dropped)
+	}
+}
+
+// mapProfileToInstances returns the instances referencing the profile, which are reconciled once it changes.
+func (r *MyAppResourceReconciler) mapProfileToInstances(ctx context.Context, object client.Object) []reconcile.Request {
+	var instances myapigroupv1alpha1.MyAppResourceList
+	if err := r.Client.List(ctx, &instances); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the instances referencing the profile", "profile", object.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, instance := range instances.Items {
+		if instance.Spec.ProfileRef != nil && instance.Spec.ProfileRef.Name == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&instance)})
+		}
+	}
+	return requests
+}
+
 // getAllManagedObjects returns all the k8s object directly managed by the operator.
 func getAllManagedObjects(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) []client.Object {
-	return []client.Object{
-		redis.GetStatefulset(name, namespace),
+	objects := []client.Object{
+		redis.GetStatefulset(name, namespace, spec),
 		redis.GetService(name, namespace),
 		podinfo.GetDeployment(name, namespace, redis.GetServiceAddr(name, namespace), spec),
-		podinfo.GetService(name, namespace),
+		podinfo.GetConfigMap(name, namespace, spec),
+		podinfo.GetService(name, namespace, spec),
+		podinfo.GetPrometheusRule(name, namespace, spec),
+		serviceaccount.GetServiceAccount(name, namespace, spec),
+	}
+	for _, monitor := range podinfo.GetAllMonitors(name, namespace) {
+		objects = append(objects, monitor)
+	}
+	for _, object := range podinfo.GetAllCanaryObjects(name, namespace) {
+		objects = append(objects, object)
+	}
+	for _, object := range podinfo.GetAllBlueGreenObjects(name, namespace) {
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// minRequeueAfter returns the shortest of the non-zero delays, zero if none is set.
+func minRequeueAfter(delays ...time.Duration) time.Duration {
+	var result time.Duration
+	for _, delay := range delays {
+		if delay > 0 && (result == 0 || delay < result) {
+			result = delay
+		}
 	}
+	return result
 }
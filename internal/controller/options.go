@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// The defaults of the controller options, matching the ones of controller-runtime.
+const (
+	DefaultMaxConcurrentReconciles = 1
+	DefaultRateLimiterBaseDelay    = 5 * time.Millisecond
+	DefaultRateLimiterMaxDelay     = 1000 * time.Second
+	DefaultRateLimiterQPS          = 10
+	DefaultRateLimiterBurst        = 100
+)
+
+// Options configures the concurrency, the rate limiting and the periodic resync of the reconciles.
+type Options struct {
+	// MaxConcurrentReconciles is the number of instances reconciled in parallel. An instance is never reconciled by
+	// two workers at once.
+	MaxConcurrentReconciles int
+
+	// RateLimiterBaseDelay is the delay before retrying the first failed reconcile of an instance, doubled on each
+	// consecutive failure.
+	RateLimiterBaseDelay time.Duration
+
+	// RateLimiterMaxDelay caps the delay before retrying the failed reconciles of an instance.
+	RateLimiterMaxDelay time.Duration
+
+	// RateLimiterQPS is the overall rate of the reconciles queued, with bursts of RateLimiterBurst.
+	RateLimiterQPS float64
+
+	// RateLimiterBurst is the number of reconciles queued at once beyond RateLimiterQPS.
+	RateLimiterBurst int
+
+	// ResyncPeriod is the period the instances are reconciled at to detect drifted managed objects, even when
+	// no event is received. The periodic resync is disabled when zero.
+	ResyncPeriod time.Duration
+}
+
+// DefaultOptions returns the controller-runtime defaults, with the periodic resync disabled.
+func DefaultOptions() Options {
+	return Options{
+		MaxConcurrentReconciles: DefaultMaxConcurrentReconciles,
+		RateLimiterBaseDelay:    DefaultRateLimiterBaseDelay,
+		RateLimiterMaxDelay:     DefaultRateLimiterMaxDelay,
+		RateLimiterQPS:          DefaultRateLimiterQPS,
+		RateLimiterBurst:        DefaultRateLimiterBurst,
+	}
+}
+
+// getControllerOptions returns the controller-runtime options of the controller, rate limiting the reconciles both
+// per instance, with an exponential backoff, and overall, with a token bucket.
+func getControllerOptions(options Options) crcontroller.Options {
+	return crcontroller.Options{
+		MaxConcurrentReconciles: options.MaxConcurrentReconciles,
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(options.RateLimiterBaseDelay, options.RateLimiterMaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(options.RateLimiterQPS), options.RateLimiterBurst)},
+		),
+	}
+}
@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+)
+
+func TestGetControllerOptions(t *testing.T) {
+	options := getControllerOptions(Options{
+		MaxConcurrentReconciles: 4,
+		RateLimiterBaseDelay:    time.Second,
+		RateLimiterMaxDelay:     3 * time.Second,
+		RateLimiterQPS:          100,
+		RateLimiterBurst:        100,
+	})
+	if options.MaxConcurrentReconciles != 4 {
+		t.Errorf("getControllerOptions: expected 4 concurrent reconciles, got %d", options.MaxConcurrentReconciles)
+	}
+
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		delays = append(delays, options.RateLimiter.When("whatever"))
+	}
+	// the delays of another instance are independent
+	delays = append(delays, options.RateLimiter.When("other"))
+	expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second, time.Second}
+	if diff := cmp.Diff(expected, delays); diff != "" {
+		t.Errorf("getControllerOptions: delays mismatch (-want +got):\n%s", diff)
+	}
+
+	options.RateLimiter.Forget("whatever")
+	if delay := options.RateLimiter.When("whatever"); delay != time.Second {
+		t.Errorf("getControllerOptions: expected the delay to be reset, got %s", delay)
+	}
+}
+
+func TestMinRequeueAfterResync(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		delays   []time.Duration
+		expected time.Duration
+	}{
+		{name: "no delay", delays: []time.Duration{0, 0}},
+		{name: "resync only", delays: []time.Duration{0, time.Hour}, expected: time.Hour},
+		{name: "rollout before resync", delays: []time.Duration{10 * time.Second, time.Hour}, expected: 10 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := minRequeueAfter(tc.delays...); got != tc.expected {
+				t.Errorf("minRequeueAfter: expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// latencyClient delays the writes like a loaded API server, and counts the conflicts they run into.
+type latencyClient struct {
+	client.Client
+	delay     time.Duration
+	conflicts *atomic.Int32
+}
+
+func (c *latencyClient) write(err error) error {
+	if apierrors.IsConflict(err) {
+		c.conflicts.Add(1)
+	}
+	return err
+}
+
+func (c *latencyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	time.Sleep(c.delay)
+	return c.write(c.Client.Create(ctx, obj, opts...))
+}
+
+func (c *latencyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	time.Sleep(c.delay)
+	return c.write(c.Client.Update(ctx, obj, opts...))
+}
+
+func (c *latencyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	time.Sleep(c.delay)
+	return c.write(c.Client.Patch(ctx, obj, patch, opts...))
+}
+
+func (c *latencyClient) Status() client.SubResourceWriter {
+	return &latencyStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+type latencyStatusWriter struct {
+	client.SubResourceWriter
+	client *latencyClient
+}
+
+func (w *latencyStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	time.Sleep(w.client.delay)
+	return w.client.write(w.SubResourceWriter.Update(ctx, obj, opts...))
+}
+
+// overlapReconciler counts the reconciles of an instance started while another one of the same instance is running.
+type overlapReconciler struct {
+	*MyAppResourceReconciler
+	running  sync.Map
+	overlaps atomic.Int32
+}
+
+func (r *overlapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if _, loaded := r.running.LoadOrStore(req.NamespacedName, true); loaded {
+		r.overlaps.Add(1)
+	} else {
+		defer r.running.Delete(req.NamespacedName)
+	}
+	return r.MyAppResourceReconciler.Reconcile(ctx, req)
+}
+
+// reconcileInstances creates the instances in a namespace of their own and returns the time until all of them are
+// valid, reconciled by a manager with the given number of workers, with the conflicts and overlapping reconciles.
+func reconcileInstances(t *testing.T, cfg *rest.Config, k8sClient client.Client, workers int, instances int) (time.Duration, int32, int32) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	namespace := fmt.Sprintf("concurrency-%d", workers)
+	if err := k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}); err != nil {
+		t.Fatalf("failed to create the namespace: %v", err)
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  k8sClient.Scheme(),
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		Cache:   cache.Options{DefaultNamespaces: map[string]cache.Config{namespace: {}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the manager: %v", err)
+	}
+	options := DefaultOptions()
+	options.MaxConcurrentReconciles = workers
+	conflicts := &atomic.Int32{}
+	r := &overlapReconciler{MyAppResourceReconciler: &MyAppResourceReconciler{
+		Client:   &latencyClient{Client: mgr.GetClient(), delay: 20 * time.Millisecond, conflicts: conflicts},
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(controllerName),
+		Options:  options,
+	}}
+	err = ctrl.NewControllerManagedBy(mgr).
+		Named(namespace).
+		WithOptions(getControllerOptions(options)).
+		For(&myapigroupv1alpha1.MyAppResource{}, builder.WithPredicates(instancePredicate())).
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(ownedObjectPredicate())).
+		Complete(r)
+	if err != nil {
+		t.Fatalf("failed to create the controller: %v", err)
+	}
+	go func() { _ = mgr.Start(ctx) }()
+	if !mgr.GetCache().WaitForCacheSync(ctx) {
+		t.Fatalf("failed to sync the cache")
+	}
+
+	start := time.Now()
+	for i := 0; i < instances; i++ {
+		o := &myapigroupv1alpha1.MyAppResource{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: fmt.Sprintf("instance-%d", i)}}
+		if err := k8sClient.Create(ctx, o); err != nil {
+			t.Fatalf("failed to create the instance: %v", err)
+		}
+	}
+	err = wait.PollUntilContextTimeout(ctx, 50*time.Millisecond, time.Minute, true, func(ctx context.Context) (bool, error) {
+		var list myapigroupv1alpha1.MyAppResourceList
+		if err := k8sClient.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+			return false, err
+		}
+		for _, o := range list.Items {
+			if !o.Status.Valid {
+				return false, nil
+			}
+		}
+		return len(list.Items) == instances, nil
+	})
+	if err != nil {
+		t.Fatalf("the instances weren't reconciled with %d workers: %v", workers, err)
+	}
+	return time.Since(start), conflicts.Load(), r.overlaps.Load()
+}
+
+// TestMaxConcurrentReconciles reconciles instances against envtest with a single worker then several ones, the
+// writes being slowed down, and checks that the workers reconcile the instances faster without reconciling an instance
+// twice at once nor running into more conflicts. It is skipped when the envtest binaries are missing.
+func TestMaxConcurrentReconciles(t *testing.T) {
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+		BinaryAssetsDirectory: filepath.Join("..", "..", "bin", "k8s", fmt.Sprintf("1.29.0-%s-%s", runtime.GOOS, runtime.GOARCH)),
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Skipf("envtest isn't available: %v", err)
+	}
+	defer func() { _ = env.Stop() }()
+	if err := myapigroupv1alpha1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("failed to add the API to the scheme: %v", err)
+	}
+	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+
+	const instances = 20
+	sequential, sequentialConflicts, sequentialOverlaps := reconcileInstances(t, cfg, k8sClient, 1, instances)
+	concurrent, concurrentConflicts, concurrentOverlaps := reconcileInstances(t, cfg, k8sClient, 4, instances)
+	t.Logf("1 worker: %s, %d conflicts; 4 workers: %s, %d conflicts", sequential, sequentialConflicts, concurrent, concurrentConflicts)
+	if concurrent >= sequential {
+		t.Errorf("MaxConcurrentReconciles: expected 4 workers to be faster than 1, got %s and %s", concurrent, sequential)
+	}
+	if sequentialOverlaps != 0 || concurrentOverlaps != 0 {
+		t.Errorf("MaxConcurrentReconciles: expected no instance reconciled twice at once, got %d and %d", sequentialOverlaps, concurrentOverlaps)
+	}
+	if concurrentConflicts > sequentialConflicts {
+		t.Errorf("MaxConcurrentReconciles: expected no more conflicts with 4 workers than 1, got %d and %d", concurrentConflicts, sequentialConflicts)
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// managedByLabel is set on the managed objects, so that the manager only caches the objects of the owned kinds
+// managed by the operator.
+const (
+	managedByLabel      = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "myappresource-operator"
+)
+
+// instancePredicate filters the events of the instances, which are only reconciled when their spec, their
+// annotations, such as the rollback and promotion ones, or their labels, such as the shard one, change. The updates
+// of their status are ignored.
+func instancePredicate() predicate.Predicate {
+	return predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{},
+		predicate.LabelChangedPredicate{})
+}
+
+// ownedObjectPredicate filters the events of the owned objects. The updates of their status are ignored, except
+// for the readiness transitions the rollouts wait for, while the changes of their spec or metadata are reconciled
+// to revert them. The kinds without generation have no status to ignore.
+func ownedObjectPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			if e.ObjectNew.GetGeneration() == 0 || e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() {
+				return true
+			}
+			if !labels.Equals(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) ||
+				!labels.Equals(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()) ||
+				e.ObjectNew.GetDeletionTimestamp() != nil {
+				return true
+			}
+			return getReadiness(e.ObjectOld) != getReadiness(e.ObjectNew)
+		},
+	}
+}
+
+// getReadiness summarizes the readiness of an owned object: the rollout progress of a deployment, as reported by
+// the Degraded condition, and whether all the replicas of a statefulset are ready.
+func getReadiness(object client.Object) string {
+	switch o := object.(type) {
+	case *appsv1.Deployment:
+		_, condition := generateRolloutStatus(o)
+		return condition.Reason
+	case *appsv1.StatefulSet:
+		desired := int32(1)
+		if o.Spec.Replicas != nil {
+			desired = *o.Spec.Replicas
+		}
+		if o.Status.ObservedGeneration >= o.Generation && o.Status.ReadyReplicas == desired {
+			return "Ready"
+		}
+		return "NotReady"
+	}
+	return ""
+}
+
+// CacheByObject restricts the cache of the manager to the objects of the owned kinds managed by the operator,
+// rather than every Deployment, Service or ConfigMap of the cluster, and to the spec revisions of the instances.
+//
+// Returns:
+//
+//	map[client.Object]cache.ByObject: The cache options of the owned kinds.
+func CacheByObject() map[client.Object]cache.ByObject {
+	managed := labels.SelectorFromSet(labels.Set{managedByLabel: managedByLabelValue})
+	revisions := labels.NewSelector()
+	if requirement, err := labels.NewRequirement(instanceLabel, selection.Exists, nil); err == nil {
+		revisions = revisions.Add(*requirement)
+	}
+	return map[client.Object]cache.ByObject{
+		&appsv1.Deployment{}:         {Label: managed},
+		&appsv1.StatefulSet{}:        {Label: managed},
+		&corev1.Service{}:            {Label: managed},
+		&corev1.ServiceAccount{}:     {Label: managed},
+		&corev1.ConfigMap{}:          {Label: managed},
+		&appsv1.ControllerRevision{}: {Label: revisions},
+	}
+}
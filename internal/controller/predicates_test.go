@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/sharding"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func TestInstancePredicate(t *testing.T) {
+	old := &myapigroupv1alpha1.MyAppResource{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+
+	for _, tc := range []struct {
+		name     string
+		update   func(o *myapigroupv1alpha1.MyAppResource)
+		expected bool
+	}{
+		{name: "spec changed", update: func(o *myapigroupv1alpha1.MyAppResource) { o.Generation = 2 }, expected: true},
+		{name: "annotation set", update: func(o *myapigroupv1alpha1.MyAppResource) {
+			o.Annotations = map[string]string{rollbackToAnnotation: "1"}
+		}, expected: true},
+		{name: "shard label set", update: func(o *myapigroupv1alpha1.MyAppResource) {
+			o.Labels = map[string]string{sharding.ShardLabel: "a"}
+		}, expected: true},
+		{name: "status updated", update: func(o *myapigroupv1alpha1.MyAppResource) { o.Status.Valid = true }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := old.DeepCopy()
+			tc.update(updated)
+			if got := instancePredicate().Update(event.UpdateEvent{ObjectOld: old, ObjectNew: updated}); got != tc.expected {
+				t.Errorf("instancePredicate: expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOwnedObjectPredicate(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: utils.Ptr[int32](2)},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2},
+	}
+	statefulset := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+		Spec:       appsv1.StatefulSetSpec{Replicas: utils.Ptr[int32](1)},
+		Status:     appsv1.StatefulSetStatus{ObservedGeneration: 1},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		old      client.Object
+		update   func(object client.Object)
+		expected bool
+	}{
+		{name: "deployment spec changed", old: deployment, update: func(object client.Object) { object.SetGeneration(2) }, expected: true},
+		{name: "deployment labels changed", old: deployment, update: func(object client.Object) {
+			object.SetLabels(map[string]string{"foo": "bar"})
+		}, expected: true},
+		{name: "deployment pod became available", old: deployment, update: func(object client.Object) {
+			object.(*appsv1.Deployment).Status.AvailableReplicas = 1
+		}},
+		{name: "deployment rollout completed", old: deployment, update: func(object client.Object) {
+			object.(*appsv1.Deployment).Status.AvailableReplicas = 2
+		}, expected: true},
+		{name: "deployment progress deadline exceeded", old: deployment, update: func(object client.Object) {
+			object.(*appsv1.Deployment).Status.Conditions = []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: progressDeadlineExceededReason},
+			}
+		}, expected: true},
+		{name: "statefulset replica created", old: statefulset, update: func(object client.Object) {
+			object.(*appsv1.StatefulSet).Status.Replicas = 1
+		}},
+		{name: "statefulset replica ready", old: statefulset, update: func(object client.Object) {
+			object.(*appsv1.StatefulSet).Status.ReadyReplicas = 1
+		}, expected: true},
+		{name: "config map changed", old: &corev1.ConfigMap{}, update: func(object client.Object) {
+			object.(*corev1.ConfigMap).Data = map[string]string{"foo": "bar"}
+		}, expected: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := tc.old.DeepCopyObject().(client.Object)
+			tc.update(updated)
+			if got := ownedObjectPredicate().Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: updated}); got != tc.expected {
+				t.Errorf("ownedObjectPredicate: expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+// instanceClient lists the instances it holds.
+type instanceClient struct {
+	client.Client
+	instances []myapigroupv1alpha1.MyAppResource
+}
+
+func (c *instanceClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	list.(*myapigroupv1alpha1.MyAppResourceList).Items = c.instances
+	return nil
+}
+
+func TestEnqueueInstances(t *testing.T) {
+	c := &instanceClient{instances: []myapigroupv1alpha1.MyAppResource{
+		{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "third", Namespace: "other"}},
+	}}
+	r := &MyAppResourceReconciler{}
+	inDefault := func(o *myapigroupv1alpha1.MyAppResource) bool { return o.Namespace == "default" }
+
+	events := make(chan event.GenericEvent, 2)
+	r.enqueueInstances(c, events, inDefault)
+	if len(events) != 2 {
+		t.Fatalf("enqueueInstances: expected 2 events, got %d", len(events))
+	}
+
+	// nothing reads the events while the controller isn't started, they are dropped rather than blocking
+	done := make(chan struct{})
+	go func() {
+		r.enqueueInstances(c, events, inDefault)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("enqueueInstances: blocked on the full events channel")
+	}
+	if e := <-events; e.Object.GetName() != "first" {
+		t.Errorf("enqueueInstances: expected the first instance, got %s", e.Object.GetName())
+	}
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/podtemplate"
+	"github.com/aa-ang4335/myappresource-operator/internal/profile"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/podinfo"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/redis"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/serviceaccount"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// maxRenderRequestSize bounds the size of the MyAppResource accepted by the render handler.
+const maxRenderRequestSize = 1 << 20
+
+// renderPodinfoDeployment renders the podinfo deployment with the pod template patch of the spec applied.
+func renderPodinfoDeployment(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) (*appsv1.Deployment, error) {
+	deployment := podinfo.GetDeployment(name, namespace, redis.GetServiceAddr(name, namespace), spec)
+	return deployment, patchPodinfoDeployment(deployment, spec)
+}
+
+// renderPodinfoCanaryDeployment renders the podinfo canary deployment with the pod template patch of the spec applied.
+func renderPodinfoCanaryDeployment(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) (*appsv1.Deployment, error) {
+	deployment := podinfo.GetCanaryDeployment(name, namespace, redis.GetServiceAddr(name, namespace), spec)
+	return deployment, patchPodinfoDeployment(deployment, spec)
+}
+
+// renderPodinfoColorDeployment renders the podinfo deployment of a color with the pod template patch of the spec applied.
+func renderPodinfoColorDeployment(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec, color string) (*appsv1.Deployment, error) {
+	deployment := podinfo.GetColorDeployment(name, namespace, redis.GetServiceAddr(name, namespace), spec, color)
+	return deployment, patchPodinfoDeployment(deployment, spec)
+}
+
+func patchPodinfoDeployment(deployment *appsv1.Deployment, spec *myapigroupv1alpha1.MyAppResourceSpec) error {
+	if spec.PodTemplatePatch == nil || len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return nil
+	}
+
+	if err := podtemplate.Apply(&deployment.Spec.Template, string(spec.PodTemplatePatch.Type), spec.PodTemplatePatch.Patch); err != nil {
+		return fmt.Errorf("spec.podTemplatePatch: %w", err)
+	}
+	return nil
+}
+
+// renderRedisStatefulset renders the redis statefulset with the pod template patch of the spec applied.
+func renderRedisStatefulset(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) (*appsv1.StatefulSet, error) {
+	statefulSet := redis.GetStatefulset(name, namespace, spec)
+	if spec.Redis == nil || spec.Redis.PodTemplatePatch == nil {
+		return statefulSet, nil
+	}
+
+	if err := podtemplate.Apply(&statefulSet.Spec.Template, string(spec.Redis.PodTemplatePatch.Type), spec.Redis.PodTemplatePatch.Patch); err != nil {
+		return statefulSet, fmt.Errorf("spec.redis.podTemplatePatch: %w", err)
+	}
+	return statefulSet, nil
+}
+
+// renderObjects renders the objects the operator syncs for the spec, the way the reconciler would.
+// Image digests aren't resolved, the namespace LimitRanges aren't checked, no canary is rendered and
+// the blue color is rendered as active, as they depend on the cluster state.
+func renderObjects(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) ([]client.Object, error) {
+	var objects []client.Object
+	var errs error
+
+	if serviceaccount.IsManaged(spec) {
+		objects = append(objects, serviceaccount.GetServiceAccount(name, namespace, spec))
+	}
+	if spec.Redis != nil && utils.Deref(spec.Redis.Enabled) {
+		spec.Redis.Image = redis.GetImage(spec)
+		statefulSet, err := renderRedisStatefulset(name, namespace, spec)
+		errs = errors.Join(errs, err)
+		objects = append(objects, statefulSet, redis.GetService(name, namespace))
+	}
+	if podinfo.IsConfigEnabled(spec) {
+		objects = append(objects, podinfo.GetConfigMap(name, namespace, spec))
+	}
+	deployment, err := renderPodinfoDeployment(name, namespace, spec)
+	service := podinfo.GetService(name, namespace, spec)
+	if podinfo.IsBlueGreenEnabled(spec) {
+		deployment, err = renderPodinfoColorDeployment(name, namespace, spec, podinfo.ColorBlue)
+		podinfo.SelectColor(service, podinfo.ColorBlue)
+	}
+	errs = errors.Join(errs, podinfo.ValidateExtensions(name, spec), err)
+	objects = append(objects, deployment, service)
+	if podinfo.IsBlueGreenEnabled(spec) {
+		objects = append(objects, podinfo.GetPreviewService(name, namespace, podinfo.ColorGreen))
+	}
+	if podinfo.IsGatewayRoutingEnabled(spec) {
+		objects = append(objects,
+			podinfo.GetTrackService(name, namespace, podinfo.TrackStable),
+			podinfo.GetTrackService(name, namespace, podinfo.TrackCanary),
+			podinfo.GetHTTPRoute(name, namespace, spec, 0),
+		)
+	}
+	if podinfo.IsMonitoringEnabled(spec) {
+		objects = append(objects, podinfo.GetMonitor(name, namespace, spec))
+	}
+	if podinfo.IsPrometheusRuleEnabled(spec) {
+		objects = append(objects, podinfo.GetPrometheusRule(name, namespace, spec))
+	}
+
+	return objects, errs
+}
+
+// NewRenderHandler returns a handler rendering the objects the operator would sync for a MyAppResource, without applying them.
+// It accepts a MyAppResource in JSON or YAML on POST and responds with a v1 List of the rendered objects.
+// The spec of a MyAppResource referencing a profile is merged over it first.
+//
+// Parameters:
+//
+//	scheme: The scheme used to set the kind of the rendered objects.
+//	reader: The client reading the profiles.
+//
+// Returns:
+//
+//	http.Handler: The render handler.
+func NewRenderHandler(scheme *runtime.Scheme, reader client.Reader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxRenderRequestSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read the request: %v", err), http.StatusBadRequest)
+			return
+		}
+		o := &myapigroupv1alpha1.MyAppResource{}
+		if err := yaml.Unmarshal(body, o); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode the MyAppResource: %v", err), http.StatusBadRequest)
+			return
+		}
+		if o.Name == "" || o.Namespace == "" {
+			http.Error(w, "metadata.name and metadata.namespace must be set", http.StatusBadRequest)
+			return
+		}
+
+		resolve := func(ctx context.Context, spec *myapigroupv1alpha1.MyAppResourceSpec) (*myapigroupv1alpha1.MyAppResourceSpec, error) {
+			return profile.Resolve(ctx, reader, spec)
+		}
+		warnings, err := myapigroupv1alpha1.NewMyAppResourceValidator(resolve).ValidateCreate(req.Context(), o)
+		for _, warning := range warnings {
+			w.Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		spec, err := profile.Resolve(req.Context(), reader, &o.Spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		objects, err := renderObjects(o.Name, o.Namespace, spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		list := &corev1.List{}
+		list.APIVersion, list.Kind = "v1", "List"
+		for _, object := range objects {
+			gvk, err := apiutil.GVKForObject(object, scheme)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to render %s: %v", object.GetName(), err), http.StatusInternalServerError)
+				return
+			}
+			object.GetObjectKind().SetGroupVersionKind(gvk)
+			list.Items = append(list.Items, runtime.RawExtension{Object: object})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode the rendered objects: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// profileClient reads the profiles it holds.
+type profileClient struct {
+	client.Client
+	profiles map[string]*myapigroupv1alpha1.MyAppProfile
+}
+
+func (c *profileClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	profile, found := c.profiles[key.Name]
+	if !found {
+		return apierrors.NewNotFound(myapigroupv1alpha1.GroupVersion.WithResource("myappprofiles").GroupResource(), key.Name)
+	}
+	profile.DeepCopyInto(obj.(*myapigroupv1alpha1.MyAppProfile))
+	return nil
+}
+
+func TestRenderHandler(t *testing.T) {
+	renderScheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(renderScheme))
+	utilruntime.Must(myapigroupv1alpha1.AddToScheme(renderScheme))
+	handler := NewRenderHandler(renderScheme, &profileClient{profiles: map[string]*myapigroupv1alpha1.MyAppProfile{
+		"with-redis": {
+			ObjectMeta: metav1.ObjectMeta{Name: "with-redis"},
+			Spec:       myapigroupv1alpha1.MyAppResourceSpec{Redis: &myapigroupv1alpha1.Redis{Enabled: utils.Ptr(true)}},
+		},
+	}})
+
+	for _, tc := range []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedKinds  []string
+	}{
+		{
+			name: "patched podinfo with redis",
+			body: `
+apiVersion: my.api.group/v1alpha1
+kind: MyAppResource
+metadata:
+  name: whatever
+  namespace: default
+spec:
+  image:
+    repository: ghcr.io/stefanprodan/podinfo
+    tag: latest
+  redis:
+    enabled: true
+  podTemplatePatch:
+    type: JSON
+    patch: '[{"op":"add","path":"/spec/hostname","value":"podinfo"}]'
+`,
+			expectedStatus: http.StatusOK,
+			expectedKinds:  []string{"ServiceAccount", "StatefulSet", "Service", "Deployment", "Service"},
+		},
+		{
+			name: "patch that doesn't apply to the rendered template",
+			body: `
+metadata:
+  name: whatever
+  namespace: default
+spec:
+  image:
+    repository: ghcr.io/stefanprodan/podinfo
+    tag: latest
+  podTemplatePatch:
+    type: JSON
+    patch: '[{"op":"replace","path":"/spec/containers/3/image","value":"busybox"}]'
+`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "blue/green release",
+			body: `
+metadata:
+  name: whatever
+  namespace: default
+spec:
+  image:
+    repository: ghcr.io/stefanprodan/podinfo
+    tag: latest
+  rollout:
+    blueGreen: {}
+  podTemplatePatch:
+    patch: '{"spec":{"hostname":"podinfo"}}'
+`,
+			expectedStatus: http.StatusOK,
+			expectedKinds:  []string{"ServiceAccount", "Deployment", "Service", "Service"},
+		},
+		{
+			name: "profile enabling redis",
+			body: `
+metadata:
+  name: whatever
+  namespace: default
+spec:
+  profileRef:
+    name: with-redis
+  image:
+    repository: ghcr.io/stefanprodan/podinfo
+    tag: latest
+  podTemplatePatch:
+    patch: '{"spec":{"hostname":"podinfo"}}'
+`,
+			expectedStatus: http.StatusOK,
+			expectedKinds:  []string{"ServiceAccount", "StatefulSet", "Service", "Deployment", "Service"},
+		},
+		{
+			name:           "missing profile",
+			body:           `{"metadata":{"name":"whatever","namespace":"default"},"spec":{"profileRef":{"name":"missing"}}}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "missing namespace",
+			body:           `{"metadata":{"name":"whatever"}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(tc.body)))
+
+			if recorder.Code != tc.expectedStatus {
+				t.Fatalf("render: expected status %d, got %d: %s", tc.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			list := struct {
+				Items []unstructured.Unstructured `json:"items"`
+			}{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+				t.Fatalf("render: failed to decode the response: %v", err)
+			}
+			var kinds []string
+			for _, item := range list.Items {
+				kinds = append(kinds, item.GetKind())
+				if item.GetKind() == "Deployment" {
+					if hostname, _, _ := unstructured.NestedString(item.Object, "spec", "template", "spec", "hostname"); hostname != "podinfo" {
+						t.Errorf("render: expected the deployment to be patched, got hostname %q", hostname)
+					}
+				}
+			}
+			if diff := cmp.Diff(tc.expectedKinds, kinds); diff != "" {
+				t.Errorf("render: kinds mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+)
+
+// revisionAnnotation is set by the Deployment controller to the revision being rolled out.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// progressDeadlineExceededReason is the reason of the Progressing condition of a Deployment that exceeded its progress deadline.
+const progressDeadlineExceededReason = "ProgressDeadlineExceeded"
+
+// rolloutPollInterval is the interval the podinfo Deployment is checked at while it rolls out, as only its readiness
+// transitions are reconciled and the replica counts reported in the status would otherwise freeze until it completes.
+const rolloutPollInterval = 10 * time.Second
+
+// The reasons of the Degraded condition.
+const (
+	reasonRolloutComplete   = "RolloutComplete"
+	reasonRolloutInProgress = "RolloutInProgress"
+)
+
+// updateRolloutStatus reports the rollout progress of the podinfo deployment in the status of the instance, and
+// returns the delay to check it again at while the rollout is in progress.
+// The rollout status and the Degraded condition are cleared when the deployment doesn't exist.
+func (r *MyAppResourceReconciler) updateRolloutStatus(ctx context.Context, o *myapigroupv1alpha1.MyAppResource, local *appsv1.Deployment) (time.Duration, error) {
+	deployment := &appsv1.Deployment{}
+	if err := lookupDeployment(r.Client, ctx, local, deployment); err != nil {
+		return 0, fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
+	}
+
+	if deployment.Name == "" {
+		o.Status.Rollout = nil
+		meta.RemoveStatusCondition(&o.Status.Conditions, myapigroupv1alpha1.ConditionTypeDegraded)
+		return 0, nil
+	}
+
+	status, condition := generateRolloutStatus(deployment)
+	condition.ObservedGeneration = o.Generation
+	if previous := meta.FindStatusCondition(o.Status.Conditions, myapigroupv1alpha1.ConditionTypeDegraded); previous == nil || previous.Reason != condition.Reason {
+		switch {
+		case condition.Status == metav1.ConditionTrue:
+			recordEvent(ctx, corev1.EventTypeWarning, reasonRolloutDegraded, "deployment %s is degraded: %s", deployment.Name, condition.Message)
+		case condition.Reason == reasonRolloutComplete && previous != nil:
+			recordEvent(ctx, corev1.EventTypeNormal, reasonRolloutComplete, "deployment %s rolled out revision %s", deployment.Name, status.Revision)
+		}
+	}
+	o.Status.Rollout = status
+	meta.SetStatusCondition(&o.Status.Conditions, condition)
+	if condition.Reason == reasonRolloutInProgress {
+		return rolloutPollInterval, nil
+	}
+	return 0, nil
+}
+
+// generateRolloutStatus generates the rollout status and the Degraded condition from the status of the deployment.
+func generateRolloutStatus(deployment *appsv1.Deployment) (*myapigroupv1alpha1.RolloutStatus, metav1.Condition) {
+	status := &myapigroupv1alpha1.RolloutStatus{
+		Revision:          deployment.Annotations[revisionAnnotation],
+		Replicas:          deployment.Status.Replicas,
+		UpdatedReplicas:   deployment.Status.UpdatedReplicas,
+		ReadyReplicas:     deployment.Status.ReadyReplicas,
+		AvailableReplicas: deployment.Status.AvailableReplicas,
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse && condition.Reason == progressDeadlineExceededReason {
+			return status, metav1.Condition{
+				Type:    myapigroupv1alpha1.ConditionTypeDegraded,
+				Status:  metav1.ConditionTrue,
+				Reason:  progressDeadlineExceededReason,
+				Message: condition.Message,
+			}
+		}
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ObservedGeneration >= deployment.Generation && deployment.Status.UpdatedReplicas == desired &&
+		deployment.Status.Replicas == desired && deployment.Status.AvailableReplicas == desired {
+		return status, metav1.Condition{
+			Type:    myapigroupv1alpha1.ConditionTypeDegraded,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonRolloutComplete,
+			Message: fmt.Sprintf("revision %s is available", status.Revision),
+		}
+	}
+
+	return status, metav1.Condition{
+		Type:   myapigroupv1alpha1.ConditionTypeDegraded,
+		Status: metav1.ConditionFalse,
+		Reason: reasonRolloutInProgress,
+		Message: fmt.Sprintf("%d of %d replicas updated, %d available",
+			deployment.Status.UpdatedReplicas, desired, deployment.Status.AvailableReplicas),
+	}
+}
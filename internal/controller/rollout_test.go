@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func TestGenerateRolloutStatus(t *testing.T) {
+	newDeployment := func(status appsv1.DeploymentStatus) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Generation:  2,
+				Annotations: map[string]string{revisionAnnotation: "3"},
+			},
+			Spec:   appsv1.DeploymentSpec{Replicas: utils.Ptr[int32](3)},
+			Status: status,
+		}
+	}
+
+	for _, tc := range []struct {
+		name              string
+		argDeployment     *appsv1.Deployment
+		expectedStatus    *myapigroupv1alpha1.RolloutStatus
+		expectedCondition metav1.Condition
+	}{
+		{
+			name: "complete rollout",
+			argDeployment: newDeployment(appsv1.DeploymentStatus{
+				ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3,
+			}),
+			expectedStatus: &myapigroupv1alpha1.RolloutStatus{Revision: "3", Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3},
+			expectedCondition: metav1.Condition{
+				Type:    myapigroupv1alpha1.ConditionTypeDegraded,
+				Status:  metav1.ConditionFalse,
+				Reason:  reasonRolloutComplete,
+				Message: "revision 3 is available",
+			},
+		},
+		{
+			name: "rollout in progress",
+			argDeployment: newDeployment(appsv1.DeploymentStatus{
+				ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1, ReadyReplicas: 3, AvailableReplicas: 3,
+			}),
+			expectedStatus: &myapigroupv1alpha1.RolloutStatus{Revision: "3", Replicas: 4, UpdatedReplicas: 1, ReadyReplicas: 3, AvailableReplicas: 3},
+			expectedCondition: metav1.Condition{
+				Type:    myapigroupv1alpha1.ConditionTypeDegraded,
+				Status:  metav1.ConditionFalse,
+				Reason:  reasonRolloutInProgress,
+				Message: "1 of 3 replicas updated, 3 available",
+			},
+		},
+		{
+			name: "progress deadline exceeded",
+			argDeployment: newDeployment(appsv1.DeploymentStatus{
+				ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1, ReadyReplicas: 3, AvailableReplicas: 3,
+				Conditions: []appsv1.DeploymentCondition{
+					{
+						Type:    appsv1.DeploymentProgressing,
+						Status:  corev1.ConditionFalse,
+						Reason:  progressDeadlineExceededReason,
+						Message: `ReplicaSet "whatever-podinfo-7d9c" has timed out progressing.`,
+					},
+				},
+			}),
+			expectedStatus: &myapigroupv1alpha1.RolloutStatus{Revision: "3", Replicas: 4, UpdatedReplicas: 1, ReadyReplicas: 3, AvailableReplicas: 3},
+			expectedCondition: metav1.Condition{
+				Type:    myapigroupv1alpha1.ConditionTypeDegraded,
+				Status:  metav1.ConditionTrue,
+				Reason:  progressDeadlineExceededReason,
+				Message: `ReplicaSet "whatever-podinfo-7d9c" has timed out progressing.`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, condition := generateRolloutStatus(tc.argDeployment)
+
+			if diff := cmp.Diff(tc.expectedStatus, status); diff != "" {
+				t.Errorf("generateRolloutStatus: status mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedCondition, condition); diff != "" {
+				t.Errorf("generateRolloutStatus: condition mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateRolloutStatus(t *testing.T) {
+	c := newMemoryClient()
+	r := &MyAppResourceReconciler{Client: c}
+	o := newSyncOwner()
+	local := newSyncDeployment(2)
+	if err := syncK8sDeployment(c, context.Background(), o, local); err != nil {
+		t.Fatalf("syncK8sDeployment: unexpected error %v", err)
+	}
+
+	// the status of the deployment changes after the sync, without any event reconciled until the rollout completes
+	for _, tc := range []struct {
+		name            string
+		status          appsv1.DeploymentStatus
+		expectedStatus  *myapigroupv1alpha1.RolloutStatus
+		expectedReason  string
+		expectedRequeue time.Duration
+	}{
+		{
+			name:            "created",
+			expectedStatus:  &myapigroupv1alpha1.RolloutStatus{},
+			expectedReason:  reasonRolloutInProgress,
+			expectedRequeue: rolloutPollInterval,
+		},
+		{
+			name:            "rolling out",
+			status:          appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 1, AvailableReplicas: 1},
+			expectedStatus:  &myapigroupv1alpha1.RolloutStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 1, AvailableReplicas: 1},
+			expectedReason:  reasonRolloutInProgress,
+			expectedRequeue: rolloutPollInterval,
+		},
+		{
+			name:           "rolled out",
+			status:         appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2},
+			expectedStatus: &myapigroupv1alpha1.RolloutStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2},
+			expectedReason: reasonRolloutComplete,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c.deployments[client.ObjectKeyFromObject(local)].Status = tc.status
+
+			requeueAfter, err := r.updateRolloutStatus(context.Background(), o, local)
+			if err != nil {
+				t.Fatalf("updateRolloutStatus: unexpected error %v", err)
+			}
+			if requeueAfter != tc.expectedRequeue {
+				t.Errorf("updateRolloutStatus: expected a requeue after %s, got %s", tc.expectedRequeue, requeueAfter)
+			}
+			if diff := cmp.Diff(tc.expectedStatus, o.Status.Rollout); diff != "" {
+				t.Errorf("updateRolloutStatus: status mismatch (-want +got):\n%s", diff)
+			}
+			if condition := meta.FindStatusCondition(o.Status.Conditions, myapigroupv1alpha1.ConditionTypeDegraded); condition == nil || condition.Reason != tc.expectedReason {
+				t.Errorf("updateRolloutStatus: expected the %s reason, got %v", tc.expectedReason, condition)
+			}
+		})
+	}
+}
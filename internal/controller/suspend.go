@@ -0,0 +1,71 @@
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// pausedAnnotation pauses the instance when set to "true", like spec.paused.
+const pausedAnnotation = "myapp.example/paused"
+
+// The reasons of the Paused and Suspended conditions.
+const (
+	reasonPausedBySpec       = "PausedBySpec"
+	reasonPausedByAnnotation = "PausedByAnnotation"
+	reasonSuspended          = "Suspended"
+)
+
+// updatePausedCondition sets the Paused condition of the instance if it is paused, and removes it otherwise.
+// It returns true if the instance is paused.
+func updatePausedCondition(o *myapigroupv1alpha1.MyAppResource) bool {
+	condition := metav1.Condition{
+		Type:               myapigroupv1alpha1.ConditionTypePaused,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: o.Generation,
+	}
+	switch {
+	case o.Spec.Paused:
+		condition.Reason, condition.Message = reasonPausedBySpec, "spec.paused is set, the managed objects aren't synced"
+	case o.Annotations[pausedAnnotation] == "true":
+		condition.Reason, condition.Message = reasonPausedByAnnotation, pausedAnnotation+" is set, the managed objects aren't synced"
+	default:
+		meta.RemoveStatusCondition(&o.Status.Conditions, myapigroupv1alpha1.ConditionTypePaused)
+		return false
+	}
+
+	meta.SetStatusCondition(&o.Status.Conditions, condition)
+	return true
+}
+
+// suspendWorkloads scales the podinfo deployments, and the redis statefulset if requested, down to zero while the
+// instance is suspended, and reports it in the Suspended condition. The deployments without pod template, which
+// aren't synced, are left untouched.
+func suspendWorkloads(o *myapigroupv1alpha1.MyAppResource, spec *myapigroupv1alpha1.MyAppResourceSpec, deployments []*appsv1.Deployment, statefulSet *appsv1.StatefulSet) {
+	if !spec.Suspend {
+		meta.RemoveStatusCondition(&o.Status.Conditions, myapigroupv1alpha1.ConditionTypeSuspended)
+		return
+	}
+
+	for _, deployment := range deployments {
+		if len(deployment.Spec.Template.Spec.Containers) > 0 {
+			deployment.Spec.Replicas = utils.Ptr[int32](0)
+		}
+	}
+	message := "the frontend is scaled down to zero"
+	if spec.Redis != nil && utils.Deref(spec.Redis.Enabled) && utils.Deref(spec.Redis.Suspend) {
+		statefulSet.Spec.Replicas = utils.Ptr[int32](0)
+		message = "the frontend and redis are scaled down to zero"
+	}
+
+	meta.SetStatusCondition(&o.Status.Conditions, metav1.Condition{
+		Type:               myapigroupv1alpha1.ConditionTypeSuspended,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonSuspended,
+		Message:            message,
+		ObservedGeneration: o.Generation,
+	})
+}
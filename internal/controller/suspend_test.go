@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func TestUpdatePausedCondition(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		paused         bool
+		annotations    map[string]string
+		expectedReason string
+	}{
+		{name: "not paused"},
+		{name: "paused by spec", paused: true, expectedReason: reasonPausedBySpec},
+		{name: "paused by annotation", annotations: map[string]string{pausedAnnotation: "true"}, expectedReason: reasonPausedByAnnotation},
+		{name: "annotation set to false", annotations: map[string]string{pausedAnnotation: "false"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &myapigroupv1alpha1.MyAppResource{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+				Spec:       myapigroupv1alpha1.MyAppResourceSpec{Paused: tc.paused},
+				Status: myapigroupv1alpha1.MyAppResourceStatus{Conditions: []metav1.Condition{
+					{Type: myapigroupv1alpha1.ConditionTypePaused, Status: metav1.ConditionTrue, Reason: reasonPausedBySpec},
+				}},
+			}
+
+			paused := updatePausedCondition(o)
+			condition := meta.FindStatusCondition(o.Status.Conditions, myapigroupv1alpha1.ConditionTypePaused)
+			if paused != (tc.expectedReason != "") {
+				t.Errorf("updatePausedCondition: expected paused %t, got %t", tc.expectedReason != "", paused)
+			}
+			if tc.expectedReason == "" && condition != nil {
+				t.Errorf("updatePausedCondition: expected the condition to be removed, got %v", condition)
+			}
+			if tc.expectedReason != "" && (condition == nil || condition.Reason != tc.expectedReason) {
+				t.Errorf("updatePausedCondition: expected reason %s, got %v", tc.expectedReason, condition)
+			}
+		})
+	}
+}
+
+func TestSuspendWorkloads(t *testing.T) {
+	newDeployment := func(containers int) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: utils.Ptr[int32](3)}}
+		for i := 0; i < containers; i++ {
+			deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{})
+		}
+		return deployment
+	}
+
+	for _, tc := range []struct {
+		name                  string
+		spec                  myapigroupv1alpha1.MyAppResourceSpec
+		expectedReplicas      int32
+		expectedRedisReplicas int32
+		expectedSuspended     bool
+	}{
+		{
+			name:                  "not suspended",
+			spec:                  myapigroupv1alpha1.MyAppResourceSpec{Redis: &myapigroupv1alpha1.Redis{Enabled: utils.Ptr(true), Suspend: utils.Ptr(true)}},
+			expectedReplicas:      3,
+			expectedRedisReplicas: 1,
+		},
+		{
+			name:                  "frontend suspended",
+			spec:                  myapigroupv1alpha1.MyAppResourceSpec{Suspend: true, Redis: &myapigroupv1alpha1.Redis{Enabled: utils.Ptr(true)}},
+			expectedReplicas:      0,
+			expectedRedisReplicas: 1,
+			expectedSuspended:     true,
+		},
+		{
+			name:                  "frontend and redis suspended",
+			spec:                  myapigroupv1alpha1.MyAppResourceSpec{Suspend: true, Redis: &myapigroupv1alpha1.Redis{Enabled: utils.Ptr(true), Suspend: utils.Ptr(true)}},
+			expectedReplicas:      0,
+			expectedRedisReplicas: 0,
+			expectedSuspended:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &myapigroupv1alpha1.MyAppResource{}
+			deployment, empty := newDeployment(1), newDeployment(0)
+			statefulSet := &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: utils.Ptr[int32](1)}}
+
+			suspendWorkloads(o, &tc.spec, []*appsv1.Deployment{deployment, empty}, statefulSet)
+
+			if *deployment.Spec.Replicas != tc.expectedReplicas {
+				t.Errorf("suspendWorkloads: expected %d replicas, got %d", tc.expectedReplicas, *deployment.Spec.Replicas)
+			}
+			if *empty.Spec.Replicas != 3 {
+				t.Errorf("suspendWorkloads: expected the deployment without pod template to be left untouched, got %d replicas", *empty.Spec.Replicas)
+			}
+			if *statefulSet.Spec.Replicas != tc.expectedRedisReplicas {
+				t.Errorf("suspendWorkloads: expected %d redis replicas, got %d", tc.expectedRedisReplicas, *statefulSet.Spec.Replicas)
+			}
+			if suspended := meta.IsStatusConditionTrue(o.Status.Conditions, myapigroupv1alpha1.ConditionTypeSuspended); suspended != tc.expectedSuspended {
+				t.Errorf("suspendWorkloads: expected suspended %t, got %t", tc.expectedSuspended, suspended)
+			}
+		})
+	}
+}
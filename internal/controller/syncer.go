@@ -2,167 +2,262 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"knative.dev/pkg/kmp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
 )
 
-func syncK8sDeployment(k8sClient client.Client, ctx context.Context, local *appsv1.Deployment) error {
-	logger := log.FromContext(ctx)
-	logger.WithValues("name", local.GetName(), "kind", local.GetObjectKind())
-	remote := &appsv1.Deployment{}
-	if err := lookupDeployment(k8sClient, ctx, local, remote); err != nil {
-		return fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
-	}
+// desiredHashAnnotation records the hash of the rendered desired state on the managed objects, so that unchanged
+// objects are recognised from the cache without submitting them to the API server.
+const desiredHashAnnotation = "my.api.group/desired-hash"
 
-	// Candidate for create
-	if remote.Name == "" {
-		if err := k8sClient.Create(ctx, local); err != nil {
-			return fmt.Errorf("failed to create resource: %w", err)
+func syncK8sDeployment(k8sClient client.Client, ctx context.Context, owner *myapigroupv1alpha1.MyAppResource, local *appsv1.Deployment) error {
+	return syncK8sObject(k8sClient, ctx, "syncK8sDeployment", owner, local, &appsv1.Deployment{}, func(object client.Object) interface{} {
+		return object.(*appsv1.Deployment).Spec
+	})
+}
 
-		}
+func syncK8sService(k8sClient client.Client, ctx context.Context, owner *myapigroupv1alpha1.MyAppResource, local *corev1.Service) error {
+	return syncK8sObject(k8sClient, ctx, "syncK8sService", owner, local, &corev1.Service{}, func(object client.Object) interface{} {
+		return object.(*corev1.Service).Spec
+	})
+}
 
-		logger.Info("deployment created successfully")
-		return nil
-	}
+func syncK8sServiceAccount(k8sClient client.Client, ctx context.Context, owner *myapigroupv1alpha1.MyAppResource, local *corev1.ServiceAccount) error {
+	// service accounts have no spec, so the fields set by the operator are compared instead.
+	return syncK8sObject(k8sClient, ctx, "syncK8sServiceAccount", owner, local, &corev1.ServiceAccount{}, func(object client.Object) interface{} {
+		serviceAccount := object.(*corev1.ServiceAccount)
+		return []interface{}{serviceAccount.Annotations, serviceAccount.AutomountServiceAccountToken, serviceAccount.ImagePullSecrets}
+	})
+}
 
-	// Candidate for update
-	if err := k8sClient.Update(ctx, local, client.DryRunAll); err != nil {
-		return fmt.Errorf("failed to dry-run update resource: %w", err)
-	}
-	diff, err := kmp.SafeDiff(remote.Spec, local.Spec)
-	if err != nil {
-		return fmt.Errorf("failed to diff resurces: %w", err)
-	}
+func syncK8sConfigMap(k8sClient client.Client, ctx context.Context, owner *myapigroupv1alpha1.MyAppResource, local *corev1.ConfigMap) error {
+	// config maps have no spec, so their data is compared instead.
+	return syncK8sObject(k8sClient, ctx, "syncK8sConfigMap", owner, local, &corev1.ConfigMap{}, func(object client.Object) interface{} {
+		return object.(*corev1.ConfigMap).Data
+	})
+}
 
-	if diff != "" {
-		logger.Info("submitted resource for update", "diff", diff)
-		if err := k8sClient.Update(ctx, local); err != nil {
-			return fmt.Errorf("failed to update resource: %w", err)
-		}
-	} else {
-		logger.Info("no changes detected")
-	}
+func syncK8sStatefulset(k8sClient client.Client, ctx context.Context, owner *myapigroupv1alpha1.MyAppResource, local *appsv1.StatefulSet) error {
+	return syncK8sObject(k8sClient, ctx, "syncK8sStatefulset", owner, local, &appsv1.StatefulSet{}, func(object client.Object) interface{} {
+		return object.(*appsv1.StatefulSet).Spec
+	})
+}
 
-	return nil
+// syncK8sUnstructured syncs objects whose kinds are not registered in the scheme, such as Prometheus Operator resources.
+// Only the spec field is compared, and the remote resource version is carried over since custom resources reject unconditional updates.
+func syncK8sUnstructured(k8sClient client.Client, ctx context.Context, owner *myapigroupv1alpha1.MyAppResource, local *unstructured.Unstructured) error {
+	remote := &unstructured.Unstructured{}
+	remote.SetGroupVersionKind(local.GroupVersionKind())
+	return syncK8sObject(k8sClient, ctx, "syncK8sUnstructured", owner, local, remote, func(object client.Object) interface{} {
+		return object.(*unstructured.Unstructured).Object["spec"]
+	})
 }
 
-func syncK8sService(k8sClient client.Client, ctx context.Context, local *corev1.Service) error {
-	logger := log.FromContext(ctx)
-	logger.WithValues("name", local.GetName(), "kind", local.GetObjectKind())
-	remote := &corev1.Service{}
-	if err := lookupService(k8sClient, ctx, local, remote); err != nil {
-		return fmt.Errorf("failed to lookup deployment %s: %w", local.GetName(), err)
+// syncK8sObject syncs the rendered object with the remote one, comparing the fields returned by fields.
+//
+// The hash of the rendered object is recorded in the desired-hash annotation. A remote object read from the cache
+// with the same hash, and whose generation (or resource version for the kinds without generation) was already
+// observed by a previous sync, is left untouched without any API call. A changed hash is submitted as an update
+// right away. An unchanged hash with an unobserved version, after a change made outside of the operator or a
+// restart, is checked with a dry-run update first, and the object is updated only if its fields drifted. The
+// rendered labels and annotations changed outside of the operator are updated right away too. The objects
+// are labeled as managed by the operator, which scopes the cache of the manager to them, and controlled by the
+// instance, so that their events are reconciled and they are garbage collected along with it. An existing object
+// missing from the cache is read from the API server and adopted if it is adoptable, and an existing object that
+// isn't managed by the instance is never updated.
+func syncK8sObject(k8sClient client.Client, ctx context.Context, operation string, owner *myapigroupv1alpha1.MyAppResource, local client.Object, remote client.Object, fields func(client.Object) interface{}) (err error) {
+	logger := log.FromContext(ctx).WithValues("name", local.GetName(), "kind", getObjectKind(local))
+	ctx, outcome := newSyncOutcome(ctx, operation, local)
+	defer func() { outcome.done(err) }()
+
+	annotations := map[string]string{}
+	for key, value := range local.GetAnnotations() {
+		annotations[key] = value
+	}
+	annotations[desiredHashAnnotation] = outcome.hash
+	local.SetAnnotations(annotations)
+	objectLabels := map[string]string{}
+	for key, value := range local.GetLabels() {
+		objectLabels[key] = value
+	}
+	objectLabels[managedByLabel] = managedByLabelValue
+	local.SetLabels(objectLabels)
+	local.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(owner, myapigroupv1alpha1.GroupVersion.WithKind("MyAppResource"))})
+
+	if err := lookupObject(k8sClient, ctx, local, remote); err != nil {
+		return fmt.Errorf("failed to lookup %s %s: %w", getObjectKind(local), local.GetName(), err)
 	}
 
 	// Candidate for create
-	if remote.Name == "" {
-		if err := k8sClient.Create(ctx, local); err != nil {
+	adopted := false
+	if remote.GetName() == "" {
+		err := k8sClient.Create(ctx, local)
+		if apierrors.IsAlreadyExists(err) {
+			// the cache only holds the objects labeled as managed by the operator, so the existing object is read
+			// from the API server, e.g. an object created before the label was set or whose label was removed.
+			if err := getAPIReader(ctx, k8sClient).Get(ctx, client.ObjectKeyFromObject(local), remote); err != nil {
+				return fmt.Errorf("failed to lookup %s %s: %w", getObjectKind(local), local.GetName(), err)
+			}
+			if !isAdoptable(remote, local, owner.Name) {
+				recordEvent(ctx, corev1.EventTypeWarning, reasonNotManaged, "%s %s already exists and isn't managed by the instance", getObjectKind(local), local.GetName())
+				return fmt.Errorf("%s %s already exists and isn't managed by the instance", getObjectKind(local), local.GetName())
+			}
+			logger.Info("adopting resource missing the managed-by label")
+			adopted = true
+		} else if err != nil {
 			return fmt.Errorf("failed to create resource: %w", err)
-
+		} else {
+			logger.Info("resource created successfully")
+			outcome.created(local)
+			recordEvent(ctx, corev1.EventTypeNormal, reasonCreated, "created %s %s", getObjectKind(local), local.GetName())
+			return nil
 		}
+	}
 
-		logger.Info("deployment created successfully")
+	if !adopted && !isManagedBy(remote, owner.Name) {
+		recordEvent(ctx, corev1.EventTypeWarning, reasonNotManaged, "%s %s is controlled by another owner", getObjectKind(local), local.GetName())
+		return fmt.Errorf("%s %s is controlled by another owner", getObjectKind(local), local.GetName())
+	}
+
+	// the objects synced before the owner references were set are adopted, their hash predates the reference. The
+	// changes of the metadata don't bump the generation, so the rendered labels and annotations are checked as well.
+	hashed := remote.GetAnnotations()[desiredHashAnnotation] == outcome.hash && metav1.IsControlledBy(remote, owner)
+	metadataDrifted := hashed && !hasMetadata(remote, local)
+	unchanged := hashed && !metadataDrifted
+	if unchanged && outcome.observed(remote) {
+		logger.Info("no changes detected")
+		outcome.unchanged(remote)
 		return nil
 	}
 
 	// Candidate for update
-	if err := k8sClient.Update(ctx, local, client.DryRunAll); err != nil {
-		return fmt.Errorf("failed to dry-run update resource: %w", err)
+	if _, ok := local.(*unstructured.Unstructured); ok {
+		local.SetResourceVersion(remote.GetResourceVersion())
 	}
-
-	diff, err := kmp.SafeDiff(remote.Spec, local.Spec)
-	if err != nil {
-		return fmt.Errorf("failed to diff resurces: %w", err)
+	// the volume claims of a statefulset can't be updated, e.g. once the default storage class changed.
+	if statefulSet, ok := local.(*appsv1.StatefulSet); ok {
+		statefulSet.Spec.VolumeClaimTemplates = remote.(*appsv1.StatefulSet).Spec.VolumeClaimTemplates
 	}
-	if diff != "" {
-		logger.Info("submitted resource for update", "diff", diff)
-		if err := k8sClient.Update(ctx, local); err != nil {
-			return fmt.Errorf("failed to update resource: %w", err)
+	if unchanged {
+		if err := dryRunUpdate(k8sClient, ctx, local); err != nil {
+			return fmt.Errorf("failed to dry-run update resource: %w", err)
 		}
-	} else {
-		logger.Info("no changes detected")
+		diff, err := kmp.SafeDiff(fields(remote), fields(local))
+		if err != nil {
+			return fmt.Errorf("failed to diff resurces: %w", err)
+		}
+		if diff == "" {
+			logger.Info("no changes detected")
+			outcome.unchanged(remote)
+			return nil
+		}
+		logger.Info("submitted drifted resource for update", "diff", diff)
 	}
 
+	if err := k8sClient.Update(ctx, local); err != nil {
+		return fmt.Errorf("failed to update resource: %w", err)
+	}
+	// the updated object carries the defaults set by the API server, so it is compared to the remote one.
+	diff, err := kmp.SafeDiff(fields(remote), fields(local))
+	if err != nil {
+		return fmt.Errorf("failed to diff resurces: %w", err)
+	}
+	logger.Info("updated resource", "diff", diff)
+	outcome.updated(local, unchanged || metadataDrifted)
+	recordEvent(ctx, corev1.EventTypeNormal, reasonUpdated, "updated %s %s: %s", getObjectKind(local), local.GetName(), summarizeDiff(diff))
 	return nil
 }
 
-func syncK8sStatefulset(k8sClient client.Client, ctx context.Context, local *appsv1.StatefulSet) error {
-	logger := log.FromContext(ctx)
-	logger.WithValues("name", local.GetName(), "kind", local.GetObjectKind())
-	remote := &appsv1.StatefulSet{}
-	if err := lookupStatefulset(k8sClient, ctx, local, remote); err != nil {
-		return fmt.Errorf("failed to lookup statefulset %s: %w", local.GetName(), err)
+// isManagedBy returns true if the object is controlled by the instance of the given name, or labeled as managed by
+// the operator without any controller, like the objects synced before the owner references were set. The name is
+// compared rather than the uid, so that the objects of a deleted instance are still recognised.
+func isManagedBy(object metav1.Object, instance string) bool {
+	if controller := metav1.GetControllerOf(object); controller != nil {
+		return controller.APIVersion == myapigroupv1alpha1.GroupVersion.String() && controller.Kind == "MyAppResource" && controller.Name == instance
 	}
+	return object.GetLabels()[managedByLabel] == managedByLabelValue
+}
 
-	// Candidate for create
-	if remote.Name == "" {
-		if err := k8sClient.Create(ctx, local); err != nil {
-			return fmt.Errorf("failed to create resource: %w", err)
-
+// hasMetadata returns true if the remote object carries the labels and annotations rendered for it. The ones set by
+// others, e.g. the revision annotation of the deployments, are ignored.
+func hasMetadata(remote metav1.Object, local metav1.Object) bool {
+	for key, value := range local.GetLabels() {
+		if current, found := remote.GetLabels()[key]; !found || current != value {
+			return false
 		}
-
-		logger.Info("statefulset created successfully")
-		return nil
 	}
-
-	// Candidate for update
-	if err := k8sClient.Update(ctx, local, client.DryRunAll); err != nil {
-		return fmt.Errorf("failed to dry-run update resource: %w", err)
-	}
-
-	diff, err := kmp.SafeDiff(remote.Spec, local.Spec)
-	if err != nil {
-		return fmt.Errorf("failed to diff resurces: %w", err)
-	}
-	if diff != "" {
-		logger.Info("submitted resource for update", "diff", diff)
-		if err := k8sClient.Update(ctx, local); err != nil {
-			return fmt.Errorf("failed to update resource: %w", err)
+	for key, value := range local.GetAnnotations() {
+		if current, found := remote.GetAnnotations()[key]; !found || current != value {
+			return false
 		}
-	} else {
-		logger.Info("no changes detected")
 	}
-
-	return nil
+	return true
 }
 
-func lookupDeployment(k8sClient client.Client, ctx context.Context, local *appsv1.Deployment, remote *appsv1.Deployment) error {
+// generatedLabels are set on every rendered object, by the previous versions of the operator as well.
+var generatedLabels = []string{"app.kubernetes.io/name", "app.kubernetes.io/namespace"}
 
-	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(local), remote); err != nil {
-		if apierrors.IsNotFound(err) {
-			return nil
-
-		} else {
-			return fmt.Errorf("failed to lookup resource: %w", err)
+// isAdoptable returns true if the existing object missing from the cache can be adopted by the instance: it is
+// managed by the instance but lost its managed-by label, or it has no controller and carries the labels generated
+// for the rendered object, like the objects created before the operator labeled and owned them.
+func isAdoptable(remote metav1.Object, local metav1.Object, instance string) bool {
+	if metav1.GetControllerOf(remote) != nil {
+		return isManagedBy(remote, instance)
+	}
+	for _, key := range generatedLabels {
+		value, found := local.GetLabels()[key]
+		if !found || remote.GetLabels()[key] != value {
+			return false
 		}
-
 	}
-
-	return nil
+	return true
 }
 
-func lookupStatefulset(k8sClient client.Client, ctx context.Context, local *appsv1.StatefulSet, remote *appsv1.StatefulSet) error {
+// apiReaderKey carries the reader of the objects left out of the cache in the context of the syncs.
+type apiReaderKey struct{}
 
-	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(local), remote); err != nil {
-		if apierrors.IsNotFound(err) {
-			return nil
+// withAPIReader returns a context carrying the reader of the objects left out of the cache.
+func withAPIReader(ctx context.Context, reader client.Reader) context.Context {
+	return context.WithValue(ctx, apiReaderKey{}, reader)
+}
 
-		} else {
-			return fmt.Errorf("failed to lookup resource: %w", err)
-		}
+// getAPIReader returns the reader of the objects left out of the cache carried by the context, or the client.
+func getAPIReader(ctx context.Context, k8sClient client.Client) client.Reader {
+	if reader, ok := ctx.Value(apiReaderKey{}).(client.Reader); ok && reader != nil {
+		return reader
+	}
+	return k8sClient
+}
 
+// generateObjectHash hashes the rendered object.
+func generateObjectHash(object client.Object) string {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return ""
 	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
 
-	return nil
+func lookupDeployment(k8sClient client.Client, ctx context.Context, local *appsv1.Deployment, remote *appsv1.Deployment) error {
+	return lookupObject(k8sClient, ctx, local, remote)
 }
 
-func lookupService(k8sClient client.Client, ctx context.Context, local *corev1.Service, remote *corev1.Service) error {
+// lookupObject reads the remote object, which is left empty if it doesn't exist.
+func lookupObject(k8sClient client.Client, ctx context.Context, local client.Object, remote client.Object) (err error) {
+	ctx, span := startSpan(ctx, "lookup"+getObjectKind(local), local)
+	defer func() { endSpan(span, outcomeOf(err), err) }()
 
 	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(local), remote); err != nil {
 		if apierrors.IsNotFound(err) {
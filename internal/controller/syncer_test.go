@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	dto "github.com/prometheus/client_model/go"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/metrics"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// memoryClient keeps deployments in memory, defaulting them and bumping their generation when their spec changes
+// like the API server does, and counts the API calls.
+type memoryClient struct {
+	client.Client
+	deployments map[client.ObjectKey]*appsv1.Deployment
+	calls       map[string]int
+}
+
+func newMemoryClient() *memoryClient {
+	return &memoryClient{deployments: map[client.ObjectKey]*appsv1.Deployment{}, calls: map[string]int{}}
+}
+
+func (c *memoryClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.calls["get"]++
+	deployment, found := c.deployments[key]
+	if !found {
+		return apierrors.NewNotFound(appsv1.Resource("deployments"), key.Name)
+	}
+	deployment.DeepCopyInto(obj.(*appsv1.Deployment))
+	return nil
+}
+
+func (c *memoryClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.calls["create"]++
+	deployment := obj.(*appsv1.Deployment)
+	deployment.Generation, deployment.ResourceVersion = 1, "1"
+	defaultDeployment(deployment)
+	c.deployments[client.ObjectKeyFromObject(obj)] = deployment.DeepCopy()
+	return nil
+}
+
+func (c *memoryClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	options := &client.UpdateOptions{}
+	options.ApplyOptions(opts)
+	deployment, stored := obj.(*appsv1.Deployment), c.deployments[client.ObjectKeyFromObject(obj)]
+	defaultDeployment(deployment)
+	deployment.Generation, deployment.ResourceVersion = stored.Generation, stored.ResourceVersion
+	if len(options.DryRun) > 0 {
+		c.calls["dryRunUpdate"]++
+		return nil
+	}
+
+	c.calls["update"]++
+	if !equality.Semantic.DeepEqual(deployment.Spec, stored.Spec) {
+		deployment.Generation++
+	}
+	deployment.ResourceVersion = fmt.Sprint(len(c.calls) + c.calls["update"])
+	c.deployments[client.ObjectKeyFromObject(obj)] = deployment.DeepCopy()
+	return nil
+}
+
+// defaultDeployment sets a default like the API server does.
+func defaultDeployment(deployment *appsv1.Deployment) {
+	if deployment.Spec.RevisionHistoryLimit == nil {
+		deployment.Spec.RevisionHistoryLimit = utils.Ptr[int32](10)
+	}
+}
+
+// newSyncOwner returns the instance owning the synced objects.
+func newSyncOwner() *myapigroupv1alpha1.MyAppResource {
+	return &myapigroupv1alpha1.MyAppResource{ObjectMeta: metav1.ObjectMeta{Name: "whatever", Namespace: "syncer", UID: "whatever-uid"}}
+}
+
+func newSyncDeployment(replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "whatever-podinfo",
+			Namespace: "syncer",
+			Labels:    utils.GenerateDefaultLabels("whatever-podinfo", "syncer"),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: utils.Ptr(replicas),
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "podinfo", Image: "podinfo"}}}},
+		},
+	}
+}
+
+func TestSyncK8sObject(t *testing.T) {
+	c := newMemoryClient()
+	key := client.ObjectKey{Namespace: "syncer", Name: "whatever-podinfo"}
+	drifts := func() float64 {
+		m := &dto.Metric{}
+		if err := metrics.DriftCorrectionsTotal.WithLabelValues("Deployment").Write(m); err != nil {
+			t.Fatalf("Write: unexpected error: %v", err)
+		}
+		return m.GetCounter().GetValue()
+	}
+
+	for _, tc := range []struct {
+		name             string
+		replicas         int32
+		before           func()
+		expectedCalls    map[string]int
+		expectedReplicas int32
+		expectedDrift    float64
+	}{
+		{
+			name:             "missing deployment",
+			replicas:         1,
+			expectedCalls:    map[string]int{"get": 1, "create": 1},
+			expectedReplicas: 1,
+		},
+		{
+			name:             "unchanged deployment",
+			replicas:         1,
+			expectedCalls:    map[string]int{"get": 1},
+			expectedReplicas: 1,
+		},
+		{
+			name:             "changed desired state",
+			replicas:         2,
+			expectedCalls:    map[string]int{"get": 1, "update": 1},
+			expectedReplicas: 2,
+		},
+		{
+			name:     "deployment changed outside of the operator",
+			replicas: 2,
+			before: func() {
+				c.deployments[key].Spec.Replicas = utils.Ptr[int32](5)
+				c.deployments[key].Generation++
+			},
+			expectedCalls:    map[string]int{"get": 1, "dryRunUpdate": 1, "update": 1},
+			expectedReplicas: 2,
+			expectedDrift:    1,
+		},
+		{
+			name:             "first sync after a restart",
+			replicas:         2,
+			before:           func() { forgetObjects([]client.Object{newSyncDeployment(2)}) },
+			expectedCalls:    map[string]int{"get": 1, "dryRunUpdate": 1},
+			expectedReplicas: 2,
+		},
+		{
+			name:             "metadata changed outside of the operator",
+			replicas:         2,
+			before:           func() { c.deployments[key].Labels["app.kubernetes.io/name"] = "other" },
+			expectedCalls:    map[string]int{"get": 1, "update": 1},
+			expectedReplicas: 2,
+			expectedDrift:    1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.before != nil {
+				tc.before()
+			}
+			c.calls = map[string]int{}
+			before := drifts()
+
+			if err := syncK8sDeployment(c, context.Background(), newSyncOwner(), newSyncDeployment(tc.replicas)); err != nil {
+				t.Fatalf("syncK8sDeployment: unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expectedCalls, c.calls); diff != "" {
+				t.Errorf("syncK8sDeployment: calls mismatch (-want +got):\n%s", diff)
+			}
+			if replicas := *c.deployments[key].Spec.Replicas; replicas != tc.expectedReplicas {
+				t.Errorf("syncK8sDeployment: expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			if drift := drifts() - before; drift != tc.expectedDrift {
+				t.Errorf("syncK8sDeployment: expected %v drift corrections, got %v", tc.expectedDrift, drift)
+			}
+		})
+	}
+}
+
+// scopedClient serves the deployments from a cache holding only the ones labeled as managed by the operator, while
+// the API server, the embedded memory client, holds them all.
+type scopedClient struct {
+	memoryClient
+}
+
+func (c *scopedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if deployment, found := c.deployments[key]; found && deployment.Labels[managedByLabel] != managedByLabelValue {
+		c.calls["get"]++
+		return apierrors.NewNotFound(appsv1.Resource("deployments"), key.Name)
+	}
+	return c.memoryClient.Get(ctx, key, obj, opts...)
+}
+
+func (c *scopedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, found := c.deployments[client.ObjectKeyFromObject(obj)]; found {
+		c.calls["create"]++
+		return apierrors.NewAlreadyExists(appsv1.Resource("deployments"), obj.GetName())
+	}
+	return c.memoryClient.Create(ctx, obj, opts...)
+}
+
+func TestSyncK8sObjectOwnership(t *testing.T) {
+	owner := newSyncOwner()
+	key := client.ObjectKey{Namespace: "syncer", Name: "whatever-podinfo"}
+	existing := func(labels map[string]string, controller string) *memoryClient {
+		c := newMemoryClient()
+		deployment := newSyncDeployment(1)
+		deployment.Generation, deployment.ResourceVersion, deployment.Labels = 1, "1", labels
+		if controller != "" {
+			deployment.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(
+				&myapigroupv1alpha1.MyAppResource{ObjectMeta: metav1.ObjectMeta{Name: controller, UID: "other-uid"}},
+				myapigroupv1alpha1.GroupVersion.WithKind("MyAppResource"),
+			)}
+		}
+		c.deployments[key] = deployment
+		return c
+	}
+
+	for _, tc := range []struct {
+		name          string
+		client        *memoryClient
+		expectedCalls map[string]int
+		expectedError bool
+	}{
+		{
+			name:          "synced before the owner references were set",
+			client:        existing(map[string]string{managedByLabel: managedByLabelValue}, ""),
+			expectedCalls: map[string]int{"get": 1, "update": 1},
+		},
+		{
+			name:          "controlled by another instance",
+			client:        existing(map[string]string{managedByLabel: managedByLabelValue}, "other"),
+			expectedCalls: map[string]int{"get": 1},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := syncK8sDeployment(tc.client, context.Background(), owner, newSyncDeployment(2))
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("syncK8sDeployment: expected error %t, got %v", tc.expectedError, err)
+			}
+			if diff := cmp.Diff(tc.expectedCalls, tc.client.calls); diff != "" {
+				t.Errorf("syncK8sDeployment: calls mismatch (-want +got):\n%s", diff)
+			}
+			if !tc.expectedError && !metav1.IsControlledBy(tc.client.deployments[key], owner) {
+				t.Errorf("syncK8sDeployment: expected the deployment to be controlled by the instance, got %v", tc.client.deployments[key].OwnerReferences)
+			}
+		})
+	}
+
+	// the cache only holds the managed objects, the existing objects missing from it are read from the API server
+	generated := utils.GenerateDefaultLabels(key.Name, key.Namespace)
+	for _, tc := range []struct {
+		name          string
+		labels        map[string]string
+		controller    string
+		expectedError bool
+	}{
+		{name: "created before the managed-by label was set", labels: generated},
+		{name: "managed-by label removed", labels: generated, controller: "whatever"},
+		{name: "unrelated deployment", labels: map[string]string{"app": "other"}, expectedError: true},
+		{name: "unlabeled and controlled by another instance", labels: generated, controller: "other", expectedError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &scopedClient{*existing(tc.labels, tc.controller)}
+			ctx := withAPIReader(context.Background(), &c.memoryClient)
+			local := newSyncDeployment(2)
+			local.Labels = utils.GenerateDefaultLabels(key.Name, key.Namespace)
+
+			err := syncK8sDeployment(c, ctx, owner, local)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("syncK8sDeployment: expected error %t, got %v", tc.expectedError, err)
+			}
+			expectedCalls := map[string]int{"get": 2, "create": 1, "update": 1}
+			if tc.expectedError {
+				expectedCalls = map[string]int{"get": 2, "create": 1}
+			}
+			if diff := cmp.Diff(expectedCalls, c.calls); diff != "" {
+				t.Errorf("syncK8sDeployment: calls mismatch (-want +got):\n%s", diff)
+			}
+			deployment := c.deployments[key]
+			if adopted := metav1.IsControlledBy(deployment, owner) && deployment.Labels[managedByLabel] == managedByLabelValue; adopted == tc.expectedError {
+				t.Errorf("syncK8sDeployment: expected the deployment to be adopted %t, got labels %v and owners %v", !tc.expectedError, deployment.Labels, deployment.OwnerReferences)
+			}
+		})
+	}
+}
+
+// countingClient counts the requests sent to the API server.
+type countingClient struct {
+	client.Client
+	calls int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.calls++
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *countingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.calls++
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *countingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.calls++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// BenchmarkSyncK8sDeployment reports the API calls of the sync of an unchanged deployment against envtest, the
+// reads being served by the cache of the manager in the operator. It is skipped when the envtest binaries are missing.
+func BenchmarkSyncK8sDeployment(b *testing.B) {
+	env := &envtest.Environment{
+		BinaryAssetsDirectory: filepath.Join("..", "..", "bin", "k8s", fmt.Sprintf("1.29.0-%s-%s", runtime.GOOS, runtime.GOARCH)),
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		b.Skipf("envtest isn't available: %v", err)
+	}
+	defer func() { _ = env.Stop() }()
+	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		b.Fatalf("failed to create the client: %v", err)
+	}
+
+	owner := newSyncOwner()
+	owner.Namespace = "default"
+	deployment := newSyncDeployment(1)
+	deployment.Namespace = "default"
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "podinfo"}}
+	deployment.Spec.Template.Labels = deployment.Spec.Selector.MatchLabels
+	if err := syncK8sDeployment(k8sClient, context.Background(), owner, deployment.DeepCopy()); err != nil {
+		b.Fatalf("syncK8sDeployment: unexpected error: %v", err)
+	}
+
+	for _, bc := range []struct {
+		name   string
+		before func()
+	}{
+		// the dry-run update sent by every sync before the desired state hash, as on the first sync after a restart
+		{name: "dry-run", before: func() { forgetObjects([]client.Object{deployment}) }},
+		{name: "desired-hash", before: func() {}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := &countingClient{Client: k8sClient}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bc.before()
+				if err := syncK8sDeployment(c, context.Background(), owner, deployment.DeepCopy()); err != nil {
+					b.Fatalf("syncK8sDeployment: unexpected error: %v", err)
+				}
+			}
+			b.ReportMetric(float64(c.calls)/float64(b.N), "api-calls/op")
+		})
+	}
+}
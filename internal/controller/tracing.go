@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tracer traces the reconciles. It is a no-op until a tracer provider is set up.
+var tracer = otel.Tracer("github.com/aa-ang4335/myappresource-operator/internal/controller")
+
+// The attributes of the spans.
+const (
+	attributeNamespace = attribute.Key("namespace")
+	attributeName      = attribute.Key("name")
+	attributeKind      = attribute.Key("kind")
+	attributeOutcome   = attribute.Key("outcome")
+)
+
+// startSpan starts a span of the operation on the object.
+func startSpan(ctx context.Context, operation string, object client.Object) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation, trace.WithAttributes(
+		attributeNamespace.String(object.GetNamespace()),
+		attributeName.String(object.GetName()),
+		attributeKind.String(getObjectKind(object)),
+	))
+}
+
+// endSpan ends the span with the outcome of the operation, recording the error if it failed.
+func endSpan(span trace.Span, outcome string, err error) {
+	span.SetAttributes(attributeOutcome.String(outcome))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// dryRunUpdate submits the update of the object in dry-run mode, which fills in the defaults set by the API server.
+func dryRunUpdate(k8sClient client.Client, ctx context.Context, local client.Object) error {
+	ctx, span := startSpan(ctx, "dryRunUpdate", local)
+	err := k8sClient.Update(ctx, local, client.DryRunAll)
+	endSpan(span, outcomeOf(err), err)
+	return err
+}
+
+// outcomeOf returns the outcome of an operation which either succeeded or failed.
+func outcomeOf(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+)
+
+// updateClient serves a single deployment and accepts its updates.
+type updateClient struct {
+	deploymentClient
+}
+
+func (c *updateClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return nil
+}
+
+func TestSyncSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "whatever-podinfo", Namespace: "default"}}
+	remote := deployment.DeepCopy()
+	remote.Annotations = map[string]string{desiredHashAnnotation: generateObjectHash(deployment)}
+	remote.Labels = map[string]string{managedByLabel: managedByLabelValue}
+	owner := newSyncOwner()
+	remote.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, myapigroupv1alpha1.GroupVersion.WithKind("MyAppResource"))}
+	c := &updateClient{deploymentClient{deployment: remote}}
+	if err := syncK8sDeployment(c, context.Background(), owner, deployment); err != nil {
+		t.Fatalf("syncK8sDeployment: unexpected error: %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	sync := spans["syncK8sDeployment"]
+	if sync == nil {
+		t.Fatalf("syncK8sDeployment: expected a span, got %v", spans)
+	}
+	expected := []attribute.KeyValue{
+		attributeNamespace.String("default"),
+		attributeName.String("whatever-podinfo"),
+		attributeKind.String("Deployment"),
+		attributeOutcome.String("unchanged"),
+	}
+	if diff := cmp.Diff(expected, sync.Attributes(), cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("syncK8sDeployment: attributes mismatch (-want +got):\n%s", diff)
+	}
+	for _, name := range []string{"lookupDeployment", "dryRunUpdate"} {
+		if span := spans[name]; span == nil || span.Parent().SpanID() != sync.SpanContext().SpanID() {
+			t.Errorf("syncK8sDeployment: expected a %s child span, got %v", name, span)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package limitrange
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Validate checks container resource requirements against the Container constraints of the namespace's LimitRanges.
+// The LimitRange defaults are applied first, the same way the LimitRanger admission plugin does, so that the
+// result matches what the API server would admit.
+//
+// Parameters:
+//
+//	requirements: The rendered resource requirements of the container.
+//	limitRanges: The LimitRanges of the namespace the container is created in.
+//
+// Returns:
+//
+//	error: An error listing every violated constraint, or nil if the requirements are admitted.
+func Validate(requirements corev1.ResourceRequirements, limitRanges []corev1.LimitRange) error {
+	var errs error
+
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+
+			requests, limits := applyDefaults(requirements, item)
+			errs = errors.Join(errs, validateItem(limitRange.Name, "", item, requests, limits))
+		}
+	}
+
+	return errs
+}
+
+// ValidatePod checks the containers and the init containers of a pod against the Container constraints of the
+// namespace's LimitRanges, and the pod as a whole against their Pod constraints. The pod requests and limits are the
+// sum of its containers, or of its largest init container when higher as the init containers run one after the
+// other, once the LimitRange defaults are applied, the same way the LimitRanger admission plugin computes them.
+//
+// Parameters:
+//
+//	spec: The rendered spec of the pod.
+//	limitRanges: The LimitRanges of the namespace the pod is created in.
+//
+// Returns:
+//
+//	error: An error listing every violated constraint, or nil if the pod is admitted.
+func ValidatePod(spec corev1.PodSpec, limitRanges []corev1.LimitRange) error {
+	var errs error
+
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			if err := Validate(container.Resources, limitRanges); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("container %s: %w", container.Name, err))
+			}
+		}
+	}
+
+	for _, limitRange := range limitRanges {
+		// the defaults are those of the Container constraints, the LimitRanger ignoring the ones of the Pod constraints
+		defaults := corev1.LimitRangeItem{}
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type == corev1.LimitTypeContainer {
+				defaults = item
+			}
+		}
+
+		requests, limits := getPodResources(spec, defaults)
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type == corev1.LimitTypePod {
+				errs = errors.Join(errs, validateItem(limitRange.Name, " per pod", item, requests, limits))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateItem checks requests and limits against the minimum, the maximum and the limit to request ratio of a
+// LimitRange item, the scope qualifying the usage in the errors.
+func validateItem(limitRange string, scope string, item corev1.LimitRangeItem, requests corev1.ResourceList, limits corev1.ResourceList) error {
+	var errs error
+
+	for _, name := range sortedNames(item.Min) {
+		min := item.Min[name]
+		if request, found := requests[name]; !found {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: minimum %s usage%s is %s, but no request is specified", limitRange, name, scope, min.String()))
+		} else if request.Cmp(min) < 0 {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: minimum %s usage%s is %s, but request is %s", limitRange, name, scope, min.String(), request.String()))
+		}
+		if limit, found := limits[name]; found && limit.Cmp(min) < 0 {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: minimum %s usage%s is %s, but limit is %s", limitRange, name, scope, min.String(), limit.String()))
+		}
+	}
+	for _, name := range sortedNames(item.Max) {
+		max := item.Max[name]
+		if limit, found := limits[name]; !found {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: maximum %s usage%s is %s, but no limit is specified", limitRange, name, scope, max.String()))
+		} else if limit.Cmp(max) > 0 {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: maximum %s usage%s is %s, but limit is %s", limitRange, name, scope, max.String(), limit.String()))
+		}
+		if request, found := requests[name]; found && request.Cmp(max) > 0 {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: maximum %s usage%s is %s, but request is %s", limitRange, name, scope, max.String(), request.String()))
+		}
+	}
+	for _, name := range sortedNames(item.MaxLimitRequestRatio) {
+		ratio := item.MaxLimitRequestRatio[name]
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		if !hasRequest || !hasLimit || request.IsZero() {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: %s max limit to request ratio%s is %s, but no request or limit is specified", limitRange, name, scope, ratio.String()))
+			continue
+		}
+		if float64(limit.MilliValue())/float64(request.MilliValue()) > ratio.AsApproximateFloat64() {
+			errs = errors.Join(errs, fmt.Errorf("limitrange %s: %s max limit to request ratio%s is %s, but limit is %s and request is %s", limitRange, name, scope, ratio.String(), limit.String(), request.String()))
+		}
+	}
+
+	return errs
+}
+
+// getPodResources returns the requests and limits of the pod once the LimitRange defaults are applied to its
+// containers. Restartable init containers run alongside the containers and are summed with them.
+func getPodResources(spec corev1.PodSpec, defaults corev1.LimitRangeItem) (corev1.ResourceList, corev1.ResourceList) {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	initRequests := corev1.ResourceList{}
+	initLimits := corev1.ResourceList{}
+
+	for _, container := range spec.Containers {
+		containerRequests, containerLimits := applyDefaults(container.Resources, defaults)
+		addResources(requests, containerRequests)
+		addResources(limits, containerLimits)
+	}
+	for _, container := range spec.InitContainers {
+		containerRequests, containerLimits := applyDefaults(container.Resources, defaults)
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			addResources(requests, containerRequests)
+			addResources(limits, containerLimits)
+			continue
+		}
+		maxResources(initRequests, containerRequests)
+		maxResources(initLimits, containerLimits)
+	}
+	maxResources(requests, initRequests)
+	maxResources(limits, initLimits)
+
+	return requests, limits
+}
+
+// addResources adds the quantities of the resources to the list.
+func addResources(list corev1.ResourceList, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		total := list[name]
+		total.Add(quantity)
+		list[name] = total
+	}
+}
+
+// maxResources raises the quantities of the list to the ones of the resources when higher.
+func maxResources(list corev1.ResourceList, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		if current, found := list[name]; !found || quantity.Cmp(current) > 0 {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// applyDefaults returns the requests and limits of the container once the LimitRange defaults are applied.
+// Unset limits take the default limit, and unset requests take the default request or, failing that, the limit.
+func applyDefaults(requirements corev1.ResourceRequirements, item corev1.LimitRangeItem) (corev1.ResourceList, corev1.ResourceList) {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	for name, quantity := range requirements.Requests {
+		requests[name] = quantity
+	}
+	for name, quantity := range requirements.Limits {
+		limits[name] = quantity
+	}
+
+	for name, quantity := range item.Default {
+		if _, found := limits[name]; !found {
+			limits[name] = quantity
+		}
+	}
+	for name, quantity := range item.DefaultRequest {
+		if _, found := requests[name]; !found {
+			requests[name] = quantity
+		}
+	}
+	for name, quantity := range limits {
+		if _, found := requests[name]; !found {
+			requests[name] = quantity.DeepCopy()
+		}
+	}
+
+	return requests, limits
+}
+
+// sortedNames returns the resource names of the list in a stable order, so the reported errors don't change between reconciles.
+func sortedNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
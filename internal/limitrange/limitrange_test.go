@@ -0,0 +1,170 @@
+package limitrange
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func TestValidate(t *testing.T) {
+	limitRange := corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits"},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type: corev1.LimitTypeContainer,
+					Min:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					Max:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+					MaxLimitRequestRatio: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("2"),
+					},
+					Default: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+				{
+					Type: corev1.LimitTypePersistentVolumeClaim,
+					Min:  corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name         string
+		requirements corev1.ResourceRequirements
+		expected     string
+	}{
+		{
+			name: "admitted requirements",
+			requirements: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			},
+		},
+		{
+			name: "admitted requirements relying on the default limit",
+			requirements: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+			},
+		},
+		{
+			name: "request below the minimum",
+			requirements: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+			},
+			expected: "limitrange limits: minimum cpu usage is 100m, but request is 50m",
+		},
+		{
+			name: "limit above the maximum and ratio exceeded",
+			requirements: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			expected: "limitrange limits: maximum memory usage is 512Mi, but limit is 1Gi\n" +
+				"limitrange limits: memory max limit to request ratio is 2, but limit is 1Gi and request is 128Mi",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.requirements, []corev1.LimitRange{limitRange})
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.expected {
+				t.Errorf("Validate: expected error %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidatePod(t *testing.T) {
+	limitRange := corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits"},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type:           corev1.LimitTypeContainer,
+					Max:            corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+					Default:        corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+					DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				},
+				{
+					Type: corev1.LimitTypePod,
+					Min:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+					Max:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("768Mi")},
+				},
+			},
+		},
+	}
+	container := func(name string, cpu string, memory string) corev1.Container {
+		return corev1.Container{
+			Name: name,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)},
+			},
+		}
+	}
+	sidecar := container("sidecar", "100m", "256Mi")
+	sidecar.RestartPolicy = utils.Ptr(corev1.ContainerRestartPolicyAlways)
+
+	for _, tc := range []struct {
+		name     string
+		spec     corev1.PodSpec
+		expected string
+	}{
+		{
+			name: "admitted pod",
+			spec: corev1.PodSpec{Containers: []corev1.Container{container("podinfo", "100m", "256Mi"), container("proxy", "100m", "256Mi")}},
+		},
+		{
+			name: "admitted pod relying on the default request and limit",
+			spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "podinfo"}, {Name: "proxy"}}},
+		},
+		{
+			name: "init container above the container maximum",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("init", "100m", "1Gi")},
+				Containers:     []corev1.Container{container("podinfo", "200m", "256Mi")},
+			},
+			// the memory requests default to the limits
+			expected: "container init: limitrange limits: maximum memory usage is 512Mi, but limit is 1Gi\n" +
+				"limitrange limits: maximum memory usage is 512Mi, but request is 1Gi\n" +
+				"limitrange limits: maximum memory usage per pod is 768Mi, but limit is 1Gi\n" +
+				"limitrange limits: maximum memory usage per pod is 768Mi, but request is 1Gi",
+		},
+		{
+			name: "containers below the pod minimum",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("init", "150m", "256Mi")},
+				Containers:     []corev1.Container{container("podinfo", "100m", "256Mi")},
+			},
+			expected: "limitrange limits: minimum cpu usage per pod is 200m, but request is 150m",
+		},
+		{
+			name: "containers and sidecar above the pod maximum",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{sidecar},
+				Containers:     []corev1.Container{container("podinfo", "100m", "512Mi"), container("proxy", "100m", "256Mi")},
+			},
+			expected: "limitrange limits: maximum memory usage per pod is 768Mi, but limit is 1Gi\n" +
+				"limitrange limits: maximum memory usage per pod is 768Mi, but request is 1Gi",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePod(tc.spec, []corev1.LimitRange{limitRange})
+
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.expected {
+				t.Errorf("ValidatePod: expected error %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
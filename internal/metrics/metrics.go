@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The outcomes of the syncs of managed objects.
+const (
+	SyncOutcomeCreated   = "created"
+	SyncOutcomeUpdated   = "updated"
+	SyncOutcomeUnchanged = "unchanged"
+	SyncOutcomeFailed    = "failed"
+)
+
+// The phases of a reconcile whose duration is observed.
+const (
+	PhaseRedisSync    = "redis_sync"
+	PhasePodinfoSync  = "podinfo_sync"
+	PhaseStatusUpdate = "status_update"
+)
+
+var (
+	// Instances counts the managed instances by readiness.
+	Instances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "myappresource_instances",
+		Help: "Number of managed MyAppResource instances by readiness.",
+	}, []string{"ready"})
+
+	// SyncTotal counts the syncs of managed objects by kind and outcome.
+	SyncTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "myappresource_sync_total",
+		Help: "Number of syncs of managed objects by kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	// DriftCorrectionsTotal counts the updates reverting the changes made to managed objects outside of the operator.
+	DriftCorrectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "myappresource_drift_corrections_total",
+		Help: "Number of updates reverting managed objects changed outside of the operator, by kind.",
+	}, []string{"kind"})
+
+	// ReconcilePhaseDuration observes the duration of the reconcile phases.
+	ReconcilePhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "myappresource_reconcile_phase_duration_seconds",
+		Help:    "Duration of the reconcile phases in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"phase"})
+
+	// DesiredReplicas reports the desired podinfo replicas of each instance.
+	DesiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "myappresource_desired_replicas",
+		Help: "Desired podinfo replicas of the instance.",
+	}, []string{"namespace", "name"})
+
+	// ReadyReplicas reports the ready podinfo replicas of each instance.
+	ReadyReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "myappresource_ready_replicas",
+		Help: "Ready podinfo replicas of the instance.",
+	}, []string{"namespace", "name"})
+)
+
+// instances keeps the readiness of the managed instances, from which the Instances gauge is computed.
+var instances = struct {
+	sync.Mutex
+	ready map[string]bool
+}{ready: map[string]bool{}}
+
+func init() {
+	// registers the metrics on the registry served by the metrics server of the manager.
+	metrics.Registry.MustRegister(Instances, SyncTotal, DriftCorrectionsTotal, ReconcilePhaseDuration, DesiredReplicas, ReadyReplicas)
+}
+
+// SetInstance reports the readiness and the replicas of an instance.
+//
+// Parameters:
+//
+//	namespace: The namespace of the instance.
+//	name: The name of the instance.
+//	ready: Whether the instance was synced successfully and its workloads are available.
+//	desired: The desired podinfo replicas.
+//	available: The ready podinfo replicas.
+func SetInstance(namespace string, name string, ready bool, desired int32, available int32) {
+	instances.Lock()
+	instances.ready[namespace+"/"+name] = ready
+	updateInstances()
+	instances.Unlock()
+
+	DesiredReplicas.WithLabelValues(namespace, name).Set(float64(desired))
+	ReadyReplicas.WithLabelValues(namespace, name).Set(float64(available))
+}
+
+// DeleteInstance stops reporting an instance once it is deleted.
+//
+// Parameters:
+//
+//	namespace: The namespace of the instance.
+//	name: The name of the instance.
+func DeleteInstance(namespace string, name string) {
+	instances.Lock()
+	delete(instances.ready, namespace+"/"+name)
+	updateInstances()
+	instances.Unlock()
+
+	DesiredReplicas.DeleteLabelValues(namespace, name)
+	ReadyReplicas.DeleteLabelValues(namespace, name)
+}
+
+// updateInstances recomputes the Instances gauge. The instances lock must be held.
+func updateInstances() {
+	counts := map[bool]int{true: 0, false: 0}
+	for _, ready := range instances.ready {
+		counts[ready]++
+	}
+	for ready, count := range counts {
+		Instances.WithLabelValues(strconv.FormatBool(ready)).Set(float64(count))
+	}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gaugeValue returns the value of a gauge.
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	m := &dto.Metric{}
+	if err := gauge.Write(m); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestSetInstance(t *testing.T) {
+	SetInstance("default", "first", true, 3, 2)
+	SetInstance("default", "second", false, 1, 0)
+	SetInstance("other", "first", true, 2, 2)
+
+	for _, tc := range []struct {
+		name     string
+		gauge    prometheus.Gauge
+		expected float64
+	}{
+		{name: "ready instances", gauge: Instances.WithLabelValues("true"), expected: 2},
+		{name: "unready instances", gauge: Instances.WithLabelValues("false"), expected: 1},
+		{name: "desired replicas", gauge: DesiredReplicas.WithLabelValues("default", "first"), expected: 3},
+		{name: "ready replicas", gauge: ReadyReplicas.WithLabelValues("default", "first"), expected: 2},
+	} {
+		if got := gaugeValue(t, tc.gauge); got != tc.expected {
+			t.Errorf("SetInstance: %s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+
+	SetInstance("default", "second", true, 1, 1)
+	DeleteInstance("other", "first")
+	if got := gaugeValue(t, Instances.WithLabelValues("true")); got != 2 {
+		t.Errorf("DeleteInstance: expected 2 ready instances, got %v", got)
+	}
+	if got := gaugeValue(t, Instances.WithLabelValues("false")); got != 0 {
+		t.Errorf("DeleteInstance: expected no unready instance, got %v", got)
+	}
+	if DesiredReplicas.DeleteLabelValues("other", "first") {
+		t.Errorf("DeleteInstance: expected the replicas of the deleted instance to be removed")
+	}
+}
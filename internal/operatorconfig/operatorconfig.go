@@ -0,0 +1,313 @@
+package operatorconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// The version of the configuration file.
+const (
+	APIVersion = "my.api.group/v1alpha1"
+	Kind       = "OperatorConfig"
+)
+
+// defaultRegistry is the registry of the image references without registry host.
+const defaultRegistry = "docker.io"
+
+// Config is the configuration of the operator, setting the defaults of the rendered objects.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// ClusterDomain is the DNS domain of the cluster, which the address of the Redis service is built with.
+	// Defaults to cluster.local.
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+
+	// RegistryMirrors maps registry hosts, such as docker.io, to the mirrors the images are pulled from instead.
+	RegistryMirrors map[string]string `json:"registryMirrors,omitempty"`
+
+	// Redis sets the defaults of the Redis objects.
+	Redis Redis `json:"redis,omitempty"`
+
+	// Podinfo sets the defaults of the podinfo objects.
+	Podinfo Podinfo `json:"podinfo,omitempty"`
+}
+
+// Redis sets the defaults of the Redis objects.
+type Redis struct {
+	// Repository is the repository of the Redis image of the instances not setting their own.
+	// Defaults to docker.io/redis.
+	Repository string `json:"repository,omitempty"`
+
+	// Tag is the tag of the Redis image of the instances not setting their own. Defaults to 7.2.4.
+	Tag string `json:"tag,omitempty"`
+
+	// StorageClassName is the storage class of the Redis volume claims. Defaults to standard.
+	// The volume claims of a StatefulSet can't be changed, so it only applies to the new instances.
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Port is the port of the Redis service. Defaults to 6379.
+	Port int32 `json:"port,omitempty"`
+
+	// Resources are the resource requirements of the Redis containers.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// Podinfo sets the defaults of the podinfo objects.
+type Podinfo struct {
+	// Port is the port of the podinfo service. Defaults to 9898.
+	Port int32 `json:"port,omitempty"`
+
+	// MetricsPort is the port of the podinfo metrics. Defaults to 9797.
+	MetricsPort int32 `json:"metricsPort,omitempty"`
+
+	// Resources are the resource requirements of the podinfo containers of the instances not setting spec.resources.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// current is the configuration the objects are rendered with.
+var current atomic.Pointer[Config]
+
+// Default returns the configuration of the operator when no file is set.
+func Default() *Config {
+	out := &Config{APIVersion: APIVersion, Kind: Kind}
+	setDefaults(out)
+	return out
+}
+
+// Get returns the configuration the objects are rendered with.
+func Get() *Config {
+	if config := current.Load(); config != nil {
+		return config
+	}
+	return Default()
+}
+
+// Set replaces the configuration the objects are rendered with.
+func Set(config *Config) {
+	current.Store(config)
+}
+
+// Parse parses a configuration file, rejecting the unknown versions and fields, and fills in the defaults.
+//
+// Parameters:
+//
+//	data: The YAML content of the file.
+//
+// Returns:
+//
+//	*Config: The configuration with its defaults.
+//	error: An error if the file is invalid.
+func Parse(data []byte) (*Config, error) {
+	out := &Config{}
+	if err := yaml.UnmarshalStrict(data, out); err != nil {
+		return nil, fmt.Errorf("invalid operator configuration: %w", err)
+	}
+	if out.APIVersion != APIVersion || out.Kind != Kind {
+		return nil, fmt.Errorf("unsupported operator configuration %s %s, expected %s %s", out.APIVersion, out.Kind, APIVersion, Kind)
+	}
+	setDefaults(out)
+	return out, nil
+}
+
+// Load reads and parses a configuration file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the operator configuration: %w", err)
+	}
+	return Parse(data)
+}
+
+func setDefaults(config *Config) {
+	if config.ClusterDomain == "" {
+		config.ClusterDomain = "cluster.local"
+	}
+	if config.Redis.Repository == "" {
+		config.Redis.Repository = "docker.io/redis"
+	}
+	if config.Redis.Tag == "" {
+		config.Redis.Tag = "7.2.4"
+	}
+	if config.Redis.StorageClassName == "" {
+		config.Redis.StorageClassName = "standard"
+	}
+	if config.Redis.Port == 0 {
+		config.Redis.Port = 6379
+	}
+	if config.Podinfo.Port == 0 {
+		config.Podinfo.Port = 9898
+	}
+	if config.Podinfo.MetricsPort == 0 {
+		config.Podinfo.MetricsPort = 9797
+	}
+}
+
+// MirrorImage returns the image reference pulled from the mirror of its registry, if the configuration sets one.
+// The references without registry host are pulled from docker.io, where the single name images belong to library.
+func MirrorImage(reference string) string {
+	mirrors := Get().RegistryMirrors
+	if len(mirrors) == 0 {
+		return reference
+	}
+
+	registry, path := splitRegistry(reference)
+	mirror, found := mirrors[registry]
+	if !found {
+		return reference
+	}
+	if registry == defaultRegistry && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return strings.TrimSuffix(mirror, "/") + "/" + path
+}
+
+// splitRegistry splits the registry host from the image reference. The first component of the reference is a
+// registry host if it contains a dot or a port, or is localhost.
+func splitRegistry(reference string) (string, string) {
+	host, path, found := strings.Cut(reference, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return defaultRegistry, reference
+	}
+	return host, path
+}
+
+// Affects returns true if the objects of the instance are rendered differently with the new configuration.
+//
+// Parameters:
+//
+//	previous: The configuration the objects were rendered with.
+//	config: The new configuration.
+//	spec: The spec of the instance.
+//
+// Returns:
+//
+//	bool: Whether the instance must be reconciled.
+func Affects(previous *Config, config *Config, spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	redisEnabled := spec.Redis != nil && utils.Deref(spec.Redis.Enabled)
+	// the Redis address is passed to podinfo
+	if redisEnabled && (!reflect.DeepEqual(previous.Redis, config.Redis) || previous.ClusterDomain != config.ClusterDomain) {
+		return true
+	}
+	if spec.Image != nil && (previous.Podinfo.Port != config.Podinfo.Port || previous.Podinfo.MetricsPort != config.Podinfo.MetricsPort) {
+		return true
+	}
+	if spec.Image != nil && spec.Resources == nil && !reflect.DeepEqual(previous.Podinfo.Resources, config.Podinfo.Resources) {
+		return true
+	}
+
+	var registries []string
+	if spec.Image != nil {
+		registry, _ := splitRegistry(spec.Image.Repository)
+		registries = append(registries, registry)
+	}
+	if redisEnabled {
+		repository := config.Redis.Repository
+		if spec.Redis.Image != nil && spec.Redis.Image.Repository != "" {
+			repository = spec.Redis.Image.Repository
+		}
+		registry, _ := splitRegistry(repository)
+		registries = append(registries, registry)
+	}
+	for _, registry := range registries {
+		if previous.RegistryMirrors[registry] != config.RegistryMirrors[registry] {
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher reloads the configuration file when it changes, e.g. once the kubelet updated the ConfigMap it is
+// mounted from.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	data     []byte
+
+	lock     sync.Mutex
+	onChange []func(previous *Config, config *Config)
+}
+
+// NewWatcher returns the watcher of the configuration file, which must be added to the manager.
+//
+// Parameters:
+//
+//	path: The path of the configuration file, whose current content was loaded already.
+//	interval: The interval the file is read at.
+//
+// Returns:
+//
+//	*Watcher: The watcher of the file.
+func NewWatcher(path string, interval time.Duration) *Watcher {
+	data, _ := os.ReadFile(path)
+	return &Watcher{path: path, interval: interval, data: data}
+}
+
+// OnChange registers a function called in the background with the previous and the new configurations once it is
+// reloaded, e.g. to reconcile the instances it affects. It must be called before the watcher is started.
+func (w *Watcher) OnChange(f func(previous *Config, config *Config)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.onChange = append(w.onChange, f)
+}
+
+// NeedLeaderElection returns false as every replica renders objects.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start reloads the configuration file each time its content changes, until the context is done. An invalid
+// configuration is reported and ignored, the previous one is kept until the file is fixed.
+func (w *Watcher) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("path", w.path)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(w.path)
+		if err != nil {
+			logger.Error(err, "failed to read the operator configuration")
+			continue
+		}
+		if bytes.Equal(data, w.data) {
+			continue
+		}
+		w.data = data
+
+		config, err := Parse(data)
+		if err != nil {
+			logger.Error(err, "ignoring the invalid operator configuration")
+			continue
+		}
+		previous := Get()
+		Set(config)
+		logger.Info("reloaded the operator configuration")
+
+		w.lock.Lock()
+		onChange := w.onChange
+		w.lock.Unlock()
+		for _, f := range onChange {
+			go f(previous, config)
+		}
+	}
+}
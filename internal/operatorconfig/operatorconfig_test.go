@@ -0,0 +1,142 @@
+package operatorconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		data          string
+		expected      *Config
+		expectedError bool
+	}{
+		{
+			name:     "defaults",
+			data:     "apiVersion: my.api.group/v1alpha1\nkind: OperatorConfig\n",
+			expected: Default(),
+		},
+		{
+			name: "overridden",
+			data: `apiVersion: my.api.group/v1alpha1
+kind: OperatorConfig
+clusterDomain: example.org
+registryMirrors:
+  docker.io: mirror.example.org/docker
+redis:
+  tag: 7.2.5
+  resources:
+    requests:
+      memory: 128Mi
+`,
+			expected: func() *Config {
+				out := Default()
+				out.ClusterDomain = "example.org"
+				out.RegistryMirrors = map[string]string{"docker.io": "mirror.example.org/docker"}
+				out.Redis.Tag = "7.2.5"
+				out.Redis.Resources = corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+				}
+				return out
+			}(),
+		},
+		{name: "unknown field", data: "apiVersion: my.api.group/v1alpha1\nkind: OperatorConfig\nfoo: bar\n", expectedError: true},
+		{name: "unknown version", data: "apiVersion: my.api.group/v1\nkind: OperatorConfig\n", expectedError: true},
+		{name: "invalid", data: "redis: [", expectedError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := Parse([]byte(tc.data))
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("Parse: expected error %t, got %v", tc.expectedError, err)
+			}
+			if diff := cmp.Diff(tc.expected, config); diff != "" {
+				t.Errorf("Parse: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMirrorImage(t *testing.T) {
+	config := Default()
+	config.RegistryMirrors = map[string]string{
+		"docker.io": "mirror.example.org/docker/",
+		"ghcr.io":   "mirror.example.org/ghcr",
+	}
+	Set(config)
+	defer Set(nil)
+
+	for _, tc := range []struct {
+		reference string
+		expected  string
+	}{
+		{reference: "redis:7.2.4", expected: "mirror.example.org/docker/library/redis:7.2.4"},
+		{reference: "docker.io/redis:7.2.4", expected: "mirror.example.org/docker/library/redis:7.2.4"},
+		{reference: "bitnami/redis:7.2.4", expected: "mirror.example.org/docker/bitnami/redis:7.2.4"},
+		{reference: "ghcr.io/stefanprodan/podinfo:6.5.4", expected: "mirror.example.org/ghcr/stefanprodan/podinfo:6.5.4"},
+		{reference: "quay.io/foo/bar:latest", expected: "quay.io/foo/bar:latest"},
+		{reference: "localhost:5000/podinfo:latest", expected: "localhost:5000/podinfo:latest"},
+	} {
+		if got := MirrorImage(tc.reference); got != tc.expected {
+			t.Errorf("MirrorImage(%s): expected %s, got %s", tc.reference, tc.expected, got)
+		}
+	}
+}
+
+func TestAffects(t *testing.T) {
+	podinfo := &myapigroupv1alpha1.MyAppResourceSpec{
+		Image: &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "latest"},
+	}
+	redis := &myapigroupv1alpha1.MyAppResourceSpec{
+		Image: &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "latest"},
+		Redis: &myapigroupv1alpha1.Redis{Enabled: utils.Ptr(true)},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		update   func(config *Config)
+		spec     *myapigroupv1alpha1.MyAppResourceSpec
+		expected bool
+	}{
+		{name: "unchanged", update: func(*Config) {}, spec: redis},
+		{name: "redis tag without redis", update: func(c *Config) { c.Redis.Tag = "7.2.5" }, spec: podinfo},
+		{name: "redis tag", update: func(c *Config) { c.Redis.Tag = "7.2.5" }, spec: redis, expected: true},
+		{name: "cluster domain", update: func(c *Config) { c.ClusterDomain = "example.org" }, spec: redis, expected: true},
+		{name: "podinfo port", update: func(c *Config) { c.Podinfo.Port = 8080 }, spec: podinfo, expected: true},
+		{
+			name: "podinfo resources",
+			update: func(c *Config) {
+				c.Podinfo.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+			},
+			spec:     podinfo,
+			expected: true,
+		},
+		{
+			name: "podinfo resources set by the instance",
+			update: func(c *Config) {
+				c.Podinfo.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+			},
+			spec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Image:     podinfo.Image,
+				Resources: &myapigroupv1alpha1.Resources{CPURequest: "50m"},
+			},
+		},
+		{name: "mirror of another registry", update: func(c *Config) { c.RegistryMirrors = map[string]string{"quay.io": "mirror"} }, spec: redis},
+		{name: "mirror of podinfo", update: func(c *Config) { c.RegistryMirrors = map[string]string{"ghcr.io": "mirror"} }, spec: podinfo, expected: true},
+		{name: "mirror of redis", update: func(c *Config) { c.RegistryMirrors = map[string]string{"docker.io": "mirror"} }, spec: redis, expected: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := Default()
+			tc.update(config)
+			if got := Affects(Default(), config, tc.spec); got != tc.expected {
+				t.Errorf("Affects: expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
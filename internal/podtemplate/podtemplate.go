@@ -0,0 +1,115 @@
+package podtemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// The patch types, matching the api PodTemplatePatchType values.
+const (
+	StrategicMerge = "StrategicMerge"
+	JSON           = "JSON"
+)
+
+// jsonPatchOperations lists the RFC 6902 operations.
+var jsonPatchOperations = map[string]bool{
+	"add":     true,
+	"remove":  true,
+	"replace": true,
+	"move":    true,
+	"copy":    true,
+	"test":    true,
+}
+
+// Apply patches the pod template in place.
+//
+// Parameters:
+//
+//	template: The rendered pod template to patch.
+//	patchType: The format of the patch, StrategicMerge or JSON. Strategic merge is assumed when empty.
+//	patch: The patch, in JSON or YAML.
+//
+// Returns:
+//
+//	error: An error if the patch is invalid or couldn't be applied, in which case the template is left untouched.
+func Apply(template *corev1.PodTemplateSpec, patchType string, patch string) error {
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return fmt.Errorf("failed to decode the pod template patch: %w", err)
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to encode the pod template: %w", err)
+	}
+
+	var patched []byte
+	switch patchType {
+	case StrategicMerge, "":
+		patched, err = strategicpatch.StrategicMergePatch(original, patchJSON, corev1.PodTemplateSpec{})
+	case JSON:
+		var jsonPatch jsonpatch.Patch
+		if jsonPatch, err = jsonpatch.DecodePatch(patchJSON); err == nil {
+			patched, err = jsonPatch.Apply(original)
+		}
+	default:
+		return fmt.Errorf("unsupported pod template patch type %q", patchType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply the pod template patch: %w", err)
+	}
+
+	out := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(patched, &out); err != nil {
+		return fmt.Errorf("failed to decode the patched pod template: %w", err)
+	}
+	*template = out
+	return nil
+}
+
+// Validate checks the patch can be decoded and, for strategic merge patches, that it is a valid PodTemplateSpec fragment.
+// JSON patches can only be fully checked against the rendered template, so only their operations are checked here.
+//
+// Parameters:
+//
+//	patchType: The format of the patch, StrategicMerge or JSON. Strategic merge is assumed when empty.
+//	patch: The patch, in JSON or YAML.
+//
+// Returns:
+//
+//	error: An error describing why the patch is invalid, or nil.
+func Validate(patchType string, patch string) error {
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return fmt.Errorf("failed to decode the patch: %w", err)
+	}
+
+	switch patchType {
+	case StrategicMerge, "":
+		if !strings.HasPrefix(strings.TrimSpace(string(patchJSON)), "{") {
+			return fmt.Errorf("a strategic merge patch must be an object")
+		}
+		return Apply(&corev1.PodTemplateSpec{}, StrategicMerge, patch)
+	case JSON:
+		jsonPatch, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return fmt.Errorf("failed to decode the JSON patch: %w", err)
+		}
+		for i, operation := range jsonPatch {
+			if !jsonPatchOperations[operation.Kind()] {
+				return fmt.Errorf("operation %d: unsupported operation %q", i, operation.Kind())
+			}
+			if path, err := operation.Path(); err != nil || !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("operation %d: the path must be a JSON pointer", i)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported patch type %q", patchType)
+	}
+}
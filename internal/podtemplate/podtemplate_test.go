@@ -0,0 +1,102 @@
+package podtemplate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTemplate() *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "podinfo", Image: "ghcr.io/stefanprodan/podinfo:6.6.0", Env: []corev1.EnvVar{{Name: "A", Value: "a"}}},
+			},
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		patchType   string
+		patch       string
+		expected    *corev1.PodTemplateSpec
+		expectedErr bool
+	}{
+		{
+			name:      "strategic merge patch merges containers by name",
+			patchType: StrategicMerge,
+			patch: `
+metadata:
+  annotations:
+    sidecar.istio.io/inject: "false"
+spec:
+  hostAliases:
+  - ip: 10.0.0.1
+    hostnames: [backend]
+  containers:
+  - name: podinfo
+    env:
+    - name: B
+      value: b
+`,
+			expected: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"sidecar.istio.io/inject": "false"}},
+				Spec: corev1.PodSpec{
+					HostAliases: []corev1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"backend"}}},
+					Containers: []corev1.Container{
+						{Name: "podinfo", Image: "ghcr.io/stefanprodan/podinfo:6.6.0", Env: []corev1.EnvVar{{Name: "B", Value: "b"}, {Name: "A", Value: "a"}}},
+					},
+				},
+			},
+		},
+		{
+			name:      "JSON patch",
+			patchType: JSON,
+			patch:     `[{"op":"replace","path":"/spec/containers/0/image","value":"podinfo:latest"},{"op":"add","path":"/spec/containers/0/args","value":["--level=debug"]}]`,
+			expected: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "podinfo", Image: "podinfo:latest", Args: []string{"--level=debug"}, Env: []corev1.EnvVar{{Name: "A", Value: "a"}}},
+					},
+				},
+			},
+		},
+		{
+			name:        "JSON patch on a missing path",
+			patchType:   JSON,
+			patch:       `[{"op":"replace","path":"/spec/containers/1/image","value":"busybox"}]`,
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported patch type",
+			patchType:   "Merge",
+			patch:       `{}`,
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			template := newTemplate()
+			err := Apply(template, tc.patchType, tc.patch)
+
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("Apply: expected an error")
+				}
+				if diff := cmp.Diff(newTemplate(), template); diff != "" {
+					t.Errorf("Apply: expected the template to be untouched (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, template); diff != "" {
+				t.Errorf("Apply: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
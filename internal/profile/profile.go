@@ -0,0 +1,79 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+)
+
+// Merge returns the effective spec of an instance referencing a profile. The spec of the instance is applied over
+// the one of the profile as a JSON merge patch (RFC 7386): the objects are merged field by field, while the lists and
+// the scalars set by the instance replace the ones of the profile. The fields left unset by the instance keep the
+// values of the profile, the optional booleans included, so an instance can turn off a switch its profile turns on.
+//
+// Parameters:
+//
+//	profile: The spec of the profile.
+//	spec: The spec of the instance.
+//
+// Returns:
+//
+//	*myapigroupv1alpha1.MyAppResourceSpec: The effective spec.
+//	error: An error if the specs can't be merged.
+func Merge(profile *myapigroupv1alpha1.MyAppResourceSpec, spec *myapigroupv1alpha1.MyAppResourceSpec) (*myapigroupv1alpha1.MyAppResourceSpec, error) {
+	// the instance-level switches only come from the instance
+	base := profile.DeepCopy()
+	base.Paused, base.Suspend, base.ProfileRef = false, false, nil
+
+	original, err := json.Marshal(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the profile spec: %w", err)
+	}
+	patch, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the instance spec: %w", err)
+	}
+	merged, err := jsonpatch.MergePatch(original, patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge the instance spec over the profile: %w", err)
+	}
+
+	out := &myapigroupv1alpha1.MyAppResourceSpec{}
+	if err := json.Unmarshal(merged, out); err != nil {
+		return nil, fmt.Errorf("failed to decode the effective spec: %w", err)
+	}
+	return out, nil
+}
+
+// Resolve returns the effective spec of an instance, its own spec when it doesn't reference a profile.
+//
+// Parameters:
+//
+//	ctx: The context of the request.
+//	reader: The client reading the profiles.
+//	spec: The spec of the instance.
+//
+// Returns:
+//
+//	*myapigroupv1alpha1.MyAppResourceSpec: The effective spec, a copy the caller may modify.
+//	error: An error if the profile doesn't exist or can't be read.
+func Resolve(ctx context.Context, reader client.Reader, spec *myapigroupv1alpha1.MyAppResourceSpec) (*myapigroupv1alpha1.MyAppResourceSpec, error) {
+	if spec.ProfileRef == nil {
+		return spec.DeepCopy(), nil
+	}
+
+	profile := &myapigroupv1alpha1.MyAppProfile{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: spec.ProfileRef.Name}, profile); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("profile %s not found", spec.ProfileRef.Name)
+		}
+		return nil, fmt.Errorf("failed to get profile %s: %w", spec.ProfileRef.Name, err)
+	}
+	return Merge(&profile.Spec, spec)
+}
@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// profileClient reads the profiles it holds.
+type profileClient struct {
+	client.Client
+	profiles map[string]*myapigroupv1alpha1.MyAppProfile
+}
+
+func (c *profileClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	profile, found := c.profiles[key.Name]
+	if !found {
+		return apierrors.NewNotFound(myapigroupv1alpha1.GroupVersion.WithResource("myappprofiles").GroupResource(), key.Name)
+	}
+	profile.DeepCopyInto(obj.(*myapigroupv1alpha1.MyAppProfile))
+	return nil
+}
+
+func TestMerge(t *testing.T) {
+	profile := &myapigroupv1alpha1.MyAppResourceSpec{
+		Paused:       true,
+		ReplicaCount: utils.Ptr[int32](3),
+		Resources: &myapigroupv1alpha1.Resources{ResourceRequirements: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+		}},
+		Image: &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.5.4"},
+		Redis: &myapigroupv1alpha1.Redis{
+			Enabled:    utils.Ptr(true),
+			Scheduling: &myapigroupv1alpha1.Scheduling{NodeSelector: map[string]string{"pool": "redis"}},
+		},
+		Monitoring: &myapigroupv1alpha1.Monitoring{Enabled: utils.Ptr(true)},
+		Scheduling: &myapigroupv1alpha1.Scheduling{
+			Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		spec     *myapigroupv1alpha1.MyAppResourceSpec
+		expected *myapigroupv1alpha1.MyAppResourceSpec
+	}{
+		{
+			name: "profile only",
+			spec: &myapigroupv1alpha1.MyAppResourceSpec{ProfileRef: &myapigroupv1alpha1.ProfileReference{Name: "default"}},
+			expected: func() *myapigroupv1alpha1.MyAppResourceSpec {
+				out := profile.DeepCopy()
+				out.Paused = false
+				out.ProfileRef = &myapigroupv1alpha1.ProfileReference{Name: "default"}
+				return out
+			}(),
+		},
+		{
+			name: "instance values merged over the profile",
+			spec: &myapigroupv1alpha1.MyAppResourceSpec{
+				ProfileRef:   &myapigroupv1alpha1.ProfileReference{Name: "default"},
+				ReplicaCount: utils.Ptr[int32](0),
+				Resources: &myapigroupv1alpha1.Resources{ResourceRequirements: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+				}},
+				Image: &myapigroupv1alpha1.Image{Tag: "latest"},
+				// booleans set to false turn off the ones of the profile
+				Redis: &myapigroupv1alpha1.Redis{Enabled: utils.Ptr(false)},
+				Scheduling: &myapigroupv1alpha1.Scheduling{
+					Tolerations: []corev1.Toleration{{Key: "spot", Operator: corev1.TolerationOpExists}},
+				},
+			},
+			expected: &myapigroupv1alpha1.MyAppResourceSpec{
+				ProfileRef:   &myapigroupv1alpha1.ProfileReference{Name: "default"},
+				ReplicaCount: utils.Ptr[int32](0),
+				Resources: &myapigroupv1alpha1.Resources{ResourceRequirements: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+				}},
+				Image: &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "latest"},
+				Redis: &myapigroupv1alpha1.Redis{
+					Enabled:    utils.Ptr(false),
+					Scheduling: &myapigroupv1alpha1.Scheduling{NodeSelector: map[string]string{"pool": "redis"}},
+				},
+				Monitoring: &myapigroupv1alpha1.Monitoring{Enabled: utils.Ptr(true)},
+				// lists are replaced
+				Scheduling: &myapigroupv1alpha1.Scheduling{
+					Tolerations: []corev1.Toleration{{Key: "spot", Operator: corev1.TolerationOpExists}},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := Merge(profile, tc.spec)
+			if err != nil {
+				t.Fatalf("Merge: unexpected error %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, spec); diff != "" {
+				t.Errorf("Merge: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	k8sClient := &profileClient{profiles: map[string]*myapigroupv1alpha1.MyAppProfile{
+		"small": {
+			ObjectMeta: metav1.ObjectMeta{Name: "small"},
+			Spec:       myapigroupv1alpha1.MyAppResourceSpec{ReplicaCount: utils.Ptr[int32](1)},
+		},
+	}}
+
+	for _, tc := range []struct {
+		name          string
+		spec          *myapigroupv1alpha1.MyAppResourceSpec
+		expected      *myapigroupv1alpha1.MyAppResourceSpec
+		expectedError bool
+	}{
+		{
+			name:     "no profile",
+			spec:     &myapigroupv1alpha1.MyAppResourceSpec{ServiceAccountName: "podinfo"},
+			expected: &myapigroupv1alpha1.MyAppResourceSpec{ServiceAccountName: "podinfo"},
+		},
+		{
+			name: "profile",
+			spec: &myapigroupv1alpha1.MyAppResourceSpec{ProfileRef: &myapigroupv1alpha1.ProfileReference{Name: "small"}},
+			expected: &myapigroupv1alpha1.MyAppResourceSpec{
+				ProfileRef:   &myapigroupv1alpha1.ProfileReference{Name: "small"},
+				ReplicaCount: utils.Ptr[int32](1),
+			},
+		},
+		{
+			name:          "missing profile",
+			spec:          &myapigroupv1alpha1.MyAppResourceSpec{ProfileRef: &myapigroupv1alpha1.ProfileReference{Name: "large"}},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := Resolve(context.Background(), k8sClient, tc.spec)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("Resolve: expected error %t, got %v", tc.expectedError, err)
+			}
+			if diff := cmp.Diff(tc.expected, spec); diff != "" {
+				t.Errorf("Resolve: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client evaluates instant queries against the Prometheus HTTP API.
+type Client struct {
+	Client *http.Client
+}
+
+// queryTimeout bounds each query, which is evaluated within a reconcile of the instance.
+const queryTimeout = 5 * time.Second
+
+// NewClient returns a Client using an HTTP client with a bounded timeout.
+func NewClient() *Client {
+	return &Client{Client: &http.Client{Timeout: queryTimeout}}
+}
+
+// ParseAddresses parses a comma separated list of Prometheus API addresses, without their trailing slash.
+//
+// Parameters:
+//
+//	addresses: The comma separated addresses, e.g. http://prometheus.monitoring:9090,http://thanos.monitoring:9090.
+//
+// Returns:
+//
+//	[]string: The addresses, nil if none is set.
+func ParseAddresses(addresses string) []string {
+	var out []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSuffix(strings.TrimSpace(address), "/"); address != "" {
+			out = append(out, address)
+		}
+	}
+	return out
+}
+
+// queryResponse is the subset of the /api/v1/query response read by the client.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// sample is an element of a vector result.
+type sample struct {
+	Value [2]json.RawMessage `json:"value"`
+}
+
+// Query evaluates an instant query returning a single sample.
+//
+// Parameters:
+//
+//	ctx: The context of the Prometheus request.
+//	address: The address of the Prometheus API, e.g. http://prometheus.monitoring:9090.
+//	query: The PromQL query, which must return a scalar or a vector of one sample.
+//
+// Returns:
+//
+//	float64: The value of the sample.
+//	error: An error if the query failed or didn't return a single sample.
+func (c *Client) Query(ctx context.Context, address string, query string) (float64, error) {
+	queryURL := fmt.Sprintf("%s/api/v1/query?%s", strings.TrimSuffix(address, "/"), url.Values{"query": {query}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var body queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode the response of %s: %s", address, resp.Status)
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("query failed with %s: %s", body.ErrorType, body.Error)
+	}
+
+	var value [2]json.RawMessage
+	switch body.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(body.Data.Result, &value); err != nil {
+			return 0, fmt.Errorf("failed to decode the scalar result: %w", err)
+		}
+	case "vector":
+		var samples []sample
+		if err := json.Unmarshal(body.Data.Result, &samples); err != nil {
+			return 0, fmt.Errorf("failed to decode the vector result: %w", err)
+		}
+		if len(samples) != 1 {
+			return 0, fmt.Errorf("query returned %d samples, expected 1", len(samples))
+		}
+		value = samples[0].Value
+	default:
+		return 0, fmt.Errorf("unsupported result type %q", body.Data.ResultType)
+	}
+
+	return parseValue(value[1])
+}
+
+// parseValue parses a sample value, which the API encodes as a string to carry NaN and infinities.
+func parseValue(raw json.RawMessage) (float64, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, fmt.Errorf("failed to decode the sample value: %w", err)
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newPrometheus starts a stand-in Prometheus API answering each known query with its canned result.
+func newPrometheus(t *testing.T, results map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		result, found := results[r.URL.Query().Get("query")]
+		if !found {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":%s}`, result)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestQuery(t *testing.T) {
+	server := newPrometheus(t, map[string]string{
+		"vector(0.5)": `{"resultType":"vector","result":[{"metric":{},"value":[1714564800,"0.5"]}]}`,
+		"scalar(2)":   `{"resultType":"scalar","result":[1714564800,"2"]}`,
+		"empty":       `{"resultType":"vector","result":[]}`,
+		"by_pod":      `{"resultType":"vector","result":[{"metric":{"pod":"a"},"value":[1714564800,"1"]},{"metric":{"pod":"b"},"value":[1714564800,"2"]}]}`,
+		"matrix":      `{"resultType":"matrix","result":[]}`,
+		"nan":         `{"resultType":"scalar","result":[1714564800,"NaN"]}`,
+	})
+
+	for _, tc := range []struct {
+		query       string
+		expected    float64
+		expectedErr bool
+	}{
+		{query: "vector(0.5)", expected: 0.5},
+		{query: "scalar(2)", expected: 2},
+		{query: "empty", expectedErr: true},
+		{query: "by_pod", expectedErr: true},
+		{query: "matrix", expectedErr: true},
+		{query: "unknown", expectedErr: true},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			c := &Client{Client: server.Client()}
+			value, err := c.Query(context.Background(), server.URL+"/", tc.query)
+
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("Query: expected an error, got value %v", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query: unexpected error: %v", err)
+			}
+			if value != tc.expected {
+				t.Errorf("Query: expected value %v, got %v", tc.expected, value)
+			}
+		})
+	}
+
+	t.Run("nan", func(t *testing.T) {
+		c := &Client{Client: server.Client()}
+		value, err := c.Query(context.Background(), server.URL, "nan")
+		if err != nil {
+			t.Fatalf("Query: unexpected error: %v", err)
+		}
+		if !math.IsNaN(value) {
+			t.Errorf("Query: expected NaN, got %v", value)
+		}
+	})
+}
+
+func TestParseAddresses(t *testing.T) {
+	for _, tc := range []struct {
+		addresses string
+		expected  []string
+	}{
+		{addresses: ""},
+		{addresses: "http://prometheus.monitoring:9090/", expected: []string{"http://prometheus.monitoring:9090"}},
+		{
+			addresses: "http://prometheus.monitoring:9090, http://thanos.monitoring:9090,",
+			expected:  []string{"http://prometheus.monitoring:9090", "http://thanos.monitoring:9090"},
+		},
+	} {
+		if diff := cmp.Diff(tc.expected, ParseAddresses(tc.addresses)); diff != "" {
+			t.Errorf("ParseAddresses(%q): mismatch (-want +got):\n%s", tc.addresses, diff)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const defaultRegistry = "registry-1.docker.io"
+
+// defaultRegistryRealm is the host of the token service of Docker Hub, which isn't served by the registry host.
+const defaultRegistryRealm = "auth.docker.io"
+
+// manifestMediaTypes lists the manifest media types accepted when resolving a tag.
+// Index and manifest list types come first so multi-arch images resolve to their index digest.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Credentials holds the basic auth credentials of a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Resolver resolves image tags to digests using the OCI distribution API.
+type Resolver struct {
+	Client *http.Client
+
+	// AllowedHosts are the registry hosts the tags may be resolved against, as returned by ParseHosts. The images
+	// of any other registry fail to resolve, so that the instances can't make the operator send requests, nor their
+	// pull secrets, to any address.
+	AllowedHosts []string
+}
+
+// NewResolver returns a Resolver using an HTTP client with a bounded timeout.
+//
+// Parameters:
+//
+//	allowedHosts: The registry hosts the tags may be resolved against, none if empty.
+//
+// Returns:
+//
+//	*Resolver: The resolver.
+func NewResolver(allowedHosts []string) *Resolver {
+	return &Resolver{Client: &http.Client{Timeout: 30 * time.Second}, AllowedHosts: allowedHosts}
+}
+
+// ParseHosts parses a comma separated list of registry hosts. Docker Hub may be listed as docker.io.
+//
+// Parameters:
+//
+//	hosts: The comma separated hosts, e.g. docker.io,ghcr.io,registry.example.com:5000.
+//
+// Returns:
+//
+//	[]string: The hosts, nil if none is set.
+func ParseHosts(hosts string) []string {
+	var out []string
+	for _, host := range strings.Split(hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			out = append(out, normalizeHost(host))
+		}
+	}
+	return out
+}
+
+// Resolve returns the digest of the manifest the tag of the repository points to.
+//
+// Parameters:
+//
+//	ctx: The context of the registry requests.
+//	repository: The image repository, e.g. ghcr.io/stefanprodan/podinfo. Docker Hub is assumed when no registry host is set.
+//	tag: The tag to resolve.
+//	credentials: The credentials per registry host, as parsed from the image pull secrets.
+//
+// Returns:
+//
+//	string: The digest of the manifest, e.g. sha256:<hex>.
+//	error: An error if the tag couldn't be resolved.
+func (r *Resolver) Resolve(ctx context.Context, repository string, tag string, credentials map[string]Credentials) (string, error) {
+	host, path := splitRepository(repository)
+	if !slices.Contains(r.AllowedHosts, host) {
+		return "", fmt.Errorf("registry %s isn't allowed by the operator", host)
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, path, tag)
+	creds, hasCreds := credentials[host]
+	if host == defaultRegistry && !hasCreds {
+		creds, hasCreds = credentials["docker.io"]
+	}
+
+	resp, err := r.getManifest(ctx, http.MethodHead, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	authorization := ""
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err = r.authorize(ctx, resp.Header.Get("WWW-Authenticate"), host, path, creds, hasCreds)
+		if err != nil {
+			return "", fmt.Errorf("failed to authenticate against %s: %w", host, err)
+		}
+		if resp, err = r.getManifest(ctx, http.MethodHead, manifestURL, authorization); err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to resolve %s:%s: unexpected status %s", repository, tag, resp.Status)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// some registries don't return the digest on HEAD requests, so it's computed from the manifest instead.
+	if resp, err = r.getManifest(ctx, http.MethodGet, manifestURL, authorization); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch the manifest of %s:%s: unexpected status %s", repository, tag, resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to read the manifest of %s:%s: %w", repository, tag, err)
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+func (r *Resolver) getManifest(ctx context.Context, method string, manifestURL string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the manifest request: %w", err)
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request the manifest: %w", err)
+	}
+	return resp, nil
+}
+
+// authorize answers the registry authentication challenge and returns the Authorization header value to use.
+// Bearer challenges are answered by fetching a pull token from the token service, basic challenges with the credentials.
+// The credentials are only sent to a token service served over HTTPS by the registry host, or by the one of Docker
+// Hub, the other token services are asked for an anonymous token.
+func (r *Resolver) authorize(ctx context.Context, challenge string, host string, path string, creds Credentials, hasCreds bool) (string, error) {
+	authScheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(authScheme) {
+	case "basic":
+		if !hasCreds {
+			return "", fmt.Errorf("registry requires credentials")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+		tokenURL, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" || tokenURL.Scheme != "https" {
+			return "", fmt.Errorf("invalid token realm %q", params["realm"])
+		}
+		if tokenURL.Host != host && (host != defaultRegistry || tokenURL.Host != defaultRegistryRealm) {
+			hasCreds = false
+		}
+		query := tokenURL.Query()
+		if params["service"] != "" {
+			query.Set("service", params["service"])
+		}
+		query.Set("scope", fmt.Sprintf("repository:%s:pull", path))
+		tokenURL.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to build the token request: %w", err)
+		}
+		if hasCreds {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to request a token: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to request a token: unexpected status %s", resp.Status)
+		}
+
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("failed to decode the token: %w", err)
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+}
+
+// CredentialsFromSecrets parses the registry credentials held by image pull secrets.
+// Both kubernetes.io/dockerconfigjson and kubernetes.io/dockercfg secrets are supported.
+func CredentialsFromSecrets(secrets []corev1.Secret) (map[string]Credentials, error) {
+	out := map[string]Credentials{}
+
+	for _, secret := range secrets {
+		auths := map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		}{}
+
+		var err error
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			config := struct {
+				Auths json.RawMessage `json:"auths"`
+			}{}
+			if err = json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err == nil {
+				err = json.Unmarshal(config.Auths, &auths)
+			}
+		case corev1.SecretTypeDockercfg:
+			err = json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse secret %s: %w", secret.Name, err)
+		}
+
+		for server, auth := range auths {
+			creds := Credentials{Username: auth.Username, Password: auth.Password}
+			if auth.Auth != "" {
+				decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode the auth of %s in secret %s: %w", server, secret.Name, err)
+				}
+				creds.Username, creds.Password, _ = strings.Cut(string(decoded), ":")
+			}
+			out[normalizeHost(server)] = creds
+		}
+	}
+
+	return out, nil
+}
+
+// splitRepository splits an image repository into its registry host and path, following the Docker conventions.
+func splitRepository(repository string) (string, string) {
+	host, path, found := strings.Cut(repository, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, path = defaultRegistry, repository
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = defaultRegistry
+	}
+	if host == defaultRegistry && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return host, path
+}
+
+// normalizeHost extracts the registry host from a docker config server entry, e.g. https://index.docker.io/v1/.
+func normalizeHost(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	if server == "index.docker.io" || server == "docker.io" {
+		return defaultRegistry
+	}
+	return server
+}
+
+// parseChallenge parses a WWW-Authenticate header such as `Bearer realm="https://auth",service="registry"`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	authScheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var pair string
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				break
+			}
+			pair, rest = value[1:end+1], value[end+2:]
+		} else {
+			pair, rest, _ = strings.Cut(value, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = pair
+	}
+
+	return authScheme, params
+}
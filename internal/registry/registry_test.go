@@ -0,0 +1,173 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const manifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+
+// newRegistry starts a stand-in OCI registry serving a single podinfo:6.6.0 manifest behind a token service. The
+// token service is the one of the registry unless realm is set.
+func newRegistry(t *testing.T, sendDigest bool, realm string) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("scope") != "repository:stefanprodan/podinfo:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"token":"pull-token"}`)
+	})
+	mux.HandleFunc("/v2/stefanprodan/podinfo/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			if realm == "" {
+				realm = server.URL + "/token"
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s",service="stand-in"`, realm))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/6.6.0") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if sendDigest {
+			w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest))))
+		}
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, manifest)
+		}
+	})
+
+	server = httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolve(t *testing.T) {
+	expectedDigest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest)))
+
+	for _, tc := range []struct {
+		name        string
+		sendDigest  bool
+		tag         string
+		credentials bool
+		otherRealm  bool
+		notAllowed  bool
+		expectedErr bool
+	}{
+		{name: "digest from header", sendDigest: true, tag: "6.6.0", credentials: true},
+		{name: "digest computed from manifest", sendDigest: false, tag: "6.6.0", credentials: true},
+		{name: "unknown tag", sendDigest: true, tag: "0.0.0", credentials: true, expectedErr: true},
+		{name: "missing credentials", sendDigest: true, tag: "6.6.0", credentials: false, expectedErr: true},
+		{name: "registry not allowed", sendDigest: true, tag: "6.6.0", credentials: true, notAllowed: true, expectedErr: true},
+		{name: "credentials kept from another realm host", sendDigest: true, tag: "6.6.0", credentials: true, otherRealm: true, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// the other realm records whether it was sent the credentials
+			sentCredentials := false
+			realm := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _, sentCredentials = r.BasicAuth()
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			t.Cleanup(realm.Close)
+			realmURL := ""
+			if tc.otherRealm {
+				realmURL = realm.URL + "/token"
+			}
+
+			server := newRegistry(t, tc.sendDigest, realmURL)
+			host := strings.TrimPrefix(server.URL, "https://")
+			credentials := map[string]Credentials{}
+			if tc.credentials {
+				credentials[host] = Credentials{Username: "user", Password: "secret"}
+			}
+
+			resolver := &Resolver{Client: server.Client(), AllowedHosts: []string{host}}
+			if tc.notAllowed {
+				resolver.AllowedHosts = ParseHosts("docker.io,ghcr.io")
+			}
+			digest, err := resolver.Resolve(context.Background(), host+"/stefanprodan/podinfo", tc.tag, credentials)
+
+			if sentCredentials {
+				t.Errorf("Resolve: credentials sent to the token service of another host")
+			}
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("Resolve: expected an error, got digest %q", digest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: unexpected error: %v", err)
+			}
+			if digest != expectedDigest {
+				t.Errorf("Resolve: expected digest %q, got %q", expectedDigest, digest)
+			}
+		})
+	}
+}
+
+func TestCredentialsFromSecrets(t *testing.T) {
+	secrets := []corev1.Secret{
+		{
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpzZWNyZXQ="},"ghcr.io":{"username":"bot","password":"token"}}}`),
+			},
+		},
+		{
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{"password": []byte("ignored")},
+		},
+	}
+
+	credentials, err := CredentialsFromSecrets(secrets)
+	if err != nil {
+		t.Fatalf("CredentialsFromSecrets: unexpected error: %v", err)
+	}
+
+	expected := map[string]Credentials{
+		"registry-1.docker.io": {Username: "user", Password: "secret"},
+		"ghcr.io":              {Username: "bot", Password: "token"},
+	}
+	if diff := cmp.Diff(expected, credentials); diff != "" {
+		t.Errorf("CredentialsFromSecrets: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	expected := []string{"registry-1.docker.io", "ghcr.io", "registry.example.com:5000"}
+	if diff := cmp.Diff(expected, ParseHosts(" docker.io, ghcr.io,,registry.example.com:5000")); diff != "" {
+		t.Errorf("ParseHosts: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSplitRepository(t *testing.T) {
+	for repository, expected := range map[string][2]string{
+		"redis":                        {"registry-1.docker.io", "library/redis"},
+		"docker.io/redis":              {"registry-1.docker.io", "library/redis"},
+		"bitnami/redis":                {"registry-1.docker.io", "bitnami/redis"},
+		"ghcr.io/stefanprodan/podinfo": {"ghcr.io", "stefanprodan/podinfo"},
+		"localhost:5000/podinfo":       {"localhost:5000", "podinfo"},
+	} {
+		host, path := splitRepository(repository)
+		if host != expected[0] || path != expected[1] {
+			t.Errorf("splitRepository(%q): expected %v, got [%s %s]", repository, expected, host, path)
+		}
+	}
+}
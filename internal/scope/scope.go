@@ -0,0 +1,144 @@
+package scope
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=list
+
+// Permission is a verb the operator needs on a resource, e.g. list on deployments in the apps group.
+type Permission struct {
+	Group    string
+	Resource string
+	Verb     string
+}
+
+func (p Permission) String() string {
+	if p.Group == "" {
+		return fmt.Sprintf("%s %s", p.Verb, p.Resource)
+	}
+	return fmt.Sprintf("%s %s.%s", p.Verb, p.Resource, p.Group)
+}
+
+// ResolveNamespaces resolves the namespaces watched by the operator, either listed or selected by their labels.
+// The namespaces selected are resolved once, a namespace labeled afterwards is only watched after a restart, and a
+// namespace unlabeled afterwards is still watched until then.
+//
+// Parameters:
+//
+//	ctx: The context of the requests.
+//	reader: The client listing the namespaces, reading from the API server.
+//	names: The comma separated names of the namespaces to watch.
+//	selector: The label selector of the namespaces to watch.
+//
+// Returns:
+//
+//	[]string: The sorted namespaces to watch, none if every namespace is watched.
+//	error: An error if both a list and a selector are set, the selector is invalid or the namespaces can't be listed.
+func ResolveNamespaces(ctx context.Context, reader client.Reader, names string, selector string) ([]string, error) {
+	if names != "" && selector != "" {
+		return nil, fmt.Errorf("the watched namespaces can't be both listed and selected")
+	}
+
+	var namespaces []string
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q: %w", selector, err)
+		}
+		var list corev1.NamespaceList
+		if err := reader.List(ctx, &list, client.MatchingLabelsSelector{Selector: parsed}); err != nil {
+			return nil, fmt.Errorf("failed to list the namespaces matching %q, which needs the permission to list namespaces: %w", selector, err)
+		}
+		if len(list.Items) == 0 {
+			return nil, fmt.Errorf("no namespace matches %q", selector)
+		}
+		for _, namespace := range list.Items {
+			namespaces = append(namespaces, namespace.Name)
+		}
+	}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			namespaces = append(namespaces, name)
+		}
+	}
+
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// CacheNamespaces returns the cache configuration of the watched namespaces, nil to cache every namespace.
+func CacheNamespaces(namespaces []string) map[string]cache.Config {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	out := map[string]cache.Config{}
+	for _, namespace := range namespaces {
+		out[namespace] = cache.Config{}
+	}
+	return out
+}
+
+// CheckPermissions reviews the permissions of the operator in each watched namespace, or cluster-wide when every
+// namespace is watched, so that it fails on startup rather than on its first reconciles when they are missing.
+//
+// Parameters:
+//
+//	ctx: The context of the requests.
+//	k8sClient: The client creating the self subject access reviews.
+//	namespaces: The watched namespaces, none if every namespace is watched.
+//	permissions: The permissions needed in each namespace.
+//
+// Returns:
+//
+//	error: An error listing the missing permissions, or the error of a review.
+func CheckPermissions(ctx context.Context, k8sClient client.Client, namespaces []string, permissions []Permission) error {
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	var missing []string
+	for _, namespace := range namespaces {
+		var denied []string
+		for _, permission := range permissions {
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: namespace,
+						Group:     permission.Group,
+						Resource:  permission.Resource,
+						Verb:      permission.Verb,
+					},
+				},
+			}
+			if err := k8sClient.Create(ctx, review); err != nil {
+				return fmt.Errorf("failed to review the permission to %s: %w", permission, err)
+			}
+			if !review.Status.Allowed {
+				denied = append(denied, permission.String())
+			}
+		}
+		if len(denied) == 0 {
+			continue
+		}
+		if namespace == "" {
+			missing = append(missing, fmt.Sprintf("cluster-wide: %s", strings.Join(denied, ", ")))
+		} else {
+			missing = append(missing, fmt.Sprintf("in namespace %s: %s", namespace, strings.Join(denied, ", ")))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing permissions %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
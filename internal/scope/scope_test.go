@@ -0,0 +1,112 @@
+package scope
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespaceClient lists the namespaces matching the selector, and allows the access reviews of the granted permissions.
+type namespaceClient struct {
+	client.Client
+	namespaces []corev1.Namespace
+	granted    map[string]bool
+}
+
+func (c *namespaceClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	options := &client.ListOptions{}
+	options.ApplyOptions(opts)
+	for _, namespace := range c.namespaces {
+		if options.LabelSelector.Matches(labels.Set(namespace.Labels)) {
+			list.(*corev1.NamespaceList).Items = append(list.(*corev1.NamespaceList).Items, namespace)
+		}
+	}
+	return nil
+}
+
+func (c *namespaceClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	attributes := obj.(*authorizationv1.SelfSubjectAccessReview).Spec.ResourceAttributes
+	obj.(*authorizationv1.SelfSubjectAccessReview).Status.Allowed = c.granted[attributes.Namespace+"/"+attributes.Verb+"/"+attributes.Resource]
+	return nil
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	k8sClient := &namespaceClient{namespaces: []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"team": "foo"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "foo"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"team": "bar"}}},
+	}}
+
+	for _, tc := range []struct {
+		name          string
+		names         string
+		selector      string
+		expected      []string
+		expectedError bool
+	}{
+		{name: "every namespace"},
+		{name: "listed", names: "team-b, team-a", expected: []string{"team-a", "team-b"}},
+		{name: "selected", selector: "team=foo", expected: []string{"team-a", "team-b"}},
+		{name: "none selected", selector: "team=baz", expectedError: true},
+		{name: "invalid selector", selector: "team==", expectedError: true},
+		{name: "listed and selected", names: "team-a", selector: "team=foo", expectedError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			namespaces, err := ResolveNamespaces(context.Background(), k8sClient, tc.names, tc.selector)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("ResolveNamespaces: expected error %t, got %v", tc.expectedError, err)
+			}
+			if diff := cmp.Diff(tc.expected, namespaces); diff != "" {
+				t.Errorf("ResolveNamespaces: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	permissions := []Permission{
+		{Resource: "configmaps", Verb: "list"},
+		{Group: "apps", Resource: "deployments", Verb: "create"},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		namespaces    []string
+		granted       map[string]bool
+		expectedError string
+	}{
+		{name: "granted cluster-wide", granted: map[string]bool{"/list/configmaps": true, "/create/deployments": true}},
+		{
+			name:          "missing cluster-wide",
+			granted:       map[string]bool{"/list/configmaps": true},
+			expectedError: "missing permissions cluster-wide: create deployments.apps",
+		},
+		{
+			name:       "granted in the namespaces",
+			namespaces: []string{"team-a"},
+			granted:    map[string]bool{"team-a/list/configmaps": true, "team-a/create/deployments": true},
+		},
+		{
+			name:          "missing in a namespace",
+			namespaces:    []string{"team-a", "team-b"},
+			granted:       map[string]bool{"team-a/list/configmaps": true, "team-a/create/deployments": true},
+			expectedError: "missing permissions in namespace team-b: list configmaps, create deployments.apps",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckPermissions(context.Background(), &namespaceClient{granted: tc.granted}, tc.namespaces, permissions)
+			if tc.expectedError == "" && err != nil {
+				t.Errorf("CheckPermissions: unexpected error %v", err)
+			}
+			if tc.expectedError != "" && (err == nil || err.Error() != tc.expectedError) {
+				t.Errorf("CheckPermissions: expected error %q, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
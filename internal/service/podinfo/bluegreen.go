@@ -0,0 +1,109 @@
+package podinfo
+
+import (
+	"fmt"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ColorLabel tells the blue pods from the green ones when a blue/green release is enabled.
+const ColorLabel = "my.api.group/color"
+
+// The values of the color label.
+const (
+	ColorBlue  = "blue"
+	ColorGreen = "green"
+)
+
+// PromoteAnnotation approves the switch-over to the preview color when it is set to the preview revision.
+const PromoteAnnotation = "my.api.group/promote"
+
+// GetColorDeployment retrieves the k8s Deployment running the pods of a color.
+// The pods are labelled like the ones of the podinfo Deployment, except for the color label
+// the Deployment selects its pods with.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	namespace: The namespace in which the Deployment lives.
+//	redisServerAddr: The address of the Redis server.
+//	spec: The MyAppResourceSpec containing specifications for the Deployment.
+//	color: The color of the Deployment, ColorBlue or ColorGreen.
+//
+// Returns:
+//
+//	*appsv1.Deployment: A pointer to the k8s Deployment object.
+func GetColorDeployment(name string, namespace string, redisServerAddr string, spec *myapigroupv1alpha1.MyAppResourceSpec, color string) *appsv1.Deployment {
+	deployment := GetDeployment(name, namespace, redisServerAddr, spec)
+	deployment.Name = generateColorName(name, color)
+	deployment.Labels = utils.MergeLabels(deployment.Labels, map[string]string{ColorLabel: color})
+
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return deployment
+	}
+
+	deployment.Spec.Selector.MatchLabels = utils.MergeLabels(deployment.Spec.Selector.MatchLabels, map[string]string{ColorLabel: color})
+	deployment.Spec.Template.Name = generateColorName(name, color)
+	deployment.Spec.Template.Labels = utils.MergeLabels(deployment.Spec.Template.Labels, map[string]string{ColorLabel: color})
+	return deployment
+}
+
+// GetPreviewService retrieves the k8s Service selecting the pods of the color that isn't served by the podinfo Service.
+// The Service isn't labelled like the podinfo Service so the ServiceMonitor doesn't scrape the pods twice.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	namespace: The namespace in which the Service lives.
+//	color: The color selected by the Service.
+//
+// Returns:
+//
+//	*corev1.Service: A pointer to the k8s Service object.
+func GetPreviewService(name string, namespace string, color string) *corev1.Service {
+	service := GetService(name, namespace, &myapigroupv1alpha1.MyAppResourceSpec{})
+	service.Name = generatePreviewName(name)
+	service.Labels = utils.GenerateDefaultLabels(generatePreviewName(name), namespace)
+	SelectColor(service, color)
+	return service
+}
+
+// SelectColor restricts the selector of the service to the pods of a color.
+func SelectColor(service *corev1.Service, color string) {
+	service.Spec.Selector = utils.MergeLabels(service.Spec.Selector, map[string]string{ColorLabel: color})
+}
+
+// GetAllBlueGreenObjects returns every object that may be rendered for a blue/green release.
+// It is used to clean up the blue/green objects once the blue/green release is disabled.
+func GetAllBlueGreenObjects(name string, namespace string) []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		newUnstructured(appsv1.SchemeGroupVersion.WithKind("Deployment"), generateColorName(name, ColorBlue), namespace),
+		newUnstructured(appsv1.SchemeGroupVersion.WithKind("Deployment"), generateColorName(name, ColorGreen), namespace),
+		newUnstructured(corev1.SchemeGroupVersion.WithKind("Service"), generatePreviewName(name), namespace),
+	}
+}
+
+// OtherColor returns the color that isn't the given one.
+func OtherColor(color string) string {
+	if color == ColorBlue {
+		return ColorGreen
+	}
+	return ColorBlue
+}
+
+// IsBlueGreenEnabled reports whether the podinfo changes are released through blue and green Deployments.
+func IsBlueGreenEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec.Rollout != nil && spec.Rollout.BlueGreen != nil
+}
+
+func generateColorName(baseName string, color string) string {
+	return fmt.Sprintf("%s-%s", generateObjectName(baseName), color)
+}
+
+func generatePreviewName(baseName string) string {
+	return fmt.Sprintf("%s-preview", generateObjectName(baseName))
+}
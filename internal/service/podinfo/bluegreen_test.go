@@ -0,0 +1,57 @@
+package podinfo
+
+import (
+	"testing"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetColorDeployment(t *testing.T) {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		Image:   &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+		Rollout: &myapigroupv1alpha1.Rollout{BlueGreen: &myapigroupv1alpha1.BlueGreen{}},
+	}
+	green := GetColorDeployment("testName", "testNamespace", "", spec, ColorGreen)
+
+	expectedLabels := map[string]string{
+		"app.kubernetes.io/name":      "testName-podinfo",
+		"app.kubernetes.io/namespace": "testNamespace",
+		ColorLabel:                    ColorGreen,
+	}
+	if green.Name != "testName-podinfo-green" {
+		t.Errorf("GetColorDeployment: expected name testName-podinfo-green, got %s", green.Name)
+	}
+	if diff := cmp.Diff(expectedLabels, green.Spec.Selector.MatchLabels); diff != "" {
+		t.Errorf("GetColorDeployment: selector mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedLabels, green.Spec.Template.Labels); diff != "" {
+		t.Errorf("GetColorDeployment: template labels mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetServiceSelectColor(t *testing.T) {
+	service := GetService("testName", "testNamespace", &myapigroupv1alpha1.MyAppResourceSpec{})
+	SelectColor(service, ColorBlue)
+	preview := GetPreviewService("testName", "testNamespace", ColorGreen)
+
+	expectedSelector := map[string]string{
+		"app.kubernetes.io/name":      "testName-podinfo",
+		"app.kubernetes.io/namespace": "testNamespace",
+		ColorLabel:                    ColorBlue,
+	}
+	if diff := cmp.Diff(expectedSelector, service.Spec.Selector); diff != "" {
+		t.Errorf("SelectColor: selector mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedSelector[ColorLabel] = ColorGreen
+	if preview.Name != "testName-podinfo-preview" {
+		t.Errorf("GetPreviewService: expected name testName-podinfo-preview, got %s", preview.Name)
+	}
+	if diff := cmp.Diff(expectedSelector, preview.Spec.Selector); diff != "" {
+		t.Errorf("GetPreviewService: selector mismatch (-want +got):\n%s", diff)
+	}
+	if preview.Labels["app.kubernetes.io/name"] != "testName-podinfo-preview" {
+		t.Errorf("GetPreviewService: expected the preview service not to be labelled like the podinfo service, got %v", preview.Labels)
+	}
+}
@@ -0,0 +1,182 @@
+package podinfo
+
+import (
+	"fmt"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/operatorconfig"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TrackLabel tells the stable pods from the canary ones when a canary release is enabled.
+const TrackLabel = "my.api.group/track"
+
+// The values of the track label.
+const (
+	TrackStable = "stable"
+	TrackCanary = "canary"
+)
+
+// HTTPRouteGVK identifies the Gateway API HTTPRoute splitting the traffic between the stable and canary pods.
+// The route is built as unstructured to avoid depending on the Gateway API module.
+var HTTPRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// GetCanaryDeployment retrieves the k8s Deployment running the canary pods.
+// The canary pods are labelled like the stable ones so the podinfo Service and monitors select both,
+// except for the track label the canary Deployment selects its pods with.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	namespace: The namespace in which the Deployment lives.
+//	redisServerAddr: The address of the Redis server.
+//	spec: The MyAppResourceSpec of the canary, holding the canary image and replica count.
+//
+// Returns:
+//
+//	*appsv1.Deployment: A pointer to the k8s Deployment object.
+func GetCanaryDeployment(name string, namespace string, redisServerAddr string, spec *myapigroupv1alpha1.MyAppResourceSpec) *appsv1.Deployment {
+	deployment := GetDeployment(name, namespace, redisServerAddr, spec)
+	deployment.Name = generateCanaryName(name)
+	deployment.Labels = utils.MergeLabels(deployment.Labels, map[string]string{TrackLabel: TrackCanary})
+
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return deployment
+	}
+
+	deployment.Spec.Selector.MatchLabels = utils.MergeLabels(deployment.Spec.Selector.MatchLabels, map[string]string{TrackLabel: TrackCanary})
+	deployment.Spec.Template.Name = generateCanaryName(name)
+	deployment.Spec.Template.Labels = utils.MergeLabels(deployment.Spec.Template.Labels, map[string]string{TrackLabel: TrackCanary})
+	return deployment
+}
+
+// GetTrackService retrieves the k8s Service selecting only the stable or canary pods, used as HTTPRoute backends.
+// The Service isn't labelled like the podinfo Service so the ServiceMonitor doesn't scrape the pods twice.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	namespace: The namespace in which the Service lives.
+//	track: The pods selected by the Service, TrackStable or TrackCanary.
+//
+// Returns:
+//
+//	*corev1.Service: A pointer to the k8s Service object.
+func GetTrackService(name string, namespace string, track string) *corev1.Service {
+	service := GetService(name, namespace, &myapigroupv1alpha1.MyAppResourceSpec{})
+	service.Name = generateTrackName(name, track)
+	service.Labels = utils.GenerateDefaultLabels(generateTrackName(name, track), namespace)
+	service.Spec.Selector = utils.MergeLabels(service.Spec.Selector, map[string]string{TrackLabel: track})
+	return service
+}
+
+// GetHTTPRoute retrieves the Gateway API HTTPRoute splitting the traffic between the stable and canary Services.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	namespace: The namespace in which the HTTPRoute lives.
+//	spec: The MyAppResourceSpec containing the canary traffic routing.
+//	canaryWeight: The percentage of the traffic sent to the canary.
+//
+// Returns:
+//
+//	*unstructured.Unstructured: A pointer to the HTTPRoute object. Only the metadata is set if gateway routing is disabled.
+func GetHTTPRoute(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec, canaryWeight int32) *unstructured.Unstructured {
+	out := newUnstructured(HTTPRouteGVK, generateObjectName(name), namespace)
+	if !IsGatewayRoutingEnabled(spec) {
+		return out
+	}
+	gateway := spec.Rollout.Canary.TrafficRouting.Gateway
+
+	var parentRefs []interface{}
+	for _, ref := range gateway.ParentRefs {
+		parentRef := map[string]interface{}{"name": ref.Name}
+		if ref.Namespace != "" {
+			parentRef["namespace"] = ref.Namespace
+		}
+		if ref.SectionName != "" {
+			parentRef["sectionName"] = ref.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+
+	routeSpec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{"name": generateTrackName(name, TrackStable), "port": int64(operatorconfig.Get().Podinfo.Port), "weight": int64(100 - canaryWeight)},
+					map[string]interface{}{"name": generateTrackName(name, TrackCanary), "port": int64(operatorconfig.Get().Podinfo.Port), "weight": int64(canaryWeight)},
+				},
+			},
+		},
+	}
+	if len(gateway.Hostnames) > 0 {
+		var hostnames []interface{}
+		for _, hostname := range gateway.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+		routeSpec["hostnames"] = hostnames
+	}
+	out.Object["spec"] = routeSpec
+
+	return out
+}
+
+// GetAllCanaryObjects returns every object that may be rendered for a canary release.
+// It is used to clean up the canary objects once a release is over or the canary release is disabled.
+func GetAllCanaryObjects(name string, namespace string) []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		newUnstructured(appsv1.SchemeGroupVersion.WithKind("Deployment"), generateCanaryName(name), namespace),
+		newUnstructured(corev1.SchemeGroupVersion.WithKind("Service"), generateTrackName(name, TrackStable), namespace),
+		newUnstructured(corev1.SchemeGroupVersion.WithKind("Service"), generateTrackName(name, TrackCanary), namespace),
+		newUnstructured(HTTPRouteGVK, generateObjectName(name), namespace),
+	}
+}
+
+// GetCanaryReplicas returns the replica counts of the stable and canary Deployments for the canary weight.
+// The canary runs at least one replica. With replica ratio routing the stable replicas are scaled down by as much,
+// with gateway routing the weights split the traffic so the stable Deployment keeps all its replicas.
+func GetCanaryReplicas(spec *myapigroupv1alpha1.MyAppResourceSpec, canaryWeight int32) (int32, int32) {
+	total := int32(1)
+	if spec.ReplicaCount != nil {
+		total = *spec.ReplicaCount
+	}
+
+	canary := (total*canaryWeight + 99) / 100
+	if canary < 1 {
+		canary = 1
+	}
+	if IsGatewayRoutingEnabled(spec) {
+		return total, canary
+	}
+
+	stable := total - canary
+	if stable < 0 {
+		stable = 0
+	}
+	return stable, canary
+}
+
+// IsCanaryEnabled reports whether image changes are released through a canary Deployment.
+func IsCanaryEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec.Rollout != nil && spec.Rollout.Canary != nil && len(spec.Rollout.Canary.Steps) > 0
+}
+
+// IsGatewayRoutingEnabled reports whether the canary traffic is split by an HTTPRoute.
+func IsGatewayRoutingEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return IsCanaryEnabled(spec) && spec.Rollout.Canary.TrafficRouting != nil && spec.Rollout.Canary.TrafficRouting.Gateway != nil
+}
+
+func generateCanaryName(baseName string) string {
+	return fmt.Sprintf("%s-canary", generateObjectName(baseName))
+}
+
+func generateTrackName(baseName string, track string) string {
+	return fmt.Sprintf("%s-%s", generateObjectName(baseName), track)
+}
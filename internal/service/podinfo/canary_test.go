@@ -0,0 +1,110 @@
+package podinfo
+
+import (
+	"testing"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newCanarySpec(gateway bool) *myapigroupv1alpha1.MyAppResourceSpec {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		ReplicaCount: utils.Ptr[int32](5),
+		Image:        &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "6.6.1"},
+		Rollout: &myapigroupv1alpha1.Rollout{
+			Canary: &myapigroupv1alpha1.Canary{Steps: []myapigroupv1alpha1.CanaryStep{{Weight: 10}}},
+		},
+	}
+	if gateway {
+		spec.Rollout.Canary.TrafficRouting = &myapigroupv1alpha1.TrafficRouting{
+			Gateway: &myapigroupv1alpha1.GatewayRouting{
+				ParentRefs: []myapigroupv1alpha1.GatewayParentReference{{Name: "public", Namespace: "gateways", SectionName: "https"}},
+				Hostnames:  []string{"podinfo.example.com"},
+			},
+		}
+	}
+	return spec
+}
+
+func TestGetCanaryDeployment(t *testing.T) {
+	spec := newCanarySpec(false)
+	stable := GetDeployment("testName", "testNamespace", "", spec)
+	canary := GetCanaryDeployment("testName", "testNamespace", "", spec)
+
+	expectedStableLabels := map[string]string{
+		"app.kubernetes.io/name":      "testName-podinfo",
+		"app.kubernetes.io/namespace": "testNamespace",
+		TrackLabel:                    TrackStable,
+	}
+	if diff := cmp.Diff(expectedStableLabels, stable.Spec.Template.Labels); diff != "" {
+		t.Errorf("GetDeployment: template labels mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedCanaryLabels := map[string]string{
+		"app.kubernetes.io/name":      "testName-podinfo",
+		"app.kubernetes.io/namespace": "testNamespace",
+		TrackLabel:                    TrackCanary,
+	}
+	if canary.Name != "testName-podinfo-canary" {
+		t.Errorf("GetCanaryDeployment: expected name testName-podinfo-canary, got %s", canary.Name)
+	}
+	if diff := cmp.Diff(expectedCanaryLabels, canary.Spec.Selector.MatchLabels); diff != "" {
+		t.Errorf("GetCanaryDeployment: selector mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedCanaryLabels, canary.Spec.Template.Labels); diff != "" {
+		t.Errorf("GetCanaryDeployment: template labels mismatch (-want +got):\n%s", diff)
+	}
+	if stable.Spec.Selector.MatchLabels[TrackLabel] != "" {
+		t.Errorf("GetDeployment: expected the immutable stable selector to be left untouched, got %v", stable.Spec.Selector.MatchLabels)
+	}
+}
+
+func TestGetCanaryReplicas(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		gateway        bool
+		weight         int32
+		expectedStable int32
+		expectedCanary int32
+	}{
+		{name: "replica ratio rounds the canary up", weight: 10, expectedStable: 4, expectedCanary: 1},
+		{name: "replica ratio at half", weight: 50, expectedStable: 2, expectedCanary: 3},
+		{name: "replica ratio at full", weight: 100, expectedStable: 0, expectedCanary: 5},
+		{name: "gateway keeps the stable replicas", gateway: true, weight: 50, expectedStable: 5, expectedCanary: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stable, canary := GetCanaryReplicas(newCanarySpec(tc.gateway), tc.weight)
+
+			if stable != tc.expectedStable || canary != tc.expectedCanary {
+				t.Errorf("GetCanaryReplicas: expected %d stable and %d canary replicas, got %d and %d", tc.expectedStable, tc.expectedCanary, stable, canary)
+			}
+		})
+	}
+}
+
+func TestGetHTTPRoute(t *testing.T) {
+	route := GetHTTPRoute("testName", "testNamespace", newCanarySpec(true), 30)
+
+	expected := map[string]interface{}{
+		"parentRefs": []interface{}{
+			map[string]interface{}{"name": "public", "namespace": "gateways", "sectionName": "https"},
+		},
+		"hostnames": []interface{}{"podinfo.example.com"},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{"name": "testName-podinfo-stable", "port": int64(9898), "weight": int64(70)},
+					map[string]interface{}{"name": "testName-podinfo-canary", "port": int64(9898), "weight": int64(30)},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, route.Object["spec"]); diff != "" {
+		t.Errorf("GetHTTPRoute: mismatch (-want +got):\n%s", diff)
+	}
+
+	if route := GetHTTPRoute("testName", "testNamespace", newCanarySpec(false), 30); route.Object["spec"] != nil {
+		t.Errorf("GetHTTPRoute: expected no spec without gateway routing, got %v", route.Object["spec"])
+	}
+}
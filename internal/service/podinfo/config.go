@@ -0,0 +1,141 @@
+package podinfo
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigHashAnnotation is set on the podinfo pod template so that the pods are restarted when the configuration changes.
+const ConfigHashAnnotation = "my.api.group/config-hash"
+
+const configFileName = "config.yaml"
+const configMountPath = "/config"
+const configVolumeName = "config"
+
+// GetConfigMap retrieves the k8s ConfigMap holding the podinfo configuration file.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	namespace: The namespace in which the ConfigMap lives.
+//	spec: The MyAppResourceSpec containing the podinfo configuration.
+//
+// Returns:
+//
+//	*corev1.ConfigMap: A pointer to the k8s ConfigMap object.
+func GetConfigMap(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generateConfigMapName(name),
+			Namespace: namespace,
+			Labels:    utils.GenerateDefaultLabels(generateObjectName(name), namespace),
+		},
+	}
+
+	if IsConfigEnabled(spec) {
+		configMap.Data = map[string]string{configFileName: generateConfigFile(spec.Config)}
+	}
+
+	return configMap
+}
+
+// IsConfigEnabled returns true if the podinfo configuration is rendered into a ConfigMap.
+func IsConfigEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec.Config != nil
+}
+
+// GetConfigHash returns the hash of the podinfo configuration file, or an empty string when no configuration is rendered.
+func GetConfigHash(config *myapigroupv1alpha1.Config) string {
+	if config == nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(generateConfigFile(config))))
+}
+
+// applyConfig mounts the podinfo configuration file in the pod and annotates the pod template with its hash.
+// podinfo reads the file from the directory set by --config-path, passed here through its PODINFO_CONFIG_PATH
+// environment form so the image's command is left untouched.
+func applyConfig(template *corev1.PodTemplateSpec, name string, spec *myapigroupv1alpha1.MyAppResourceSpec) {
+	if !IsConfigEnabled(spec) {
+		return
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[ConfigHashAnnotation] = GetConfigHash(spec.Config)
+
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: configVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: generateConfigMapName(name)},
+			},
+		},
+	})
+
+	container := &template.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: configVolumeName, MountPath: configMountPath, ReadOnly: true})
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "PODINFO_CONFIG_PATH", Value: configMountPath},
+		corev1.EnvVar{Name: "PODINFO_CONFIG", Value: configFileName},
+	)
+}
+
+// generateConfigFile renders the podinfo configuration file, keyed by podinfo's flag names.
+// Settings left unset in the spec are omitted so podinfo falls back to its own defaults.
+func generateConfigFile(config *myapigroupv1alpha1.Config) string {
+	settings := map[string]interface{}{}
+
+	if config.LogLevel != "" {
+		settings["level"] = config.LogLevel
+	}
+	if config.RandomDelay != nil {
+		settings["random-delay"] = utils.Deref(config.RandomDelay.Enabled)
+		if config.RandomDelay.Min != nil {
+			settings["random-delay-min"] = *config.RandomDelay.Min
+		}
+		if config.RandomDelay.Max != nil {
+			settings["random-delay-max"] = *config.RandomDelay.Max
+		}
+		if config.RandomDelay.Unit != "" {
+			settings["random-delay-unit"] = config.RandomDelay.Unit
+		}
+	}
+	if utils.Deref(config.RandomError) {
+		settings["random-error"] = true
+	}
+	if len(config.BackendURLs) > 0 {
+		settings["backend-url"] = config.BackendURLs
+	}
+	if config.UILogo != "" {
+		settings["ui-logo"] = config.UILogo
+	}
+	if utils.Deref(config.H2C) {
+		settings["h2c"] = true
+	}
+	if utils.Deref(config.Unhealthy) {
+		settings["unhealthy"] = true
+	}
+	if utils.Deref(config.Unready) {
+		settings["unready"] = true
+	}
+
+	// map keys are sorted when marshalled, so the file and its hash are stable.
+	out, err := yaml.Marshal(settings)
+	if err != nil {
+		// the settings only hold strings, integers, booleans and string slices, which always marshal.
+		panic(fmt.Sprintf("failed to marshal podinfo configuration: %v", err))
+	}
+	return string(out)
+}
+
+func generateConfigMapName(baseName string) string {
+	return fmt.Sprintf("%s-config", generateObjectName(baseName))
+}
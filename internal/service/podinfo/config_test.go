@@ -0,0 +1,123 @@
+package podinfo
+
+import (
+	"testing"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetConfigMap(t *testing.T) {
+
+	for _, tc := range []struct {
+		name     string
+		argSpec  *myapigroupv1alpha1.MyAppResourceSpec
+		expected *corev1.ConfigMap
+	}{
+		{
+			name:    "config disabled",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{},
+			expected: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo-config",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+			},
+		},
+		{
+			name: "full config",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Config: &myapigroupv1alpha1.Config{
+					LogLevel: "debug",
+					RandomDelay: &myapigroupv1alpha1.RandomDelay{
+						Enabled: utils.Ptr(true),
+						Min:     utils.Ptr[int32](10),
+						Max:     utils.Ptr[int32](500),
+						Unit:    "ms",
+					},
+					RandomError: utils.Ptr(true),
+					BackendURLs: []string{"http://backend-a:9898/echo", "http://backend-b:9898/echo"},
+					UILogo:      "https://example.com/logo.png",
+					H2C:         utils.Ptr(true),
+				},
+			},
+			expected: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo-config",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				Data: map[string]string{
+					"config.yaml": `backend-url:
+- http://backend-a:9898/echo
+- http://backend-b:9898/echo
+h2c: true
+level: debug
+random-delay: true
+random-delay-max: 500
+random-delay-min: 10
+random-delay-unit: ms
+random-error: true
+ui-logo: https://example.com/logo.png
+`,
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configMap := GetConfigMap("testName", "testNamespace", tc.argSpec)
+
+			if diff := cmp.Diff(tc.expected, configMap); diff != "" {
+				t.Errorf("GetConfigMap: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigHashAnnotation(t *testing.T) {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		Image:  &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "latest"},
+		UI:     &myapigroupv1alpha1.UI{Message: "some string"},
+		Config: &myapigroupv1alpha1.Config{LogLevel: "info"},
+	}
+	hash := func() string {
+		return GetDeployment("testName", "testNamespace", "", spec).Spec.Template.Annotations[ConfigHashAnnotation]
+	}
+
+	initial := hash()
+	if initial == "" {
+		t.Fatalf("GetDeployment: expected the %s annotation to be set", ConfigHashAnnotation)
+	}
+
+	spec.UI.Message = "another string"
+	if got := hash(); got != initial {
+		t.Errorf("GetDeployment: expected the hash to be unchanged when the config is unchanged, got %q instead of %q", got, initial)
+	}
+
+	spec.Config.LogLevel = "debug"
+	if got := hash(); got == initial {
+		t.Errorf("GetDeployment: expected the hash to change with the config, got %q", got)
+	}
+
+	deployment := GetDeployment("testName", "testNamespace", "", spec)
+	expectedVolume := corev1.Volume{
+		Name: "config",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "testName-podinfo-config"}},
+		},
+	}
+	volumes := deployment.Spec.Template.Spec.Volumes
+	if diff := cmp.Diff(expectedVolume, volumes[len(volumes)-1]); diff != "" {
+		t.Errorf("GetDeployment: config volume mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,171 @@
+package podinfo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// managedEnvPrefix is the prefix of the UI env vars managed by the operator.
+const managedEnvPrefix = "PODINFO_UI_"
+
+// managedEnvNames lists the env vars managed by the operator, whether the spec currently sets them or not.
+var managedEnvNames = map[string]bool{
+	"PODINFO_CACHE_SERVER": true,
+	"PODINFO_PORT_METRICS": true,
+	"PODINFO_CONFIG_PATH":  true,
+	"PODINFO_CONFIG":       true,
+}
+
+// managedVolumes maps the volumes managed by the operator to their mount path in the podinfo container.
+var managedVolumes = map[string]string{
+	"data":           "/data",
+	"tmp":            "/tmp",
+	configVolumeName: configMountPath,
+}
+
+// ValidateExtensions checks the additions to the podinfo pod template against the entries managed by the operator.
+//
+// Parameters:
+//
+//	name: The name of the MyAppResource.
+//	spec: The MyAppResourceSpec containing the pod template additions.
+//
+// Returns:
+//
+//	error: An error listing every conflicting entry, or nil if the additions can be merged.
+func ValidateExtensions(name string, spec *myapigroupv1alpha1.MyAppResourceSpec) error {
+	if spec.Podinfo == nil {
+		return nil
+	}
+	var errs error
+	extensions := spec.Podinfo
+
+	seen := map[string]bool{}
+	for _, env := range extensions.ExtraEnv {
+		if isManagedEnv(env.Name) {
+			errs = errors.Join(errs, fmt.Errorf("spec.podinfo.extraEnv: %s is managed by the operator", env.Name))
+		} else if seen[env.Name] {
+			errs = errors.Join(errs, fmt.Errorf("spec.podinfo.extraEnv: %s is set more than once", env.Name))
+		}
+		seen[env.Name] = true
+	}
+
+	seen = map[string]bool{}
+	for _, volume := range extensions.Volumes {
+		if _, found := managedVolumes[volume.Name]; found {
+			errs = errors.Join(errs, fmt.Errorf("spec.podinfo.volumes: %s is managed by the operator", volume.Name))
+		} else if seen[volume.Name] {
+			errs = errors.Join(errs, fmt.Errorf("spec.podinfo.volumes: %s is declared more than once", volume.Name))
+		}
+		seen[volume.Name] = true
+	}
+
+	seen = map[string]bool{}
+	for _, mount := range extensions.VolumeMounts {
+		if isManagedMountPath(mount.MountPath) {
+			errs = errors.Join(errs, fmt.Errorf("spec.podinfo.volumeMounts: %s is managed by the operator", mount.MountPath))
+		} else if seen[mount.MountPath] {
+			errs = errors.Join(errs, fmt.Errorf("spec.podinfo.volumeMounts: %s is mounted more than once", mount.MountPath))
+		}
+		seen[mount.MountPath] = true
+	}
+
+	// init containers and sidecars share the pod's container names with podinfo.
+	seen = map[string]bool{}
+	for _, group := range []struct {
+		field      string
+		containers []corev1.Container
+	}{
+		{field: "initContainers", containers: extensions.InitContainers},
+		{field: "sidecars", containers: extensions.Sidecars},
+	} {
+		for _, container := range group.containers {
+			if container.Name == generateObjectName(name) {
+				errs = errors.Join(errs, fmt.Errorf("spec.podinfo.%s: %s is the name of the podinfo container", group.field, container.Name))
+			} else if seen[container.Name] {
+				errs = errors.Join(errs, fmt.Errorf("spec.podinfo.%s: container %s is declared more than once", group.field, container.Name))
+			}
+			seen[container.Name] = true
+		}
+	}
+
+	return errs
+}
+
+// applyExtensions merges the additions of the spec into the podinfo pod template.
+// The operator's entries come first and the additions follow in the order of the spec. Conflicting additions,
+// as reported by ValidateExtensions, are left out so the rendered template stays valid.
+func applyExtensions(podSpec *corev1.PodSpec, spec *myapigroupv1alpha1.MyAppResourceSpec) {
+	if spec.Podinfo == nil {
+		return
+	}
+	extensions := spec.Podinfo
+	container := &podSpec.Containers[0]
+
+	seen := map[string]bool{}
+	for _, env := range extensions.ExtraEnv {
+		if !isManagedEnv(env.Name) && !seen[env.Name] {
+			container.Env = append(container.Env, *env.DeepCopy())
+		}
+		seen[env.Name] = true
+	}
+	for _, envFrom := range extensions.EnvFrom {
+		container.EnvFrom = append(container.EnvFrom, *envFrom.DeepCopy())
+	}
+
+	seen = map[string]bool{}
+	for _, volume := range extensions.Volumes {
+		if _, found := managedVolumes[volume.Name]; !found && !seen[volume.Name] {
+			podSpec.Volumes = append(podSpec.Volumes, *volume.DeepCopy())
+		}
+		seen[volume.Name] = true
+	}
+
+	seen = map[string]bool{}
+	for _, mount := range extensions.VolumeMounts {
+		if !isManagedMountPath(mount.MountPath) && !seen[mount.MountPath] {
+			container.VolumeMounts = append(container.VolumeMounts, *mount.DeepCopy())
+		}
+		seen[mount.MountPath] = true
+	}
+
+	seen = map[string]bool{container.Name: true}
+	for _, initContainer := range extensions.InitContainers {
+		if !seen[initContainer.Name] {
+			podSpec.InitContainers = append(podSpec.InitContainers, withSecurityContext(initContainer, container.SecurityContext))
+		}
+		seen[initContainer.Name] = true
+	}
+	for _, sidecar := range extensions.Sidecars {
+		if !seen[sidecar.Name] {
+			podSpec.Containers = append(podSpec.Containers, withSecurityContext(sidecar, container.SecurityContext))
+		}
+		seen[sidecar.Name] = true
+	}
+}
+
+// withSecurityContext returns a copy of the container running with the provided security context unless it sets its own.
+func withSecurityContext(container corev1.Container, securityContext *corev1.SecurityContext) corev1.Container {
+	out := container.DeepCopy()
+	if out.SecurityContext == nil && securityContext != nil {
+		out.SecurityContext = securityContext.DeepCopy()
+	}
+	return *out
+}
+
+func isManagedEnv(name string) bool {
+	return managedEnvNames[name] || strings.HasPrefix(name, managedEnvPrefix)
+}
+
+func isManagedMountPath(mountPath string) bool {
+	for _, managed := range managedVolumes {
+		if strings.TrimSuffix(mountPath, "/") == managed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package podinfo
+
+import (
+	"testing"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var testExtensions = &myapigroupv1alpha1.Podinfo{
+	ExtraEnv: []corev1.EnvVar{
+		{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token",
+		}}},
+		{Name: "PODINFO_UI_COLOR", Value: "#000000"},
+		{Name: "SSL_CERT_DIR", Value: "/etc/ssl/custom"},
+	},
+	EnvFrom: []corev1.EnvFromSource{
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}},
+	},
+	Volumes: []corev1.Volume{
+		{Name: "ca-bundle", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "ca-bundle"}}}},
+		{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+	},
+	VolumeMounts: []corev1.VolumeMount{
+		{Name: "ca-bundle", MountPath: "/etc/ssl/custom", ReadOnly: true},
+		{Name: "ca-bundle", MountPath: "/data/"},
+	},
+	InitContainers: []corev1.Container{
+		{Name: "wait", Image: "busybox"},
+	},
+	Sidecars: []corev1.Container{
+		{Name: "log-shipper", Image: "fluent-bit"},
+		{Name: "testName-podinfo", Image: "busybox"},
+		{Name: "wait", Image: "busybox"},
+	},
+}
+
+func TestValidateExtensions(t *testing.T) {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{Podinfo: testExtensions}
+
+	expected := "spec.podinfo.extraEnv: PODINFO_UI_COLOR is managed by the operator\n" +
+		"spec.podinfo.volumes: tmp is managed by the operator\n" +
+		"spec.podinfo.volumeMounts: /data/ is managed by the operator\n" +
+		"spec.podinfo.sidecars: testName-podinfo is the name of the podinfo container\n" +
+		"spec.podinfo.sidecars: container wait is declared more than once"
+
+	err := ValidateExtensions("testName", spec)
+	if err == nil {
+		t.Fatalf("ValidateExtensions: expected an error")
+	}
+	if diff := cmp.Diff(expected, err.Error()); diff != "" {
+		t.Errorf("ValidateExtensions: mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := ValidateExtensions("testName", &myapigroupv1alpha1.MyAppResourceSpec{}); err != nil {
+		t.Errorf("ValidateExtensions: unexpected error: %v", err)
+	}
+}
+
+func TestApplyExtensions(t *testing.T) {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		Image:   &myapigroupv1alpha1.Image{Repository: "ghcr.io/stefanprodan/podinfo", Tag: "latest"},
+		UI:      &myapigroupv1alpha1.UI{Color: "#34577c"},
+		Podinfo: testExtensions,
+	}
+
+	podSpec := GetDeployment("testName", "testNamespace", "", spec).Spec.Template.Spec
+
+	expectedEnv := []corev1.EnvVar{
+		{Name: "PODINFO_UI_COLOR", Value: "#34577c"},
+		testExtensions.ExtraEnv[0],
+		testExtensions.ExtraEnv[2],
+	}
+	if diff := cmp.Diff(expectedEnv, podSpec.Containers[0].Env); diff != "" {
+		t.Errorf("GetDeployment: env mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(testExtensions.EnvFrom, podSpec.Containers[0].EnvFrom); diff != "" {
+		t.Errorf("GetDeployment: envFrom mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(append(expectedVolumes, testExtensions.Volumes[0]), podSpec.Volumes); diff != "" {
+		t.Errorf("GetDeployment: volumes mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(append(expectedVolumeMounts, testExtensions.VolumeMounts[0]), podSpec.Containers[0].VolumeMounts); diff != "" {
+		t.Errorf("GetDeployment: volume mounts mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedInitContainers := []corev1.Container{
+		{Name: "wait", Image: "busybox", SecurityContext: expectedSecurityContext},
+	}
+	if diff := cmp.Diff(expectedInitContainers, podSpec.InitContainers); diff != "" {
+		t.Errorf("GetDeployment: init containers mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedContainerNames := []string{"testName-podinfo", "log-shipper"}
+	var containerNames []string
+	for _, container := range podSpec.Containers {
+		containerNames = append(containerNames, container.Name)
+	}
+	if diff := cmp.Diff(expectedContainerNames, containerNames); diff != "" {
+		t.Errorf("GetDeployment: containers mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedSecurityContext, podSpec.Containers[1].SecurityContext); diff != "" {
+		t.Errorf("GetDeployment: sidecar security context mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,163 @@
+package podinfo
+
+import (
+	"fmt"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const metricsPortName = "http-metrics"
+const defaultErrorRatePercent = 5
+
+// ServiceMonitorGVK, PodMonitorGVK and PrometheusRuleGVK identify the Prometheus Operator kinds rendered by this package.
+// The objects are built as unstructured to avoid depending on the Prometheus Operator API module.
+var (
+	ServiceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+	PodMonitorGVK     = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PodMonitor"}
+	PrometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+)
+
+// GetMonitor retrieves the ServiceMonitor or PodMonitor scraping the podinfo metrics port.
+//
+// Parameters:
+//
+//	name: The base name of the monitor.
+//	namespace: The namespace in which the monitor lives.
+//	spec: The MyAppResourceSpec containing the monitoring specification.
+//
+// Returns:
+//
+//	*unstructured.Unstructured: A pointer to the monitor object. Only the metadata is set if monitoring is disabled.
+func GetMonitor(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) *unstructured.Unstructured {
+	gvk := ServiceMonitorGVK
+	endpointsField := "endpoints"
+	if spec.Monitoring != nil && spec.Monitoring.Kind == PodMonitorGVK.Kind {
+		gvk = PodMonitorGVK
+		endpointsField = "podMetricsEndpoints"
+	}
+
+	out := newUnstructured(gvk, generateObjectName(name), namespace)
+	if !IsMonitoringEnabled(spec) {
+		return out
+	}
+	out.SetLabels(utils.MergeLabels(out.GetLabels(), spec.Monitoring.Labels))
+
+	endpoint := map[string]interface{}{
+		"port": metricsPortName,
+		"path": "/metrics",
+	}
+	if spec.Monitoring.Interval != "" {
+		endpoint["interval"] = spec.Monitoring.Interval
+	}
+
+	out.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": toInterfaceMap(utils.GenerateDefaultLabels(generateObjectName(name), namespace)),
+		},
+		endpointsField: []interface{}{endpoint},
+	}
+
+	return out
+}
+
+// GetAllMonitors returns every monitor kind that may be rendered for podinfo.
+// It is used to clean up monitors after monitoring is disabled or its kind changes.
+func GetAllMonitors(name string, namespace string) []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		newUnstructured(ServiceMonitorGVK, generateObjectName(name), namespace),
+		newUnstructured(PodMonitorGVK, generateObjectName(name), namespace),
+	}
+}
+
+// GetPrometheusRule retrieves the PrometheusRule holding the default podinfo alerts.
+// Two alerts are rendered: one firing when no podinfo target is up and one firing
+// when the share of 5xx responses exceeds the configured threshold.
+//
+// Parameters:
+//
+//	name: The base name of the PrometheusRule.
+//	namespace: The namespace in which the PrometheusRule lives.
+//	spec: The MyAppResourceSpec containing the monitoring specification.
+//
+// Returns:
+//
+//	*unstructured.Unstructured: A pointer to the PrometheusRule object. Only the metadata is set if the rules are disabled.
+func GetPrometheusRule(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) *unstructured.Unstructured {
+	out := newUnstructured(PrometheusRuleGVK, generateObjectName(name), namespace)
+	if !IsPrometheusRuleEnabled(spec) {
+		return out
+	}
+	out.SetLabels(utils.MergeLabels(out.GetLabels(), spec.Monitoring.Rules.Labels))
+
+	errorRatePercent := int32(defaultErrorRatePercent)
+	if spec.Monitoring.Rules.ErrorRatePercent != nil {
+		errorRatePercent = *spec.Monitoring.Rules.ErrorRatePercent
+	}
+
+	selector := fmt.Sprintf(`namespace="%s", pod=~"%s-.*"`, namespace, generateObjectName(name))
+	out.Object["spec"] = map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name": generateObjectName(name),
+				"rules": []interface{}{
+					map[string]interface{}{
+						"alert": "PodinfoUnavailable",
+						"expr":  fmt.Sprintf("sum(up{%s}) == 0 or absent(up{%s})", selector, selector),
+						"for":   "5m",
+						"labels": map[string]interface{}{
+							"severity": "critical",
+						},
+						"annotations": map[string]interface{}{
+							"summary": fmt.Sprintf("podinfo %s/%s has no available targets.", namespace, name),
+						},
+					},
+					map[string]interface{}{
+						"alert": "PodinfoHighErrorRate",
+						"expr": fmt.Sprintf(
+							`sum(rate(http_request_duration_seconds_count{%s, status=~"5.."}[5m])) / sum(rate(http_request_duration_seconds_count{%s}[5m])) * 100 > %d`,
+							selector, selector, errorRatePercent),
+						"for": "10m",
+						"labels": map[string]interface{}{
+							"severity": "warning",
+						},
+						"annotations": map[string]interface{}{
+							"summary": fmt.Sprintf("podinfo %s/%s serves more than %d%% of 5xx responses.", namespace, name, errorRatePercent),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return out
+}
+
+// IsPrometheusRuleEnabled reports whether the PrometheusRule should be rendered.
+func IsPrometheusRuleEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return IsMonitoringEnabled(spec) && spec.Monitoring.Rules != nil && utils.Deref(spec.Monitoring.Rules.Enabled)
+}
+
+// IsMonitoringEnabled reports whether the metrics port is exposed and the ServiceMonitor or PodMonitor rendered.
+func IsMonitoringEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec != nil && spec.Monitoring != nil && utils.Deref(spec.Monitoring.Enabled)
+}
+
+func newUnstructured(gvk schema.GroupVersionKind, name string, namespace string) *unstructured.Unstructured {
+	out := &unstructured.Unstructured{}
+	out.SetGroupVersionKind(gvk)
+	out.SetName(name)
+	out.SetNamespace(namespace)
+	out.SetLabels(utils.GenerateDefaultLabels(name, namespace))
+	return out
+}
+
+func toInterfaceMap(in map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package podinfo
+
+import (
+	"strings"
+	"testing"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetMonitor(t *testing.T) {
+
+	for _, tc := range []struct {
+		name     string
+		argSpec  *myapigroupv1alpha1.MyAppResourceSpec
+		expected *unstructured.Unstructured
+	}{
+		{
+			name:    "monitoring disabled",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{},
+			expected: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "monitoring.coreos.com/v1",
+				"kind":       "ServiceMonitor",
+				"metadata": map[string]interface{}{
+					"name":      "testName-podinfo",
+					"namespace": "testNamespace",
+					"labels": map[string]interface{}{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+			}},
+		},
+		{
+			name: "ServiceMonitor with interval and labels",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Monitoring: &myapigroupv1alpha1.Monitoring{
+					Enabled:  utils.Ptr(true),
+					Interval: "15s",
+					Labels:   map[string]string{"release": "prometheus"},
+				},
+			},
+			expected: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "monitoring.coreos.com/v1",
+				"kind":       "ServiceMonitor",
+				"metadata": map[string]interface{}{
+					"name":      "testName-podinfo",
+					"namespace": "testNamespace",
+					"labels": map[string]interface{}{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+						"release":                     "prometheus",
+					},
+				},
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					"endpoints": []interface{}{
+						map[string]interface{}{
+							"port":     "http-metrics",
+							"path":     "/metrics",
+							"interval": "15s",
+						},
+					},
+				},
+			}},
+		},
+		{
+			name: "PodMonitor",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Monitoring: &myapigroupv1alpha1.Monitoring{
+					Enabled: utils.Ptr(true),
+					Kind:    "PodMonitor",
+				},
+			},
+			expected: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "monitoring.coreos.com/v1",
+				"kind":       "PodMonitor",
+				"metadata": map[string]interface{}{
+					"name":      "testName-podinfo",
+					"namespace": "testNamespace",
+					"labels": map[string]interface{}{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					"podMetricsEndpoints": []interface{}{
+						map[string]interface{}{
+							"port": "http-metrics",
+							"path": "/metrics",
+						},
+					},
+				},
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			monitor := GetMonitor("testName", "testNamespace", tc.argSpec)
+
+			if diff := cmp.Diff(tc.expected, monitor); diff != "" {
+				t.Errorf("GetMonitor: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetPrometheusRule(t *testing.T) {
+	spec := &myapigroupv1alpha1.MyAppResourceSpec{
+		Monitoring: &myapigroupv1alpha1.Monitoring{
+			Enabled: utils.Ptr(true),
+			Rules: &myapigroupv1alpha1.MonitoringRules{
+				Enabled:          utils.Ptr(true),
+				ErrorRatePercent: utils.Ptr[int32](10),
+			},
+		},
+	}
+
+	rule := GetPrometheusRule("testName", "testNamespace", spec)
+	rules, found, err := unstructured.NestedSlice(rule.Object, "spec", "groups")
+	if err != nil || !found || len(rules) != 1 {
+		t.Fatalf("GetPrometheusRule: expected a single rule group, got %v (found=%t, err=%v)", rules, found, err)
+	}
+
+	alerts := rules[0].(map[string]interface{})["rules"].([]interface{})
+	if len(alerts) != 2 {
+		t.Fatalf("GetPrometheusRule: expected 2 alerts, got %d", len(alerts))
+	}
+	if expr := alerts[1].(map[string]interface{})["expr"].(string); !strings.HasSuffix(expr, "> 10") {
+		t.Errorf("GetPrometheusRule: expected the error rate threshold to be 10, got %q", expr)
+	}
+
+	spec.Monitoring.Rules.Enabled = utils.Ptr(false)
+	if _, found := GetPrometheusRule("testName", "testNamespace", spec).Object["spec"]; found {
+		t.Errorf("GetPrometheusRule: expected no spec when the rules are disabled")
+	}
+}
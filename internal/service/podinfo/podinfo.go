@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/operatorconfig"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/serviceaccount"
 	"github.com/aa-ang4335/myappresource-operator/internal/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -12,7 +14,12 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-const servicePort = 9898
+// podinfo listens on the default port unless PODINFO_PORT is set.
+const defaultPort = 9898
+
+// podinfo images run as the unprivileged "app" user and group.
+const runAsUser = 100
+const runAsGroup = 101
 
 // GetDeployment retrieves a k8s Deployment object based on the provided parameters.
 //
@@ -36,7 +43,7 @@ func GetDeployment(name string, namespace string, redisServerAddr string, spec *
 	}
 
 	// return an empty deployment if image deployment not set.
-	if spec.Image == nil || spec.Image.Repository == "" || spec.Image.Tag == "" {
+	if !IsImageSet(spec) {
 		return deployment
 	}
 
@@ -59,39 +66,74 @@ func GetDeployment(name string, namespace string, redisServerAddr string, spec *
 			Spec: corev1.PodSpec{
 				Containers: []corev1.Container{
 					{
-						Name:      fmt.Sprintf("%s-podinfo", name),
-						Image:     fmt.Sprintf("%s:%s", spec.Image.Repository, spec.Image.Tag),
-						Resources: containerResources,
-
-						Ports: []corev1.ContainerPort{
-							{
-								Name:          "http",
-								ContainerPort: servicePort,
-								Protocol:      "TCP",
-							},
-						},
+						Name:            fmt.Sprintf("%s-podinfo", name),
+						Image:           operatorconfig.MirrorImage(utils.GenerateImageReference(spec.Image)),
+						ImagePullPolicy: spec.Image.PullPolicy,
+						Resources:       containerResources,
+
+						Ports: generateContainerPorts(spec),
 
 						Env: envVarFromSpec,
+
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "data", MountPath: "/data"},
+							{Name: "tmp", MountPath: "/tmp"},
+						},
 					},
 				},
+				ImagePullSecrets: spec.Image.PullSecrets,
+				Volumes: []corev1.Volume{
+					{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				},
 			},
 		},
 	}
+	applyRollout(&deployment.Spec, spec.Rollout)
+	if IsCanaryEnabled(spec) {
+		deployment.Spec.Template.Labels[TrackLabel] = TrackStable
+	}
+	applyConfig(&deployment.Spec.Template, name, spec)
+	podSpec := &deployment.Spec.Template.Spec
+	serviceaccount.ApplyToPodSpec(podSpec, name, spec)
+	utils.ApplyScheduling(podSpec, spec.Scheduling, generateDefaultAffinity(name, namespace))
+	utils.ApplySecurityContext(podSpec, &podSpec.Containers[0], spec.SecurityContext,
+		utils.GenerateRestrictedPodSecurityContext(runAsUser, runAsGroup, nil), utils.GenerateRestrictedSecurityContext())
+	applyExtensions(podSpec, spec)
 
 	return deployment
 }
 
+// IsImageSet reports whether the spec sets the podinfo image, without which no pod is rendered.
+func IsImageSet(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec.Image != nil && spec.Image.Repository != "" && (spec.Image.Tag != "" || spec.Image.Digest != "")
+}
+
+// IsAnalysisEnabled reports whether the podinfo updates are analysed against Prometheus metrics.
+func IsAnalysisEnabled(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec.Rollout != nil && spec.Rollout.Analysis != nil && len(spec.Rollout.Analysis.Metrics) > 0
+}
+
 // GetService retrieves podinfo k8s Service object based on the provided parameters.
 //
 // Parameters:
 //
 //	name: The name of the Service to retrieve.
 //	namespace: The namespace in which the Service lives.
+//	spec: The MyAppResourceSpec containing specifications for the Service.
 //
 // Returns:
 //
 //	*corev1.Service: A pointer to the k8s Service object or nil.
-func GetService(name string, namespace string) *corev1.Service {
+func GetService(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) *corev1.Service {
+	config := operatorconfig.Get()
+	ports := []corev1.ServicePort{
+		{Name: "http", Protocol: "TCP", TargetPort: intstr.Parse("http"), Port: config.Podinfo.Port},
+	}
+	if IsMonitoringEnabled(spec) {
+		ports = append(ports, corev1.ServicePort{Name: metricsPortName, Protocol: "TCP", TargetPort: intstr.Parse(metricsPortName), Port: config.Podinfo.MetricsPort})
+	}
+
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-podinfo", name),
@@ -102,9 +144,7 @@ func GetService(name string, namespace string) *corev1.Service {
 			Type: corev1.ServiceTypeClusterIP,
 
 			Selector: utils.GenerateDefaultLabels(generateObjectName(name), namespace),
-			Ports: []corev1.ServicePort{
-				{Name: "http", Protocol: "TCP", TargetPort: intstr.Parse("http"), Port: servicePort},
-			},
+			Ports:    ports,
 		},
 	}
 }
@@ -114,6 +154,14 @@ func GetService(name string, namespace string) *corev1.Service {
 // We also skip setting env var value for PODINFO_CACHE_SERVER if the Redis backend is disabled.
 func generateEnvVarForSpec(spec *myapigroupv1alpha1.MyAppResourceSpec, redisServerAddr string) []corev1.EnvVar {
 	var result []corev1.EnvVar
+	config := operatorconfig.Get()
+
+	if config.Podinfo.Port != defaultPort {
+		result = append(result, corev1.EnvVar{
+			Name:  "PODINFO_PORT",
+			Value: fmt.Sprintf("%d", config.Podinfo.Port),
+		})
+	}
 
 	if spec.UI != nil {
 		if spec.UI.Color != "" {
@@ -131,7 +179,7 @@ func generateEnvVarForSpec(spec *myapigroupv1alpha1.MyAppResourceSpec, redisServ
 
 		if spec.Redis != nil {
 
-			if spec.Redis.Enabled {
+			if utils.Deref(spec.Redis.Enabled) {
 				result = append(result, corev1.EnvVar{
 					Name:  "PODINFO_CACHE_SERVER",
 					Value: redisServerAddr,
@@ -140,33 +188,101 @@ func generateEnvVarForSpec(spec *myapigroupv1alpha1.MyAppResourceSpec, redisServ
 		}
 	}
 
+	if IsMonitoringEnabled(spec) {
+		result = append(result, corev1.EnvVar{
+			Name:  "PODINFO_PORT_METRICS",
+			Value: fmt.Sprintf("%d", config.Podinfo.MetricsPort),
+		})
+	}
+
 	return result
 }
 
+// generateContainerPorts generates the podinfo container ports.
+// The metrics port is only declared when monitoring is enabled.
+func generateContainerPorts(spec *myapigroupv1alpha1.MyAppResourceSpec) []corev1.ContainerPort {
+	config := operatorconfig.Get()
+	ports := []corev1.ContainerPort{
+		{
+			Name:          "http",
+			ContainerPort: config.Podinfo.Port,
+			Protocol:      "TCP",
+		},
+	}
+
+	if IsMonitoringEnabled(spec) {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          metricsPortName,
+			ContainerPort: config.Podinfo.MetricsPort,
+			Protocol:      "TCP",
+		})
+	}
+
+	return ports
+}
+
 // generateResourceRequirements generates k8s resource requirements based on the provided resource specification.
-// container resource requirements are set if CPURequest and MemoryLimit are non-empty.
+// The deprecated CPURequest and MemoryLimit fields only fill the CPU request and memory limit when the requirements don't set them.
+// The default resources of the operator configuration apply when the specification isn't set.
 func generateResourceRequirements(resourceSpec *myapigroupv1alpha1.Resources) corev1.ResourceRequirements {
 
 	resourceRequirements := corev1.ResourceRequirements{}
 
 	if resourceSpec == nil {
-		return resourceRequirements
+		return *operatorconfig.Get().Podinfo.Resources.DeepCopy()
 	}
+	resourceRequirements = *resourceSpec.ResourceRequirements.DeepCopy()
 
-	if resourceSpec.CPURequest != "" {
-		resourceRequirements.Requests = corev1.ResourceList{
-			corev1.ResourceCPU: resource.MustParse(resourceSpec.CPURequest),
+	if _, found := resourceRequirements.Requests[corev1.ResourceCPU]; !found && resourceSpec.CPURequest != "" {
+		if resourceRequirements.Requests == nil {
+			resourceRequirements.Requests = corev1.ResourceList{}
 		}
+		resourceRequirements.Requests[corev1.ResourceCPU] = resource.MustParse(resourceSpec.CPURequest)
 	}
 
-	if resourceSpec.MemoryLimit != "" {
-		resourceRequirements.Limits = corev1.ResourceList{
-			corev1.ResourceMemory: resource.MustParse(resourceSpec.MemoryLimit),
+	if _, found := resourceRequirements.Limits[corev1.ResourceMemory]; !found && resourceSpec.MemoryLimit != "" {
+		if resourceRequirements.Limits == nil {
+			resourceRequirements.Limits = corev1.ResourceList{}
 		}
+		resourceRequirements.Limits[corev1.ResourceMemory] = resource.MustParse(resourceSpec.MemoryLimit)
 	}
 
 	return resourceRequirements
 }
+
+// applyRollout sets the rollout settings of the deployment. The Deployment defaults apply to the settings left unset.
+func applyRollout(deploymentSpec *appsv1.DeploymentSpec, rollout *myapigroupv1alpha1.Rollout) {
+	if rollout == nil {
+		return
+	}
+
+	if rollout.Strategy != nil {
+		deploymentSpec.Strategy = *rollout.Strategy.DeepCopy()
+	}
+	deploymentSpec.MinReadySeconds = rollout.MinReadySeconds
+	deploymentSpec.ProgressDeadlineSeconds = rollout.ProgressDeadlineSeconds
+	deploymentSpec.RevisionHistoryLimit = rollout.RevisionHistoryLimit
+}
+
+// generateDefaultAffinity generates a soft pod anti-affinity spreading the podinfo replicas across zones.
+func generateDefaultAffinity(name string, namespace string) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: utils.GenerateDefaultLabels(generateObjectName(name), namespace),
+						},
+						TopologyKey: corev1.LabelTopologyZone,
+					},
+				},
+			},
+		},
+	}
+}
+
 func generateObjectName(baseName string) string {
 	return fmt.Sprintf("%s-podinfo", baseName)
 }
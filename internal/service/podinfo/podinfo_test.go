@@ -10,8 +10,54 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+var expectedDefaultAffinity = &corev1.Affinity{
+	PodAntiAffinity: &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+			{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					TopologyKey: "topology.kubernetes.io/zone",
+				},
+			},
+		},
+	},
+}
+
+var expectedPodSecurityContext = &corev1.PodSecurityContext{
+	RunAsNonRoot:   utils.Ptr(true),
+	RunAsUser:      utils.Ptr[int64](100),
+	RunAsGroup:     utils.Ptr[int64](101),
+	SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+}
+
+var expectedSecurityContext = &corev1.SecurityContext{
+	AllowPrivilegeEscalation: utils.Ptr(false),
+	ReadOnlyRootFilesystem:   utils.Ptr(true),
+	RunAsNonRoot:             utils.Ptr(true),
+	Capabilities: &corev1.Capabilities{
+		Drop: []corev1.Capability{"ALL"},
+	},
+}
+
+var expectedVolumes = []corev1.Volume{
+	{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+}
+
+var expectedVolumeMounts = []corev1.VolumeMount{
+	{Name: "data", MountPath: "/data"},
+	{Name: "tmp", MountPath: "/tmp"},
+}
+
 type testCase struct {
 	name string
 
@@ -44,7 +90,7 @@ func TestGetDeployment(t *testing.T) {
 					Message: "some string",
 				},
 				Redis: &myapigroupv1alpha1.Redis{
-					Enabled: true,
+					Enabled: utils.Ptr(true),
 				},
 			},
 			expected: &appsv1.Deployment{
@@ -74,10 +120,17 @@ func TestGetDeployment(t *testing.T) {
 							},
 						},
 						Spec: corev1.PodSpec{
+							ServiceAccountName:           "testName-podinfo",
+							AutomountServiceAccountToken: utils.Ptr(false),
+							SecurityContext:              expectedPodSecurityContext,
+							Volumes:                      expectedVolumes,
+							Affinity:                     expectedDefaultAffinity,
 							Containers: []corev1.Container{
 								{
-									Name:  "testName-podinfo",
-									Image: "ghcr.io/stefanprodan/podinfo:latest",
+									Name:            "testName-podinfo",
+									Image:           "ghcr.io/stefanprodan/podinfo:latest",
+									SecurityContext: expectedSecurityContext,
+									VolumeMounts:    expectedVolumeMounts,
 									Ports: []corev1.ContainerPort{
 										{
 											Name:          "http",
@@ -129,7 +182,7 @@ func TestGetDeployment(t *testing.T) {
 					Message: "some string",
 				},
 				Redis: &myapigroupv1alpha1.Redis{
-					Enabled: true,
+					Enabled: utils.Ptr(true),
 				},
 			},
 			expected: &appsv1.Deployment{
@@ -159,10 +212,17 @@ func TestGetDeployment(t *testing.T) {
 							},
 						},
 						Spec: corev1.PodSpec{
+							ServiceAccountName:           "testName-podinfo",
+							AutomountServiceAccountToken: utils.Ptr(false),
+							SecurityContext:              expectedPodSecurityContext,
+							Volumes:                      expectedVolumes,
+							Affinity:                     expectedDefaultAffinity,
 							Containers: []corev1.Container{
 								{
-									Name:  "testName-podinfo",
-									Image: "ghcr.io/stefanprodan/podinfo:latest",
+									Name:            "testName-podinfo",
+									Image:           "ghcr.io/stefanprodan/podinfo:latest",
+									SecurityContext: expectedSecurityContext,
+									VolumeMounts:    expectedVolumeMounts,
 									Ports: []corev1.ContainerPort{
 										{
 											Name:          "http",
@@ -191,6 +251,321 @@ func TestGetDeployment(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:         "MyAppResourceSpec with monitoring enabled",
+			argNamespace: "testNamespace",
+			argName:      "testName",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Image: &myapigroupv1alpha1.Image{
+					Repository: "ghcr.io/stefanprodan/podinfo",
+					Tag:        "latest",
+				},
+				Monitoring: &myapigroupv1alpha1.Monitoring{
+					Enabled: utils.Ptr(true),
+				},
+			},
+			expected: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testName-podinfo",
+							Namespace: "testNamespace",
+							Labels: map[string]string{
+								"app.kubernetes.io/name":      "testName-podinfo",
+								"app.kubernetes.io/namespace": "testNamespace",
+							},
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName:           "testName-podinfo",
+							AutomountServiceAccountToken: utils.Ptr(false),
+							SecurityContext:              expectedPodSecurityContext,
+							Volumes:                      expectedVolumes,
+							Affinity:                     expectedDefaultAffinity,
+							Containers: []corev1.Container{
+								{
+									Name:            "testName-podinfo",
+									Image:           "ghcr.io/stefanprodan/podinfo:latest",
+									SecurityContext: expectedSecurityContext,
+									VolumeMounts:    expectedVolumeMounts,
+									Ports: []corev1.ContainerPort{
+										{
+											Name:          "http",
+											ContainerPort: 9898,
+											Protocol:      "TCP",
+										},
+										{
+											Name:          "http-metrics",
+											ContainerPort: 9797,
+											Protocol:      "TCP",
+										},
+									},
+									Env: []corev1.EnvVar{
+										{
+											Name:  "PODINFO_PORT_METRICS",
+											Value: "9797",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:         "MyAppResourceSpec with scheduling constraints",
+			argNamespace: "testNamespace",
+			argName:      "testName",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Image: &myapigroupv1alpha1.Image{
+					Repository: "ghcr.io/stefanprodan/podinfo",
+					Tag:        "latest",
+				},
+				Scheduling: &myapigroupv1alpha1.Scheduling{
+					NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+					Tolerations: []corev1.Toleration{
+						{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "frontend", Effect: corev1.TaintEffectNoSchedule},
+					},
+					Affinity: &corev1.Affinity{
+						PodAntiAffinity: &corev1.PodAntiAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+								{TopologyKey: "kubernetes.io/hostname"},
+							},
+						},
+					},
+					TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+						{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.ScheduleAnyway},
+					},
+					PriorityClassName: "high-priority",
+				},
+			},
+			expected: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testName-podinfo",
+							Namespace: "testNamespace",
+							Labels: map[string]string{
+								"app.kubernetes.io/name":      "testName-podinfo",
+								"app.kubernetes.io/namespace": "testNamespace",
+							},
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName:           "testName-podinfo",
+							AutomountServiceAccountToken: utils.Ptr(false),
+							SecurityContext:              expectedPodSecurityContext,
+							Volumes:                      expectedVolumes,
+							NodeSelector:                 map[string]string{"kubernetes.io/os": "linux"},
+							Tolerations: []corev1.Toleration{
+								{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "frontend", Effect: corev1.TaintEffectNoSchedule},
+							},
+							Affinity: &corev1.Affinity{
+								PodAntiAffinity: &corev1.PodAntiAffinity{
+									RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+										{TopologyKey: "kubernetes.io/hostname"},
+									},
+								},
+							},
+							TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+								{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.ScheduleAnyway},
+							},
+							PriorityClassName: "high-priority",
+							Containers: []corev1.Container{
+								{
+									Name:            "testName-podinfo",
+									Image:           "ghcr.io/stefanprodan/podinfo:latest",
+									SecurityContext: expectedSecurityContext,
+									VolumeMounts:    expectedVolumeMounts,
+									Ports: []corev1.ContainerPort{
+										{
+											Name:          "http",
+											ContainerPort: 9898,
+											Protocol:      "TCP",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:         "MyAppResourceSpec with private image pinned to a digest",
+			argNamespace: "testNamespace",
+			argName:      "testName",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Image: &myapigroupv1alpha1.Image{
+					Repository:  "registry.example.com/podinfo",
+					Digest:      "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+					PullPolicy:  corev1.PullAlways,
+					PullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+				},
+			},
+			expected: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testName-podinfo",
+							Namespace: "testNamespace",
+							Labels: map[string]string{
+								"app.kubernetes.io/name":      "testName-podinfo",
+								"app.kubernetes.io/namespace": "testNamespace",
+							},
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName:           "testName-podinfo",
+							AutomountServiceAccountToken: utils.Ptr(false),
+							SecurityContext:              expectedPodSecurityContext,
+							Volumes:                      expectedVolumes,
+							Affinity:                     expectedDefaultAffinity,
+							ImagePullSecrets:             []corev1.LocalObjectReference{{Name: "registry"}},
+							Containers: []corev1.Container{
+								{
+									Name:            "testName-podinfo",
+									Image:           "registry.example.com/podinfo@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+									ImagePullPolicy: corev1.PullAlways,
+									SecurityContext: expectedSecurityContext,
+									VolumeMounts:    expectedVolumeMounts,
+									Ports: []corev1.ContainerPort{
+										{
+											Name:          "http",
+											ContainerPort: 9898,
+											Protocol:      "TCP",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:         "MyAppResourceSpec with rollout settings",
+			argNamespace: "testNamespace",
+			argName:      "testName",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				Image: &myapigroupv1alpha1.Image{
+					Repository: "ghcr.io/stefanprodan/podinfo",
+					Tag:        "latest",
+				},
+				Rollout: &myapigroupv1alpha1.Rollout{
+					Strategy: &appsv1.DeploymentStrategy{
+						Type: appsv1.RollingUpdateDeploymentStrategyType,
+						RollingUpdate: &appsv1.RollingUpdateDeployment{
+							MaxUnavailable: utils.Ptr(intstr.FromInt32(0)),
+							MaxSurge:       utils.Ptr(intstr.FromString("50%")),
+						},
+					},
+					MinReadySeconds:         10,
+					ProgressDeadlineSeconds: utils.Ptr[int32](120),
+					RevisionHistoryLimit:    utils.Ptr[int32](5),
+				},
+			},
+			expected: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/name":      "testName-podinfo",
+							"app.kubernetes.io/namespace": "testNamespace",
+						},
+					},
+					Strategy: appsv1.DeploymentStrategy{
+						Type: appsv1.RollingUpdateDeploymentStrategyType,
+						RollingUpdate: &appsv1.RollingUpdateDeployment{
+							MaxUnavailable: utils.Ptr(intstr.FromInt32(0)),
+							MaxSurge:       utils.Ptr(intstr.FromString("50%")),
+						},
+					},
+					MinReadySeconds:         10,
+					ProgressDeadlineSeconds: utils.Ptr[int32](120),
+					RevisionHistoryLimit:    utils.Ptr[int32](5),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testName-podinfo",
+							Namespace: "testNamespace",
+							Labels: map[string]string{
+								"app.kubernetes.io/name":      "testName-podinfo",
+								"app.kubernetes.io/namespace": "testNamespace",
+							},
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName:           "testName-podinfo",
+							AutomountServiceAccountToken: utils.Ptr(false),
+							SecurityContext:              expectedPodSecurityContext,
+							Volumes:                      expectedVolumes,
+							Affinity:                     expectedDefaultAffinity,
+							Containers: []corev1.Container{
+								{
+									Name:            "testName-podinfo",
+									Image:           "ghcr.io/stefanprodan/podinfo:latest",
+									SecurityContext: expectedSecurityContext,
+									VolumeMounts:    expectedVolumeMounts,
+									Ports: []corev1.ContainerPort{
+										{
+											Name:          "http",
+											ContainerPort: 9898,
+											Protocol:      "TCP",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name:         "empty MyAppResourceSpec",
 			argNamespace: "testNamespace",
@@ -291,3 +666,68 @@ func TestGetDeployment(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateResourceRequirements(t *testing.T) {
+
+	for _, tc := range []struct {
+		name     string
+		argSpec  *myapigroupv1alpha1.Resources
+		expected corev1.ResourceRequirements
+	}{
+		{
+			name: "full resource requirements",
+			argSpec: &myapigroupv1alpha1.Resources{
+				ResourceRequirements: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:              resource.MustParse("100m"),
+						corev1.ResourceMemory:           resource.MustParse("64Mi"),
+						corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("128Mi"),
+					},
+				},
+			},
+			expected: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:              resource.MustParse("100m"),
+					corev1.ResourceMemory:           resource.MustParse("64Mi"),
+					corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+			},
+		},
+		{
+			name: "resource requirements take precedence over the deprecated fields",
+			argSpec: &myapigroupv1alpha1.Resources{
+				ResourceRequirements: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("200m"),
+					},
+				},
+				CPURequest:  "100m",
+				MemoryLimit: "64Mi",
+			},
+			expected: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("200m"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("64Mi"),
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resourceRequirements := generateResourceRequirements(tc.argSpec)
+
+			if diff := cmp.Diff(tc.expected, resourceRequirements); diff != "" {
+				t.Errorf("generateResourceRequirements: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package redis
 import (
 	"fmt"
 
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/operatorconfig"
+	"github.com/aa-ang4335/myappresource-operator/internal/service/serviceaccount"
 	"github.com/aa-ang4335/myappresource-operator/internal/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -10,8 +13,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-const version = "7.2.4"
-const servicePort = 6379
+// redis images run as the unprivileged "redis" user and group.
+const runAsUser = 999
+const runAsGroup = 999
 
 // GetStatefulset retrieves a redis StatefulSet object based on the provided parameters.
 //
@@ -19,13 +23,16 @@ const servicePort = 6379
 //
 //	baseName: The base name of the statefulSet.
 //	namespace: The namespace in which the StatefulSet resides.
+//	spec: The MyAppResourceSpec containing the Redis specification.
 //
 // Returns:
 //
 //	*apps.StatefulSet: A pointer to the Kubernetes StatefulSet object or nil
-func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
+func GetStatefulset(baseName string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) *appsv1.StatefulSet {
 
 	replicas := int32(1)
+	image := GetImage(spec)
+	config := operatorconfig.Get()
 	out := &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "StatefulSet",
@@ -34,9 +41,7 @@ func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      getName(baseName),
 			Namespace: namespace,
-			Labels: utils.MergeLabels(utils.GenerateDefaultLabels(getName(baseName), namespace), map[string]string{
-				"app.kubernetes.io/version": version,
-			}),
+			Labels:    utils.GenerateDefaultLabels(getName(baseName), namespace),
 		},
 		Spec: appsv1.StatefulSetSpec{
 			Selector: &metav1.LabelSelector{
@@ -52,11 +57,11 @@ func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
 					Containers: []corev1.Container{
 						{
 							Name:  getName(baseName),
-							Image: fmt.Sprintf("docker.io/redis:%s", version),
+							Image: operatorconfig.MirrorImage(utils.GenerateImageReference(image)),
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "redis",
-									ContainerPort: servicePort,
+									ContainerPort: config.Redis.Port,
 									Protocol:      "TCP",
 								},
 							},
@@ -66,6 +71,10 @@ func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
 									ReadOnly:  false,
 									MountPath: "/data",
 								},
+								{
+									Name:      "tmp",
+									MountPath: "/tmp",
+								},
 							},
 							LivenessProbe: &corev1.Probe{
 								ProbeHandler: corev1.ProbeHandler{
@@ -91,9 +100,14 @@ func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
 							},
 							TerminationMessagePath:   "/dev/termination-log",
 							TerminationMessagePolicy: "File",
-							ImagePullPolicy:          "IfNotPresent",
+							ImagePullPolicy:          image.PullPolicy,
+							Resources:                *config.Redis.Resources.DeepCopy(),
 						},
 					},
+					ImagePullSecrets: image.PullSecrets,
+					Volumes: []corev1.Volume{
+						{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					},
 					RestartPolicy: "Always",
 					DNSPolicy:     "ClusterFirst",
 				},
@@ -109,7 +123,7 @@ func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
 						Labels: utils.GenerateDefaultLabels(getName(baseName), namespace),
 					},
 					Spec: corev1.PersistentVolumeClaimSpec{
-						StorageClassName: utils.Ptr[string]("standard"),
+						StorageClassName: utils.Ptr(config.Redis.StorageClassName),
 						AccessModes: []corev1.PersistentVolumeAccessMode{
 							"ReadWriteOnce",
 						},
@@ -124,6 +138,55 @@ func GetStatefulset(baseName string, namespace string) *appsv1.StatefulSet {
 		},
 	}
 
+	if image.Tag != "" {
+		out.Labels["app.kubernetes.io/version"] = image.Tag
+	}
+
+	var overrides *myapigroupv1alpha1.SecurityContext
+	podSpec := &out.Spec.Template.Spec
+	serviceaccount.ApplyToPodSpec(podSpec, baseName, spec)
+	if spec.Redis != nil {
+		utils.ApplyScheduling(podSpec, spec.Redis.Scheduling, nil)
+		overrides = spec.Redis.SecurityContext
+	}
+	// the fsGroup makes the data volume writable by the redis user.
+	utils.ApplySecurityContext(podSpec, &podSpec.Containers[0], overrides,
+		utils.GenerateRestrictedPodSecurityContext(runAsUser, runAsGroup, utils.Ptr[int64](runAsGroup)), utils.GenerateRestrictedSecurityContext())
+
+	return out
+}
+
+// GetImage returns the Redis image specification, falling back to the default image of the operator configuration
+// for the fields that aren't set.
+func GetImage(spec *myapigroupv1alpha1.MyAppResourceSpec) *myapigroupv1alpha1.Image {
+	config := operatorconfig.Get()
+	out := &myapigroupv1alpha1.Image{
+		Repository: config.Redis.Repository,
+		Tag:        config.Redis.Tag,
+		PullPolicy: corev1.PullIfNotPresent,
+	}
+
+	if spec.Redis == nil || spec.Redis.Image == nil {
+		return out
+	}
+
+	custom := spec.Redis.Image
+	if custom.Repository != "" {
+		out.Repository = custom.Repository
+		out.Tag = custom.Tag
+	}
+	if custom.Tag != "" {
+		out.Tag = custom.Tag
+	}
+	if custom.Digest != "" {
+		out.Digest = custom.Digest
+	}
+	if custom.PullPolicy != "" {
+		out.PullPolicy = custom.PullPolicy
+	}
+	out.ResolveDigest = custom.ResolveDigest
+	out.PullSecrets = custom.PullSecrets
+
 	return out
 }
 
@@ -149,7 +212,7 @@ func GetService(baseName string, namespace string) *corev1.Service {
 			Type: corev1.ServiceTypeClusterIP,
 
 			Ports: []corev1.ServicePort{
-				{Name: "redis", Protocol: "TCP", Port: servicePort},
+				{Name: "redis", Protocol: "TCP", Port: operatorconfig.Get().Redis.Port},
 			},
 			Selector: utils.GenerateDefaultLabels(getName(baseName), namespace),
 		},
@@ -157,10 +220,11 @@ func GetService(baseName string, namespace string) *corev1.Service {
 
 }
 
-// GetServiceAddr returns the Kubernetes service address for Redis.
+// GetServiceAddr returns the Kubernetes service address for Redis, in the cluster domain of the operator configuration.
 // The value returned will be passed as an environment variable for the podinfo cache server.
 func GetServiceAddr(baseName string, namespace string) string {
-	return fmt.Sprintf("tcp://%s.%s.svc.cluster.local:%d", getName(baseName), namespace, servicePort)
+	config := operatorconfig.Get()
+	return fmt.Sprintf("tcp://%s.%s.svc.%s:%d", getName(baseName), namespace, config.ClusterDomain, config.Redis.Port)
 }
 
 func getName(baseName string) string {
@@ -0,0 +1,68 @@
+package serviceaccount
+
+import (
+	"fmt"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetServiceAccount retrieves the k8s ServiceAccount dedicated to an instance based on the provided parameters.
+// The ServiceAccount is named after the instance with a suffix, so an instance named like an existing ServiceAccount
+// (e.g. default) never takes it over.
+//
+// Parameters:
+//
+//	name: The name of the instance.
+//	namespace: The namespace in which the ServiceAccount lives.
+//	spec: The MyAppResourceSpec containing the ServiceAccount specification.
+//
+// Returns:
+//
+//	*corev1.ServiceAccount: A pointer to the k8s ServiceAccount object.
+func GetServiceAccount(name string, namespace string, spec *myapigroupv1alpha1.MyAppResourceSpec) *corev1.ServiceAccount {
+	out := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetName(name),
+			Namespace: namespace,
+			Labels:    utils.GenerateDefaultLabels(GetName(name), namespace),
+		},
+		AutomountServiceAccountToken: utils.Ptr(false),
+	}
+
+	if spec.ServiceAccount != nil {
+		out.Annotations = spec.ServiceAccount.Annotations
+		out.ImagePullSecrets = spec.ServiceAccount.ImagePullSecrets
+		if spec.ServiceAccount.AutomountServiceAccountToken != nil {
+			out.AutomountServiceAccountToken = spec.ServiceAccount.AutomountServiceAccountToken
+		}
+	}
+
+	return out
+}
+
+// GetName returns the name of the ServiceAccount dedicated to an instance.
+func GetName(baseName string) string {
+	return fmt.Sprintf("%s-podinfo", baseName)
+}
+
+// IsManaged reports whether the operator creates the ServiceAccount, i.e. no existing ServiceAccount is referenced.
+func IsManaged(spec *myapigroupv1alpha1.MyAppResourceSpec) bool {
+	return spec.ServiceAccountName == ""
+}
+
+// ApplyToPodSpec sets the ServiceAccount the pods of an instance run as and whether its token is mounted.
+// The token is only mounted when explicitly enabled.
+func ApplyToPodSpec(podSpec *corev1.PodSpec, name string, spec *myapigroupv1alpha1.MyAppResourceSpec) {
+	podSpec.ServiceAccountName = GetName(name)
+	if !IsManaged(spec) {
+		podSpec.ServiceAccountName = spec.ServiceAccountName
+	}
+
+	podSpec.AutomountServiceAccountToken = utils.Ptr(false)
+	if spec.ServiceAccount != nil && spec.ServiceAccount.AutomountServiceAccountToken != nil {
+		podSpec.AutomountServiceAccountToken = spec.ServiceAccount.AutomountServiceAccountToken
+	}
+}
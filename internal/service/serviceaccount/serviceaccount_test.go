@@ -0,0 +1,107 @@
+package serviceaccount
+
+import (
+	"testing"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetServiceAccount(t *testing.T) {
+
+	for _, tc := range []struct {
+		name     string
+		argSpec  *myapigroupv1alpha1.MyAppResourceSpec
+		expected *corev1.ServiceAccount
+	}{
+		{
+			name:    "empty MyAppResourceSpec",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{},
+			expected: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+				},
+				AutomountServiceAccountToken: utils.Ptr(false),
+			},
+		},
+		{
+			name: "MyAppResourceSpec with ServiceAccount spec",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				ServiceAccount: &myapigroupv1alpha1.ServiceAccount{
+					AutomountServiceAccountToken: utils.Ptr(true),
+					ImagePullSecrets:             []corev1.LocalObjectReference{{Name: "registry"}},
+					Annotations:                  map[string]string{"iam.gke.io/gcp-service-account": "app@project.iam.gserviceaccount.com"},
+				},
+			},
+			expected: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testName-podinfo",
+					Namespace: "testNamespace",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "testName-podinfo",
+						"app.kubernetes.io/namespace": "testNamespace",
+					},
+					Annotations: map[string]string{"iam.gke.io/gcp-service-account": "app@project.iam.gserviceaccount.com"},
+				},
+				AutomountServiceAccountToken: utils.Ptr(true),
+				ImagePullSecrets:             []corev1.LocalObjectReference{{Name: "registry"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			serviceAccount := GetServiceAccount("testName", "testNamespace", tc.argSpec)
+
+			if diff := cmp.Diff(tc.expected, serviceAccount); diff != "" {
+				t.Errorf("GetServiceAccount: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyToPodSpec(t *testing.T) {
+
+	for _, tc := range []struct {
+		name     string
+		argSpec  *myapigroupv1alpha1.MyAppResourceSpec
+		expected corev1.PodSpec
+	}{
+		{
+			name:    "managed ServiceAccount",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{},
+			expected: corev1.PodSpec{
+				ServiceAccountName:           "testName-podinfo",
+				AutomountServiceAccountToken: utils.Ptr(false),
+			},
+		},
+		{
+			name: "existing ServiceAccount with token automount",
+			argSpec: &myapigroupv1alpha1.MyAppResourceSpec{
+				ServiceAccountName: "existing",
+				ServiceAccount: &myapigroupv1alpha1.ServiceAccount{
+					AutomountServiceAccountToken: utils.Ptr(true),
+				},
+			},
+			expected: corev1.PodSpec{
+				ServiceAccountName:           "existing",
+				AutomountServiceAccountToken: utils.Ptr(true),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			podSpec := corev1.PodSpec{}
+			ApplyToPodSpec(&podSpec, "testName", tc.argSpec)
+
+			if diff := cmp.Diff(tc.expected, podSpec); diff != "" {
+				t.Errorf("ApplyToPodSpec: mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
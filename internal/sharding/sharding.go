@@ -0,0 +1,288 @@
+package sharding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/aa-ang4335/myappresource-operator/internal/scope"
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// ShardLabel groups the instances labeled with the same value on the same replica. The instances without it are
+// spread across the replicas by their namespace and name.
+const ShardLabel = "my.api.group/shard"
+
+// memberLabel selects the Leases of the replicas sharing the instances.
+const memberLabel = "my.api.group/shard-member"
+
+// leasePrefix prefixes the names of the Leases of the replicas.
+const leasePrefix = "myappresource-shard-"
+
+// RequiredPermissions returns the permissions on the Leases needed in their namespace, granted by the leader
+// election Role.
+func RequiredPermissions() []scope.Permission {
+	var permissions []scope.Permission
+	for _, verb := range []string{"get", "list", "create", "update", "delete"} {
+		permissions = append(permissions, scope.Permission{Group: coordinationv1.GroupName, Resource: "leases", Verb: verb})
+	}
+	return permissions
+}
+
+// Options configures the membership of a replica.
+type Options struct {
+	// Namespace is the namespace of the Leases of the replicas, usually the namespace of the operator.
+	Namespace string
+
+	// ID identifies the replica, usually its pod name. It must be unique across the replicas.
+	ID string
+
+	// LeaseDuration is the duration after which a replica that stopped renewing its Lease leaves the shards.
+	// The Leases are renewed three times per duration.
+	LeaseDuration time.Duration
+}
+
+// Sharder coordinates the replicas sharing the instances through Leases. Each replica renews its own Lease, and the
+// replicas whose Lease is live are the members the instances are assigned to with rendezvous hashing, so that only
+// the instances of the replica joining or leaving move when the members change.
+type Sharder struct {
+	client  client.Client
+	options Options
+	now     func() time.Time
+
+	lock        sync.RWMutex
+	members     []string
+	onRebalance []func()
+}
+
+// New returns the sharder of the replica.
+//
+// Parameters:
+//
+//	k8sClient: The client managing the Leases. It must read from the API server rather than from a cache.
+//	options: The membership of the replica.
+//
+// Returns:
+//
+//	*Sharder: The sharder, which must be added to the manager to join the shards.
+func New(k8sClient client.Client, options Options) *Sharder {
+	return &Sharder{client: k8sClient, options: options, now: time.Now}
+}
+
+// ID returns the identity of the replica.
+func (s *Sharder) ID() string {
+	return s.options.ID
+}
+
+// Members returns the sorted identities of the live replicas.
+func (s *Sharder) Members() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.members
+}
+
+// OnRebalance registers a function called when the members change, e.g. to reconcile the instances the replica
+// gained. It must be called before the sharder is started.
+func (s *Sharder) OnRebalance(f func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.onRebalance = append(s.onRebalance, f)
+}
+
+// Owns returns true if the instance is assigned to the replica. No instance is owned until the replica has joined.
+func (s *Sharder) Owns(object metav1.Object) bool {
+	return assign(getShardKey(object), s.Members()) == s.options.ID
+}
+
+// NeedLeaderElection returns false as every replica renews its own Lease.
+func (s *Sharder) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the Lease of the replica and tracks the members until the context is done, when the replica leaves
+// the shards by deleting its Lease so that the other replicas take over its instances right away.
+func (s *Sharder) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("shard", s.options.ID)
+	ticker := time.NewTicker(s.options.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			logger.Error(err, "failed to sync the shard members")
+		}
+		select {
+		case <-ctx.Done():
+			leaveCtx, cancel := context.WithTimeout(context.Background(), s.options.LeaseDuration/3)
+			defer cancel()
+			lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: leasePrefix + s.options.ID, Namespace: s.options.Namespace}}
+			if err := s.client.Delete(leaveCtx, lease); err != nil && !apierrors.IsNotFound(err) {
+				logger.Error(err, "failed to leave the shards")
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync renews the Lease of the replica and updates the members, calling the rebalance functions when they change.
+// They are called in the background, so that the renewals don't wait for the caches they read from to sync.
+func (s *Sharder) sync(ctx context.Context) error {
+	if err := s.renew(ctx); err != nil {
+		return err
+	}
+
+	var leases coordinationv1.LeaseList
+	if err := s.client.List(ctx, &leases, client.InNamespace(s.options.Namespace), client.HasLabels{memberLabel}); err != nil {
+		return fmt.Errorf("failed to list the shard leases: %w", err)
+	}
+	members := getLiveMembers(leases.Items, s.now())
+
+	s.lock.Lock()
+	changed := !equalMembers(s.members, members)
+	s.members = members
+	onRebalance := s.onRebalance
+	s.lock.Unlock()
+
+	if changed {
+		log.FromContext(ctx).Info("shard members changed", "shard", s.options.ID, "members", members)
+		for _, f := range onRebalance {
+			go f()
+		}
+	}
+	return nil
+}
+
+// renew creates or renews the Lease of the replica.
+func (s *Sharder) renew(ctx context.Context) error {
+	now := metav1.NewMicroTime(s.now())
+	lease := &coordinationv1.Lease{}
+	key := client.ObjectKey{Namespace: s.options.Namespace, Name: leasePrefix + s.options.ID}
+	if err := s.client.Get(ctx, key, lease); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get the shard lease: %w", err)
+		}
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace, Labels: map[string]string{memberLabel: "true"}},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       utils.Ptr(s.options.ID),
+				LeaseDurationSeconds: utils.Ptr(int32(s.options.LeaseDuration.Seconds())),
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if err := s.client.Create(ctx, lease); err != nil {
+			return fmt.Errorf("failed to create the shard lease: %w", err)
+		}
+		return nil
+	}
+
+	lease.Spec.HolderIdentity = utils.Ptr(s.options.ID)
+	lease.Spec.LeaseDurationSeconds = utils.Ptr(int32(s.options.LeaseDuration.Seconds()))
+	lease.Spec.RenewTime = &now
+	if err := s.client.Update(ctx, lease); err != nil {
+		return fmt.Errorf("failed to renew the shard lease: %w", err)
+	}
+	return nil
+}
+
+// Collector returns the runnable deleting the Leases of the replicas that left without deleting them, e.g. after a
+// crash. It needs leader election, so that a single replica collects them.
+func (s *Sharder) Collector() manager.Runnable {
+	return manager.RunnableFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(s.options.LeaseDuration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := s.collect(ctx); err != nil {
+					log.FromContext(ctx).Error(err, "failed to collect the expired shard leases")
+				}
+			}
+		}
+	})
+}
+
+// collect deletes the Leases expired for longer than their duration.
+func (s *Sharder) collect(ctx context.Context) error {
+	var leases coordinationv1.LeaseList
+	if err := s.client.List(ctx, &leases, client.InNamespace(s.options.Namespace), client.HasLabels{memberLabel}); err != nil {
+		return fmt.Errorf("failed to list the shard leases: %w", err)
+	}
+	for i := range leases.Items {
+		lease := &leases.Items[i]
+		if isLive(lease, s.now().Add(-s.options.LeaseDuration)) {
+			continue
+		}
+		if err := s.client.Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete the shard lease %s: %w", lease.Name, err)
+		}
+	}
+	return nil
+}
+
+// getLiveMembers returns the sorted identities of the holders of the Leases renewed within their duration.
+func getLiveMembers(leases []coordinationv1.Lease, now time.Time) []string {
+	var members []string
+	for i := range leases {
+		if isLive(&leases[i], now) {
+			members = append(members, *leases[i].Spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// isLive returns true if the Lease is held and was renewed within its duration at the given time.
+func isLive(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
+
+// getShardKey returns the value of the shard label of the instance, or its namespace and name.
+func getShardKey(object metav1.Object) string {
+	if value := object.GetLabels()[ShardLabel]; value != "" {
+		return value
+	}
+	return object.GetNamespace() + "/" + object.GetName()
+}
+
+// assign returns the member with the highest hash of the key, none if there is no member.
+func assign(key string, members []string) string {
+	var owner string
+	var highest uint64
+	for _, member := range members {
+		hash := sha256.Sum256([]byte(member + "/" + key))
+		if score := binary.BigEndian.Uint64(hash[:8]); owner == "" || score > highest {
+			owner, highest = member, score
+		}
+	}
+	return owner
+}
+
+func equalMembers(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aa-ang4335/myappresource-operator/internal/utils"
+)
+
+// leaseClient keeps the leases in memory, shared by the sharders of the replicas.
+type leaseClient struct {
+	client.Client
+	leases map[string]*coordinationv1.Lease
+}
+
+func (c *leaseClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	lease, found := c.leases[key.Name]
+	if !found {
+		return apierrors.NewNotFound(coordinationv1.Resource("leases"), key.Name)
+	}
+	lease.DeepCopyInto(obj.(*coordinationv1.Lease))
+	return nil
+}
+
+func (c *leaseClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	for _, lease := range c.leases {
+		list.(*coordinationv1.LeaseList).Items = append(list.(*coordinationv1.LeaseList).Items, *lease.DeepCopy())
+	}
+	return nil
+}
+
+func (c *leaseClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.leases[obj.GetName()] = obj.(*coordinationv1.Lease).DeepCopy()
+	return nil
+}
+
+func (c *leaseClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.leases[obj.GetName()] = obj.(*coordinationv1.Lease).DeepCopy()
+	return nil
+}
+
+func (c *leaseClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	delete(c.leases, obj.GetName())
+	return nil
+}
+
+func newInstance(i int) metav1.Object {
+	return &metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("instance-%d", i)}
+}
+
+func TestAssign(t *testing.T) {
+	members := []string{"a", "b", "c"}
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		counts[assign(getShardKey(newInstance(i)), members)]++
+	}
+	for _, member := range members {
+		if counts[member] < 60 {
+			t.Errorf("assign: expected the instances to be spread, got %v", counts)
+		}
+	}
+
+	// only the instances of the joining member move
+	for i := 0; i < 300; i++ {
+		before, after := assign(getShardKey(newInstance(i)), members), assign(getShardKey(newInstance(i)), append(members, "d"))
+		if before != after && after != "d" {
+			t.Errorf("assign: instance %d moved from %s to %s", i, before, after)
+		}
+	}
+
+	labeled := func(name string) metav1.Object {
+		return &metav1.ObjectMeta{Namespace: "default", Name: name, Labels: map[string]string{ShardLabel: "team-a"}}
+	}
+	if assign(getShardKey(labeled("foo")), members) != assign(getShardKey(labeled("bar")), members) {
+		t.Errorf("assign: expected the instances with the same shard label to share a member")
+	}
+	if owner := assign("whatever", nil); owner != "" {
+		t.Errorf("assign: expected no member, got %s", owner)
+	}
+}
+
+func TestSync(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	k8sClient := &leaseClient{leases: map[string]*coordinationv1.Lease{
+		"myappresource-shard-crashed": {
+			ObjectMeta: metav1.ObjectMeta{Name: "myappresource-shard-crashed"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       utils.Ptr("crashed"),
+				LeaseDurationSeconds: utils.Ptr(int32(15)),
+				RenewTime:            &metav1.MicroTime{Time: now.Add(-time.Minute)},
+			},
+		},
+	}}
+	newSharder := func(id string) (*Sharder, *atomic.Int32) {
+		sharder := New(k8sClient, Options{Namespace: "default", ID: id, LeaseDuration: 15 * time.Second})
+		sharder.now = func() time.Time { return now }
+		var rebalances atomic.Int32
+		sharder.OnRebalance(func() { rebalances.Add(1) })
+		return sharder, &rebalances
+	}
+	a, aRebalances := newSharder("a")
+	b, bRebalances := newSharder("b")
+
+	for _, sharder := range []*Sharder{a, b, a} {
+		if err := sharder.sync(context.Background()); err != nil {
+			t.Fatalf("sync: unexpected error %v", err)
+		}
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, a.Members()); diff != "" {
+		t.Errorf("sync: members mismatch (-want +got):\n%s", diff)
+	}
+	// a saw itself join, then b
+	if err := wait.PollUntilContextTimeout(context.Background(), time.Millisecond, time.Second, true, func(context.Context) (bool, error) {
+		return aRebalances.Load() == 2 && bRebalances.Load() == 1, nil
+	}); err != nil {
+		t.Errorf("sync: expected 2 and 1 rebalances, got %d and %d", aRebalances.Load(), bRebalances.Load())
+	}
+	for i := 0; i < 100; i++ {
+		if a.Owns(newInstance(i)) == b.Owns(newInstance(i)) {
+			t.Errorf("sync: expected instance %d to be owned by a single replica", i)
+		}
+	}
+
+	if err := a.collect(context.Background()); err != nil {
+		t.Fatalf("collect: unexpected error %v", err)
+	}
+	if _, found := k8sClient.leases["myappresource-shard-crashed"]; found {
+		t.Errorf("collect: expected the expired lease to be deleted")
+	}
+	if len(k8sClient.leases) != 2 {
+		t.Errorf("collect: expected the live leases to be kept, got %d leases", len(k8sClient.leases))
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// serviceName is the name the operator reports its spans under.
+const serviceName = "myappresource-operator"
+
+// Options configures the export of the spans.
+type Options struct {
+	// Endpoint is the host and port of the OTLP/HTTP endpoint of the collector. The spans are written to stdout
+	// when it is empty.
+	Endpoint string
+
+	// Insecure disables TLS for the OTLP endpoint, e.g. for a collector running alongside the operator.
+	Insecure bool
+
+	// SampleRatio is the ratio of the reconciles traced, between 0 and 1.
+	SampleRatio float64
+}
+
+// Setup registers a global tracer provider exporting the spans as configured, so that the tracers of the operator
+// stop being no-ops.
+//
+// Parameters:
+//
+//	ctx: The context the exporter is created with.
+//	options: The export configuration.
+//
+// Returns:
+//
+//	func(context.Context) error: A function flushing the pending spans and stopping the export, to call on shutdown.
+//	error: An error if the exporter couldn't be created.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	if options.Endpoint != "" {
+		exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(options.Endpoint)}
+		if options.Insecure {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, exporterOptions...)
+	} else {
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the span exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
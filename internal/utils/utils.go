@@ -1,10 +1,26 @@
 package utils
 
+import (
+	"strings"
+
+	myapigroupv1alpha1 "github.com/aa-ang4335/myappresource-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
 // Ptr returns a pointer to the placeholder object
 func Ptr[C any](in C) *C {
 	return &in
 }
 
+// Deref returns the value the pointer points to, or the zero value when it is nil.
+func Deref[C any](in *C) C {
+	var out C
+	if in != nil {
+		out = *in
+	}
+	return out
+}
+
 // MergeLabels merges all the label maps into a single new label map.
 func MergeLabels(allLabels ...map[string]string) map[string]string {
 	out := map[string]string{}
@@ -24,3 +40,94 @@ func GenerateDefaultLabels(name, namespace string) map[string]string {
 		"app.kubernetes.io/namespace": namespace,
 	}
 }
+
+// ApplyScheduling sets the scheduling constraints of the pod spec from the provided scheduling specification.
+// The default affinity is kept when the specification doesn't set one.
+func ApplyScheduling(podSpec *corev1.PodSpec, scheduling *myapigroupv1alpha1.Scheduling, defaultAffinity *corev1.Affinity) {
+	podSpec.Affinity = defaultAffinity
+
+	if scheduling == nil {
+		return
+	}
+
+	podSpec.NodeSelector = scheduling.NodeSelector
+	podSpec.Tolerations = scheduling.Tolerations
+	podSpec.TopologySpreadConstraints = scheduling.TopologySpreadConstraints
+	podSpec.PriorityClassName = scheduling.PriorityClassName
+	if scheduling.Affinity != nil {
+		podSpec.Affinity = scheduling.Affinity
+	}
+}
+
+// GenerateRestrictedPodSecurityContext generates a pod security context complying with the restricted Pod Security Standard.
+func GenerateRestrictedPodSecurityContext(user int64, group int64, fsGroup *int64) *corev1.PodSecurityContext {
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot: Ptr(true),
+		RunAsUser:    Ptr(user),
+		RunAsGroup:   Ptr(group),
+		FSGroup:      fsGroup,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// GenerateRestrictedSecurityContext generates a container security context complying with the restricted Pod Security Standard.
+// The root filesystem is read-only, so writable paths must be mounted as volumes.
+func GenerateRestrictedSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: Ptr(false),
+		ReadOnlyRootFilesystem:   Ptr(true),
+		RunAsNonRoot:             Ptr(true),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// ApplySecurityContext sets the pod and container security contexts from the provided overrides.
+// The default security contexts are kept for the levels the overrides don't set.
+func ApplySecurityContext(podSpec *corev1.PodSpec, container *corev1.Container, overrides *myapigroupv1alpha1.SecurityContext, defaultPod *corev1.PodSecurityContext, defaultContainer *corev1.SecurityContext) {
+	podSpec.SecurityContext = defaultPod
+	container.SecurityContext = defaultContainer
+
+	if overrides == nil {
+		return
+	}
+
+	if overrides.Pod != nil {
+		podSpec.SecurityContext = overrides.Pod
+	}
+	if overrides.Container != nil {
+		container.SecurityContext = overrides.Container
+	}
+}
+
+// GenerateImageReference generates a container image reference from its repository, tag and digest.
+// The digest takes precedence over the tag when pulling, the tag is kept for readability.
+func GenerateImageReference(image *myapigroupv1alpha1.Image) string {
+	out := image.Repository
+	if image.Tag != "" {
+		out += ":" + image.Tag
+	}
+	if image.Digest != "" {
+		out += "@" + image.Digest
+	}
+	return out
+}
+
+// ParseImageReference parses an image reference generated by GenerateImageReference.
+// The other fields of the provided image, such as the pull policy, are kept.
+func ParseImageReference(reference string, image *myapigroupv1alpha1.Image) *myapigroupv1alpha1.Image {
+	out := image.DeepCopy()
+	out.Repository, out.Tag, out.Digest = reference, "", ""
+
+	if repository, digest, found := strings.Cut(reference, "@"); found {
+		out.Repository, out.Digest = repository, digest
+	}
+	// a colon after the last slash separates the tag, otherwise it belongs to the registry host port.
+	if i := strings.LastIndex(out.Repository, ":"); i > strings.LastIndex(out.Repository, "/") {
+		out.Repository, out.Tag = out.Repository[:i], out.Repository[i+1:]
+	}
+	return out
+}